@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -14,6 +15,13 @@ type Config struct {
 	DataBase DBMSConf
 	Server   ServerConf
 	Rabbit   RabbitConf
+	Tokens   TokensConf
+
+	Idempotency IdempotencyConf
+	Capping     CappingConf
+	Holdout     HoldoutConf
+	Fraud       FraudConf
+	Alerts      AlertsConf
 }
 
 type LoggerConf struct {
@@ -41,6 +49,71 @@ type RabbitConf struct {
 	ShowRoutingKey  string `mapstructure:"show routing key"`
 }
 
+// TokensConf configures the signed impression tokens, which shows and clicks must carry.
+// Raw ids are off by default, as they can be forged. The service doesn't start without the secret
+// unless AllowRawIDs is set explicitly: then the shows and clicks without a token are accepted
+// by raw ids, so that old clients keep working.
+type TokensConf struct {
+	Secret      string        `mapstructure:"secret"`
+	TTL         time.Duration `mapstructure:"ttl"`
+	AllowRawIDs bool          `mapstructure:"allow raw ids"`
+}
+
+// IdempotencyConf configures how repeated clicks and shows are recognised,
+// the storage is "memory", "postgres" or empty to disable it.
+type IdempotencyConf struct {
+	Storage string        `mapstructure:"storage"`
+	Window  time.Duration `mapstructure:"window"`
+	Size    int           `mapstructure:"size"`
+}
+
+// CappingConf configures where the shows to users are counted for the frequency caps,
+// the storage is "memory", "postgres" or empty to ignore the caps.
+type CappingConf struct {
+	Storage string `mapstructure:"storage"`
+}
+
+// HoldoutConf sets the percent of the traffic served at random to measure the gain of the bandits.
+type HoldoutConf struct {
+	Percent float64 `mapstructure:"percent"`
+}
+
+// FraudConf configures the screening of clicks, the mode is "flag" to store the suspicious
+// clicks for review, "drop" to throw them away or empty to accept all the clicks.
+// TrustedProxies lists the addresses or CIDR ranges of the proxies in front of the service,
+// X-Forwarded-For is ignored in the requests coming from anywhere else.
+type FraudConf struct {
+	Mode           string        `mapstructure:"mode"`
+	Window         time.Duration `mapstructure:"window"`
+	MaxClicks      int           `mapstructure:"max clicks"`
+	MinClickDelay  time.Duration `mapstructure:"min click delay"`
+	SpikeMinClicks int           `mapstructure:"spike min clicks"`
+	SpikeCTR       float64       `mapstructure:"spike ctr"`
+	TrustedProxies []string      `mapstructure:"trusted proxies"`
+}
+
+// AlertsConf configures the alert rules evaluated every interval and the webhook
+// which receives the alerts, the alerts are disabled while the webhook url is empty.
+type AlertsConf struct {
+	WebhookURL string          `mapstructure:"webhook url"`
+	Secret     string          `mapstructure:"secret"`
+	Retries    int             `mapstructure:"retries"`
+	Backoff    time.Duration   `mapstructure:"backoff"`
+	Interval   time.Duration   `mapstructure:"interval"`
+	Rules      []AlertRuleConf `mapstructure:"rules"`
+}
+
+// AlertRuleConf is a rule of AlertsConf, the kind is "ctr_drop" or "slot_not_found".
+type AlertRuleConf struct {
+	Name           string  `mapstructure:"name"`
+	Kind           string  `mapstructure:"kind"`
+	SlotID         int     `mapstructure:"slot"`
+	BannerID       int     `mapstructure:"banner"`
+	Drop           float64 `mapstructure:"drop"`
+	MinImpressions int64   `mapstructure:"min impressions"`
+	MinFailures    int     `mapstructure:"min failures"`
+}
+
 func NewConfig() (Config, error) {
 	c := Config{}
 	c.DataBase.MigrationsDir = defaultEnvString
@@ -94,5 +167,12 @@ func NewConfig() (Config, error) {
 		}
 	}
 
+	if c.Tokens.Secret == "" {
+		c.Tokens.Secret, _ = viper.Get("tokensecret").(string)
+	}
+	if !c.Tokens.AllowRawIDs {
+		c.Tokens.AllowRawIDs = viper.GetBool("allowrawids")
+	}
+
 	return c, nil
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+)
+
+var (
+	purgeDays      int
+	purgeEventDays int
+)
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently remove deleted banners, slots and groups, expired impressions and old events",
+	Long: `Permanently remove banners, slots and groups which were deleted
+more than the given number of days ago together with their relations
+and click statistics. Items deleted more recently can still be restored.
+The impressions whose tokens have expired are removed too, as well as
+the events of the reports older than the given number of days.`,
+	Run: purge,
+}
+
+func init() {
+	purgeCmd.Flags().IntVar(&purgeDays, "days", 30, "purge items deleted more than this number of days ago")
+	purgeCmd.Flags().IntVar(&purgeEventDays, "event-days", 90, "purge report events older than this number of days, 0 keeps them all")
+
+	rootCmd.AddCommand(purgeCmd)
+}
+
+func purge(_ *cobra.Command, _ []string) {
+	if purgeDays < 0 || purgeEventDays < 0 {
+		log.Fatal("the number of days can't be negative")
+	}
+
+	withRepository(func(repo repository.BannersRepository, config Config) {
+		deletedBefore := time.Now().AddDate(0, 0, -purgeDays)
+		res, err := repo.Purge(context.Background(), deletedBefore)
+		if err != nil {
+			log.Error("failed to purge deleted items: ", err.Error())
+			return
+		}
+
+		log.Infof("purged %d banners, %d slots and %d groups deleted before %s",
+			res.Banners, res.Slots, res.Groups, deletedBefore.Format(time.RFC3339))
+
+		// a show can't be repeated and a click can't be counted once the token has expired
+		if config.Tokens.TTL > 0 {
+			shownBefore := time.Now().Add(-config.Tokens.TTL)
+			n, err := repo.PurgeImpressions(context.Background(), shownBefore)
+			if err != nil {
+				log.Error("failed to purge expired impressions: ", err.Error())
+			} else {
+				log.Infof("purged %d impressions shown before %s", n, shownBefore.Format(time.RFC3339))
+			}
+		}
+
+		if purgeEventDays > 0 {
+			createdBefore := time.Now().AddDate(0, 0, -purgeEventDays)
+			n, err := repo.PurgeEvents(context.Background(), createdBefore)
+			if err != nil {
+				log.Error("failed to purge old events: ", err.Error())
+				return
+			}
+
+			log.Infof("purged %d events created before %s", n, createdBefore.Format(time.RFC3339))
+		}
+	})
+}
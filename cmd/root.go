@@ -1,19 +1,34 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/signal"
 	"path"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/bubblesupreme/banner_rotation/internal/alert"
 	"github.com/bubblesupreme/banner_rotation/internal/app"
-	"github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit/thompson"
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+	cappingmemorystore "github.com/bubblesupreme/banner_rotation/internal/capping/memory_store"
+	cappingsqlstore "github.com/bubblesupreme/banner_rotation/internal/capping/sql_store"
+	"github.com/bubblesupreme/banner_rotation/internal/fraud"
+	"github.com/bubblesupreme/banner_rotation/internal/idempotency"
+	memorystore "github.com/bubblesupreme/banner_rotation/internal/idempotency/memory_store"
+	sqlstore "github.com/bubblesupreme/banner_rotation/internal/idempotency/sql_store"
+	multiarmedbandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
+	"github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit/factory"
 	rabbitmqproducer "github.com/bubblesupreme/banner_rotation/internal/producer/rabbitmq_producer"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
 	"github.com/bubblesupreme/banner_rotation/internal/server"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
+	"github.com/bubblesupreme/banner_rotation/internal/webhook"
 
 	"github.com/NeowayLabs/wabbit/amqp"
 
@@ -92,6 +107,8 @@ func initConfig() {
 	viper.BindEnv("dbname", "POSTGRES_DB")
 	viper.BindEnv("dbpassword", "POSTGRES_PASSWORD")
 	viper.BindEnv("migrations", "MIGRATIONS_DIRECTORY")
+	viper.BindEnv("tokensecret", "IMPRESSION_TOKEN_SECRET")
+	viper.BindEnv("allowrawids", "IMPRESSION_ALLOW_RAW_IDS")
 
 	readConfig()
 
@@ -129,16 +146,10 @@ func run(_ *cobra.Command, args []string) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	db, err := sqlx.Connect(driver, fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s sslmode=disable",
-		config.DataBase.Host, config.DataBase.Port, config.DataBase.Login, config.DataBase.DBName, config.DataBase.Password))
+	db, err := connectDatabase(config)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"host":     config.DataBase.Host,
-			"port":     config.DataBase.Port,
-			"login":    config.DataBase.Login,
-			"dbname":   config.DataBase.DBName,
-			"password": config.DataBase.Password,
-		}).Fatal("failed to connect to database :", err.Error())
+		log.Error(err.Error())
+		return
 	}
 	defer func() {
 		if err := db.Close(); err != nil {
@@ -146,17 +157,11 @@ func run(_ *cobra.Command, args []string) {
 		}
 	}()
 
-	if err := goose.Up(db.DB, config.DataBase.MigrationsDir); err != nil {
-		log.Error("failed to migrate: ", err.Error())
-		return
-	}
-
-	bandit, err := thompson.NewThompsonBandit(minEvents)
+	repo, err := newRepository(db)
 	if err != nil {
-		log.Error("failed to initialize multi-armed bandit: ", err.Error())
+		log.Error(err.Error())
 		return
 	}
-	repo := sqlrepository.NewSQLRepository(db.DB, bandit)
 
 	rabbitConnection, err := amqp.Dial(config.Rabbit.URL)
 	if err != nil {
@@ -174,10 +179,33 @@ func run(_ *cobra.Command, args []string) {
 		return
 	}
 
-	a := app.NewBannersApp(repo, producer)
-	s := server.NewServer(a, config.Server.Port)
+	opts, err := appOptions(config, db)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	evaluator, err := newAlertEvaluator(config.Alerts, repo)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
 
 	wg := sync.WaitGroup{}
+	if evaluator == nil {
+		log.Warning("alert webhook is not set, alerts are disabled")
+	} else {
+		opts = append(opts, app.WithAlerts(evaluator))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			evaluator.Run(ctx)
+		}()
+	}
+
+	a := app.NewBannersApp(repo, producer, opts...)
+	s := server.NewServer(a, config.Server.Port)
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -210,6 +238,213 @@ func run(_ *cobra.Command, args []string) {
 	wg.Wait()
 }
 
+// connectDatabase opens the database described in the config and applies the migrations.
+func connectDatabase(config Config) (*sqlx.DB, error) {
+	db, err := sqlx.Connect(driver, fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s sslmode=disable",
+		config.DataBase.Host, config.DataBase.Port, config.DataBase.Login, config.DataBase.DBName, config.DataBase.Password))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"host":     config.DataBase.Host,
+			"port":     config.DataBase.Port,
+			"login":    config.DataBase.Login,
+			"dbname":   config.DataBase.DBName,
+			"password": config.DataBase.Password,
+		}).Error("failed to connect to database :", err.Error())
+		return nil, err
+	}
+
+	if err := goose.Up(db.DB, config.DataBase.MigrationsDir); err != nil {
+		if err := db.Close(); err != nil {
+			log.Error("failed to close database: ", err.Error())
+		}
+		return nil, fmt.Errorf("failed to migrate: %w", err)
+	}
+
+	return db, nil
+}
+
+func appOptions(config Config, db *sqlx.DB) ([]app.Option, error) {
+	opts := []app.Option{app.WithMinEvents(minEvents)}
+
+	switch {
+	case config.Tokens.Secret != "":
+		signer, err := token.NewSigner([]byte(config.Tokens.Secret), config.Tokens.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize impression tokens: %w", err)
+		}
+		opts = append(opts, app.WithImpressionTokens(signer))
+	case !config.Tokens.AllowRawIDs:
+		return nil, errors.New("impression token secret is not set, define environment variable 'IMPRESSION_TOKEN_SECRET' " +
+			"or opt in to shows and clicks by raw ids with 'IMPRESSION_ALLOW_RAW_IDS' or \"tokens\":\"allow raw ids\"")
+	}
+	if config.Tokens.AllowRawIDs {
+		log.Warning("shows and clicks without impression tokens are accepted by raw ids")
+		opts = append(opts, app.WithRawIDs())
+	}
+
+	store, err := newIdempotencyStore(config.Idempotency, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize idempotency store: %w", err)
+	}
+	if store == nil {
+		log.Warning("idempotency storage is not set, repeated clicks and shows are counted again")
+	} else {
+		opts = append(opts, app.WithIdempotency(store))
+	}
+
+	cappingStore, err := newCappingStore(config.Capping, db)
+	if err != nil {
+		return nil, err
+	}
+	if cappingStore == nil {
+		log.Warning("capping storage is not set, banner frequency caps are ignored")
+	} else {
+		opts = append(opts, app.WithFrequencyCapping(cappingStore))
+	}
+
+	if config.Holdout.Percent < 0 || config.Holdout.Percent > 100 {
+		return nil, fmt.Errorf("holdout percent %v must be between 0 and 100", config.Holdout.Percent)
+	}
+	if config.Holdout.Percent > 0 {
+		opts = append(opts, app.WithHoldout(config.Holdout.Percent))
+	}
+
+	proxies, err := parseTrustedProxies(config.Fraud.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	if len(proxies) > 0 {
+		opts = append(opts, app.WithTrustedProxies(proxies))
+	}
+
+	fraudOpt, err := fraudDetection(config.Fraud)
+	if err != nil {
+		return nil, err
+	}
+	if fraudOpt == nil {
+		log.Warning("fraud detection is disabled, all clicks are counted")
+	} else {
+		opts = append(opts, fraudOpt)
+	}
+
+	return opts, nil
+}
+
+func newIdempotencyStore(config IdempotencyConf, db *sqlx.DB) (idempotency.Store, error) {
+	switch config.Storage {
+	case "":
+		return nil, nil
+	case "memory":
+		return memorystore.NewStore(config.Window, config.Size)
+	case "postgres":
+		return sqlstore.NewStore(db.DB, config.Window)
+	}
+
+	return nil, fmt.Errorf("unknown idempotency storage %q", config.Storage)
+}
+
+func fraudDetection(config FraudConf) (app.Option, error) {
+	if config.Mode == "" {
+		return nil, nil
+	}
+	if config.Mode != "flag" && config.Mode != "drop" {
+		return nil, fmt.Errorf("unknown fraud detection mode %q", config.Mode)
+	}
+
+	detector, err := fraud.NewDetector(fraud.Config{
+		Window:         config.Window,
+		MaxClicks:      config.MaxClicks,
+		MinClickDelay:  config.MinClickDelay,
+		SpikeMinClicks: config.SpikeMinClicks,
+		SpikeCTR:       config.SpikeCTR,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize fraud detection: %w", err)
+	}
+
+	return app.WithFraudDetection(detector, config.Mode == "drop"), nil
+}
+
+// parseTrustedProxies parses the proxy addresses, a single address is a range of its own.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy range %q: %w", proxy, err)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+func newAlertEvaluator(config AlertsConf, repo repository.BannersRepository) (*alert.Evaluator, error) {
+	if config.WebhookURL == "" {
+		return nil, nil
+	}
+
+	sender, err := webhook.NewSender(config.WebhookURL, []byte(config.Secret), config.Retries, config.Backoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize alert webhook: %w", err)
+	}
+
+	rules := make([]alert.Rule, 0, len(config.Rules))
+	for _, r := range config.Rules {
+		rules = append(rules, alert.Rule{
+			Name:           r.Name,
+			Kind:           alert.Kind(r.Kind),
+			SlotID:         r.SlotID,
+			BannerID:       r.BannerID,
+			Drop:           r.Drop,
+			MinImpressions: r.MinImpressions,
+			MinFailures:    r.MinFailures,
+		})
+	}
+
+	evaluator, err := alert.NewEvaluator(rules, repo, sender, config.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize alerts: %w", err)
+	}
+
+	return evaluator, nil
+}
+
+func newCappingStore(config CappingConf, db *sqlx.DB) (capping.Store, error) {
+	switch config.Storage {
+	case "":
+		return nil, nil
+	case "memory":
+		return cappingmemorystore.NewStore(), nil
+	case "postgres":
+		return cappingsqlstore.NewStore(db.DB), nil
+	}
+
+	return nil, fmt.Errorf("unknown capping storage %q", config.Storage)
+}
+
+func newRepository(db *sqlx.DB) (repository.BannersRepository, error) {
+	bandit, err := factory.New(multiarmedbandit.Config{Algorithm: multiarmedbandit.AlgorithmThompson, MinEvents: minEvents})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize multi-armed bandit: %w", err)
+	}
+
+	return sqlrepository.NewSQLRepository(db.DB, bandit, factory.New), nil
+}
+
 func configureLogger(c Config) (*os.File, error) {
 	l, err := log.ParseLevel(c.Logger.Level)
 	if err != nil {
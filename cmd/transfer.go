@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"os/user"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/transfer"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+)
+
+var (
+	transferFormat string
+	transferFile   string
+	importDryRun   bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export banners, slots, groups and relations",
+	Long: `Export the whole rotation configuration as JSON or CSV
+to the given file or to the standard output.`,
+	Run: exportConfiguration,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import banners, slots, groups and relations",
+	Long: `Import the rotation configuration as JSON or CSV from the given file
+or from the standard input. The import runs in a single transaction,
+in the dry run mode it is rolled back and only the report is printed.`,
+	Run: importConfiguration,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{exportCmd, importCmd} {
+		c.Flags().StringVar(&transferFormat, "format", string(transfer.FormatJSON), "configuration format, json or csv")
+		c.Flags().StringVarP(&transferFile, "file", "f", "", "configuration file, the standard stream is used when it's empty")
+		rootCmd.AddCommand(c)
+	}
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "report what would change without applying it")
+}
+
+func exportConfiguration(_ *cobra.Command, _ []string) {
+	format, err := transfer.ParseFormat(transferFormat)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	withRepository(func(repo repository.BannersRepository, _ Config) {
+		config, err := repo.Export(context.Background())
+		if err != nil {
+			log.Error("failed to export configuration: ", err.Error())
+			return
+		}
+
+		out := io.Writer(os.Stdout)
+		if transferFile != "" {
+			f, err := os.Create(transferFile)
+			if err != nil {
+				log.Error("failed to create configuration file: ", err.Error())
+				return
+			}
+			defer func() {
+				if err := f.Close(); err != nil {
+					log.Error("failed to close configuration file: ", err.Error())
+				}
+			}()
+			out = f
+		}
+
+		if err := transfer.Encode(out, config, format); err != nil {
+			log.Error("failed to write configuration: ", err.Error())
+		}
+	})
+}
+
+func importConfiguration(_ *cobra.Command, _ []string) {
+	format, err := transfer.ParseFormat(transferFormat)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	in := io.Reader(os.Stdin)
+	if transferFile != "" {
+		f, err := os.Open(transferFile)
+		if err != nil {
+			log.Fatal("failed to open configuration file: ", err.Error())
+		}
+		defer f.Close()
+		in = f
+	}
+
+	config, err := transfer.Decode(in, format)
+	if err != nil {
+		log.Fatal("failed to read configuration: ", err.Error())
+	}
+
+	withRepository(func(repo repository.BannersRepository, _ Config) {
+		ctx := context.Background()
+		report, err := repo.Import(ctx, config, importDryRun)
+		if err != nil {
+			log.Error("failed to import configuration: ", err.Error())
+			return
+		}
+
+		if !importDryRun {
+			after, err := json.Marshal(&report)
+			if err == nil {
+				err = repo.AddAuditRecord(ctx, repository.AuditRecord{
+					Actor:  cliActor(),
+					Action: repository.AuditImport,
+					After:  after,
+				})
+			}
+			if err != nil {
+				log.Error("failed to write audit record: ", err.Error())
+			}
+		}
+
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if err := e.Encode(&report); err != nil {
+			log.Error("failed to print import report: ", err.Error())
+		}
+	})
+}
+
+// withRepository connects to the database from the config and passes the repository to fn.
+func withRepository(fn func(repo repository.BannersRepository, config Config)) {
+	config, err := NewConfig()
+	if err != nil {
+		log.Fatal("failed to read config: ", err.Error())
+	}
+
+	db, err := connectDatabase(config)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Fatal("failed to close database: ", err.Error())
+		}
+	}()
+
+	repo, err := newRepository(db)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	fn(repo, config)
+}
+
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+
+	return "cli"
+}
@@ -2,13 +2,20 @@ package apitest
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bubblesupreme/banner_rotation/internal/analytics"
 	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -197,3 +204,1198 @@ func TestBanners(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, b.ID == b1.ID || b.ID == b2.ID || b.ID == b3.ID)
 }
+
+// sendJSON sends reqData to the service and returns the response body of a successful request.
+func sendJSON(method, path string, reqData interface{}) ([]byte, error) {
+	req, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequest(method, "http://127.0.0.1:8088"+path, bytes.NewReader(req)) //nolint:noctx
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s %s returned non success status code (%d): %s", method, path, resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
+
+func setBannerStatus(action string, bannerID int) (*repository.Banner, error) {
+	body, err := sendJSON(http.MethodPost, "/banner/"+action, map[string]int{"banner": bannerID})
+	if err != nil {
+		return nil, err
+	}
+
+	b := repository.Banner{}
+	if err := json.Unmarshal(body, &b); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func getAllBanners() ([]repository.Banner, error) {
+	resp, err := http.Get("http://127.0.0.1:8088/all_banners") //nolint:noctx
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get all banners")
+	}
+
+	banners := make([]repository.Banner, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&banners); err != nil {
+		return nil, err
+	}
+
+	return banners, nil
+}
+
+func containsBanner(banners []repository.Banner, bannerID int) bool {
+	for _, b := range banners {
+		if b.ID == bannerID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestBannerLifecycle(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+
+	b, err := addBanner("https://mybanner.com/lifecycle", "lifecycle")
+	assert.NoError(t, err)
+	assert.Equal(t, repository.BannerActive, b.Status)
+
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	paused, err := setBannerStatus("pause", b.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, repository.BannerPaused, paused.Status)
+
+	_, err = getBanner(s.ID, g.ID)
+	assert.Error(t, err)
+
+	_, err = setBannerStatus("pause", b.ID)
+	assert.Error(t, err)
+
+	resumed, err := setBannerStatus("resume", b.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, repository.BannerActive, resumed.Status)
+
+	got, err := getBanner(s.ID, g.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, b.ID, got.ID)
+
+	archived, err := setBannerStatus("archive", b.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, repository.BannerArchived, archived.Status)
+
+	_, err = setBannerStatus("resume", b.ID)
+	assert.Error(t, err)
+}
+
+func TestSoftDelete(t *testing.T) {
+	b, err := addBanner("https://mybanner.com/trash", "trash")
+	assert.NoError(t, err)
+
+	_, err = sendJSON(http.MethodDelete, "/banner", map[string]int{"banner": b.ID})
+	assert.NoError(t, err)
+
+	banners, err := getAllBanners()
+	assert.NoError(t, err)
+	assert.False(t, containsBanner(banners, b.ID))
+
+	_, err = sendJSON(http.MethodPost, "/banner/restore", map[string]int{"banner": b.ID})
+	assert.NoError(t, err)
+
+	banners, err = getAllBanners()
+	assert.NoError(t, err)
+	assert.True(t, containsBanner(banners, b.ID))
+
+	_, err = sendJSON(http.MethodPost, "/banner/restore", map[string]int{"banner": b.ID})
+	assert.Error(t, err)
+}
+
+func updateBanner(bannerID int, ifMatch string, reqData map[string]interface{}) (*http.Response, error) {
+	reqData["banner"] = bannerID
+	req, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequest(http.MethodPatch, "http://127.0.0.1:8088/banner", bytes.NewReader(req)) //nolint:noctx
+	if err != nil {
+		return nil, err
+	}
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+
+	return http.DefaultClient.Do(r)
+}
+
+func TestUpdateBanner(t *testing.T) {
+	b, err := addBanner("https://mybanner.com/update", "update")
+	assert.NoError(t, err)
+
+	resp, err := updateBanner(b.ID, fmt.Sprintf("%q", fmt.Sprint(b.Version)), map[string]interface{}{"description": "updated"})
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	updated := repository.Banner{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&updated))
+	assert.Equal(t, "updated", updated.Description)
+	assert.Equal(t, b.URL, updated.URL)
+	assert.Equal(t, b.Version+1, updated.Version)
+	assert.Equal(t, fmt.Sprintf("%q", fmt.Sprint(updated.Version)), resp.Header.Get("ETag"))
+
+	stale, err := updateBanner(b.ID, fmt.Sprintf("%q", fmt.Sprint(b.Version)), map[string]interface{}{"description": "stale"})
+	assert.NoError(t, err)
+	defer stale.Body.Close()
+	assert.Equal(t, http.StatusPreconditionFailed, stale.StatusCode)
+}
+
+func TestAuditLog(t *testing.T) {
+	actor := fmt.Sprintf("tester-%d", time.Now().UnixNano())
+	url := "https://mybanner.com/audit/" + actor
+
+	// the banner exists the second time, so only the first request is audited
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8088/banner", //nolint:noctx
+			strings.NewReader(`{"url": "`+url+`", "description": "audit"}`))
+		assert.NoError(t, err)
+		req.Header.Set("X-Actor", actor)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// removing a missing banner succeeds without changing anything
+	_, err := sendJSON(http.MethodDelete, "/banner", map[string]int{"banner": 1 << 30})
+	assert.NoError(t, err)
+	_, err = sendJSON(http.MethodDelete, "/group", map[string]int{"group": 1 << 30})
+	assert.NoError(t, err)
+
+	resp, err := http.Get("http://127.0.0.1:8088/audit?action=add_banner&actor=" + actor) //nolint:noctx
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	records := make([]repository.AuditRecord, 0)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&records))
+	assert.Len(t, records, 1)
+	assert.Equal(t, actor, records[0].Actor)
+	assert.Empty(t, records[0].Before)
+
+	b := repository.Banner{}
+	assert.NoError(t, json.Unmarshal(records[0].After, &b))
+	assert.Equal(t, url, b.URL)
+}
+
+func TestImportExport(t *testing.T) {
+	config := repository.Configuration{
+		Banners:   []repository.Banner{{ID: 1, URL: "https://mybanner.com/import", Description: "import"}},
+		Slots:     []repository.Slot{{ID: -1}},
+		Groups:    []repository.Group{{ID: 1, Description: "group1"}},
+		Relations: []repository.Relation{{SlotID: -1, BannerID: 1}},
+	}
+
+	body, err := sendJSON(http.MethodPost, "/import?dry_run=true", config)
+	assert.NoError(t, err)
+	report := repository.ImportReport{}
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 1, report.Slots.Created)
+	assert.Equal(t, 1, report.Relations.Created)
+
+	body, err = sendJSON(http.MethodPost, "/import", config)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.False(t, report.DryRun)
+	assert.Equal(t, 1, report.Slots.Created)
+
+	resp, err := http.Get("http://127.0.0.1:8088/export") //nolint:noctx
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	exported := repository.Configuration{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&exported))
+	found := false
+	for _, b := range exported.Banners {
+		found = found || b.URL == "https://mybanner.com/import"
+	}
+	assert.True(t, found)
+
+	csvResp, err := http.Get("http://127.0.0.1:8088/export?format=csv") //nolint:noctx
+	assert.NoError(t, err)
+	defer csvResp.Body.Close()
+	assert.Equal(t, "text/csv", csvResp.Header.Get("Content-Type"))
+}
+
+func TestStatisticSnapshot(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	b, err := addBanner("https://mybanner.com/snapshot", "snapshot")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	_, err = sendJSON(http.MethodPost, "/show", map[string]int{"slot": s.ID, "banner": b.ID, "group": g.ID})
+	assert.NoError(t, err)
+
+	name := fmt.Sprintf("relaunch-%d", time.Now().UnixNano())
+	body, err := sendJSON(http.MethodPost, "/statistic/reset", map[string]interface{}{"name": name, "comment": "relaunch", "slot": s.ID})
+	assert.NoError(t, err)
+	snapshot := repository.Snapshot{}
+	assert.NoError(t, json.Unmarshal(body, &snapshot))
+	assert.Equal(t, name, snapshot.Name)
+	assert.Equal(t, s.ID, snapshot.Scope.SlotID)
+	assert.True(t, snapshot.Relations > 0)
+
+	_, err = sendJSON(http.MethodPost, "/snapshot", map[string]interface{}{"name": name, "slot": s.ID})
+	assert.Error(t, err)
+
+	_, err = sendJSON(http.MethodPost, "/snapshot/restore", map[string]int{"snapshot": snapshot.ID})
+	assert.NoError(t, err)
+}
+
+func TestGetBannerCountShow(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	b, err := addBanner("https://mybanner.com/count_show", "count show")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	body, err := sendJSON(http.MethodPost, "/get_banner", map[string]interface{}{"slot": s.ID, "group": g.ID, "count_show": true})
+	assert.NoError(t, err)
+	resp := struct {
+		repository.Banner
+		Shown bool `json:"shown"`
+	}{}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, b.ID, resp.ID)
+	assert.True(t, resp.Shown)
+
+	body, err = sendJSON(http.MethodPost, "/get_banner", map[string]interface{}{"slot": s.ID, "group": g.ID})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), `"shown"`)
+}
+
+func TestIdempotentShow(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	b, err := addBanner("https://mybanner.com/idempotent", "idempotent")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	req, err := json.Marshal(map[string]interface{}{"slot": s.ID, "banner": b.ID, "group": g.ID})
+	assert.NoError(t, err)
+	key := fmt.Sprintf("show-%d", time.Now().UnixNano())
+
+	replayed := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		r, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8088/show", bytes.NewReader(req)) //nolint:noctx
+		assert.NoError(t, err)
+		r.Header.Set("Idempotency-Key", key)
+
+		resp, err := http.DefaultClient.Do(r)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		replayed = append(replayed, resp.Header.Get("Idempotent-Replayed"))
+		resp.Body.Close()
+	}
+	assert.Equal(t, []string{"", "true"}, replayed)
+}
+
+// postStatus posts reqData to the service and returns the status code of the response.
+func postStatus(path string, reqData interface{}) (int, error) {
+	req, err := json.Marshal(reqData)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := http.Post("http://127.0.0.1:8088"+path, "application/json", bytes.NewReader(req)) //nolint:noctx
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func TestImpressionTokens(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	b, err := addBanner("https://mybanner.com/token", "token")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	body, err := sendJSON(http.MethodPost, "/get_banner", map[string]int{"slot": s.ID, "group": g.ID})
+	assert.NoError(t, err)
+	resp := struct {
+		Token string `json:"token"`
+	}{}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	assert.NotEmpty(t, resp.Token)
+
+	event := map[string]string{"token": resp.Token}
+	_, err = sendJSON(http.MethodPost, "/show", event)
+	assert.NoError(t, err)
+	_, err = sendJSON(http.MethodPost, "/click", event)
+	assert.NoError(t, err)
+
+	for _, path := range []string{"/show", "/click"} {
+		status, err := postStatus(path, event)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, status, path)
+	}
+
+	status, err := postStatus("/click", map[string]string{"token": resp.Token + "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+// tokenSecret is the impression token secret of the service from .test-env.
+const tokenSecret = "integration-tests-secret"
+
+// signImpression issues the impression token the way the service does, so that
+// the tests can make tokens the service wouldn't issue, e.g. expired ones.
+func signImpression(imp token.Impression) (string, error) {
+	payload, err := json.Marshal(&imp)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(tokenSecret))
+	mac.Write([]byte(encoded))
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// slotEvents sums the impressions and clicks of the slot over the last hour.
+func slotEvents(slotID int) (int64, int64, error) {
+	report := struct {
+		Series []struct {
+			SlotID int `json:"slot"`
+			Points []struct {
+				Impressions int64 `json:"impressions"`
+				Clicks      int64 `json:"clicks"`
+			} `json:"points"`
+		} `json:"series"`
+	}{}
+	from := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	body, err := sendJSON(http.MethodGet, "/reports?granularity=hour&group_by=slot&from="+from, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		return 0, 0, err
+	}
+
+	impressions, clicks := int64(0), int64(0)
+	for _, series := range report.Series {
+		if series.SlotID != slotID {
+			continue
+		}
+		for _, p := range series.Points {
+			impressions += p.Impressions
+			clicks += p.Clicks
+		}
+	}
+
+	return impressions, clicks, nil
+}
+
+func TestTracking(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	b, err := addBanner("https://mybanner.com/tracking", "tracking")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	body, err := sendJSON(http.MethodPost, "/get_banner", map[string]int{"slot": s.ID, "group": g.ID})
+	assert.NoError(t, err)
+	resp := struct {
+		ClickURL string `json:"click_url"`
+		PixelURL string `json:"pixel_url"`
+	}{}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+
+	// the pixel is counted once however many times it is loaded
+	for i := 0; i < 2; i++ {
+		r, err := http.Get("http://127.0.0.1:8088" + resp.PixelURL) //nolint:noctx
+		assert.NoError(t, err)
+		pixel, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, r.StatusCode)
+		assert.Equal(t, "image/gif", r.Header.Get("Content-Type"))
+		assert.Equal(t, "no-store", r.Header.Get("Cache-Control"))
+		assert.True(t, bytes.HasPrefix(pixel, []byte("GIF89a")))
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	redirect := func(path string) *http.Response {
+		r, err := client.Get("http://127.0.0.1:8088" + path) //nolint:noctx
+		assert.NoError(t, err)
+		r.Body.Close()
+
+		return r
+	}
+
+	r := redirect(resp.ClickURL)
+	assert.Equal(t, http.StatusFound, r.StatusCode)
+	assert.Equal(t, b.URL, r.Header.Get("Location"))
+
+	expired, err := signImpression(token.Impression{
+		ID: "expired", SlotID: s.ID, BannerID: b.ID, GroupID: g.ID, ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	})
+	assert.NoError(t, err)
+	r = redirect("/c/" + expired)
+	assert.Equal(t, http.StatusFound, r.StatusCode)
+	assert.Equal(t, b.URL, r.Header.Get("Location"))
+
+	impressions, clicks, err := slotEvents(s.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), impressions)
+	assert.Equal(t, int64(1), clicks)
+
+	forged := strings.TrimPrefix(resp.ClickURL, "/c/") + "x"
+	r, err = client.Get("http://127.0.0.1:8088/c/" + forged) //nolint:noctx
+	assert.NoError(t, err)
+	defer r.Body.Close()
+	assert.Equal(t, http.StatusForbidden, r.StatusCode)
+	assert.Empty(t, r.Header.Get("Location"))
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	errResp := struct {
+		Error struct {
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&errResp))
+	assert.Equal(t, http.StatusForbidden, errResp.Error.Status)
+	assert.NotEmpty(t, errResp.Error.Message)
+}
+
+func TestEventBatch(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	b, err := addBanner("https://mybanner.com/batch", "batch")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	body, err := sendJSON(http.MethodPost, "/events", []map[string]interface{}{
+		{"type": "show", "slot": s.ID, "banner": b.ID, "group": g.ID},
+		{"type": "click", "slot": s.ID, "banner": b.ID, "group": g.ID},
+		{"type": "conversion", "slot": s.ID, "banner": b.ID, "group": g.ID},
+		{"type": "show", "slot": s.ID, "banner": b.ID + 1000, "group": g.ID},
+	})
+	assert.NoError(t, err)
+
+	results := make([]struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}, 0)
+	assert.NoError(t, json.Unmarshal(body, &results))
+	assert.Len(t, results, 4)
+	assert.Equal(t, http.StatusOK, results[0].Status)
+	assert.Equal(t, http.StatusOK, results[1].Status)
+	assert.Equal(t, http.StatusBadRequest, results[2].Status)
+	assert.Equal(t, http.StatusNotFound, results[3].Status)
+}
+
+func TestRetriedEventBatch(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	b, err := addBanner("https://mybanner.com/retried-batch", "retried batch")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	eventID := fmt.Sprintf("batch-%d", time.Now().UnixNano())
+	batch := []map[string]interface{}{
+		{"type": "show", "slot": s.ID, "banner": b.ID, "group": g.ID, "event_id": eventID},
+	}
+	for i := 0; i < 2; i++ {
+		body, err := sendJSON(http.MethodPost, "/events", batch)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[{"status": 200}]`, string(body))
+	}
+
+	// the same event sent on its own is a repeat as well
+	_, err = sendJSON(http.MethodPost, "/show", map[string]interface{}{
+		"slot": s.ID, "banner": b.ID, "group": g.ID, "event_id": eventID,
+	})
+	assert.NoError(t, err)
+
+	impressions, _, err := slotEvents(s.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), impressions)
+}
+
+func TestPageBanners(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+
+	suffix := time.Now().UnixNano()
+	banners := make([]int, 0, 3)
+	for i, label := range []string{"bank", "bank", "telecom"} {
+		body, err := sendJSON(http.MethodPost, "/banner", map[string]interface{}{
+			"url":         fmt.Sprintf("https://mybanner.com/page/%d/%d", suffix, i),
+			"description": "page",
+			"labels":      []string{label},
+		})
+		assert.NoError(t, err)
+		b := repository.Banner{}
+		assert.NoError(t, json.Unmarshal(body, &b))
+		banners = append(banners, b.ID)
+	}
+
+	slots := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		s, err := addSlot()
+		assert.NoError(t, err)
+		for _, b := range banners {
+			assert.NoError(t, addRelation(s.ID, b))
+		}
+		slots = append(slots, s.ID)
+	}
+
+	body, err := sendJSON(http.MethodPost, "/get_page_banners", map[string]interface{}{"slots": slots, "group": g.ID})
+	assert.NoError(t, err)
+	page := make([]struct {
+		SlotID int                `json:"slot"`
+		Banner *repository.Banner `json:"banner"`
+	}, 0)
+	assert.NoError(t, json.Unmarshal(body, &page))
+	assert.Len(t, page, 3)
+
+	// only one of the two bank banners fits on the page, so one slot stays empty
+	shown := make(map[int]bool)
+	empty := 0
+	for i, s := range page {
+		assert.Equal(t, slots[i], s.SlotID)
+		if s.Banner == nil {
+			empty++
+			continue
+		}
+		assert.False(t, shown[s.Banner.ID])
+		shown[s.Banner.ID] = true
+	}
+	assert.Equal(t, 1, empty)
+	assert.True(t, shown[banners[2]])
+
+	_, err = sendJSON(http.MethodPost, "/get_page_banners", map[string]interface{}{"slots": []int{slots[0], slots[0]}, "group": g.ID})
+	assert.Error(t, err)
+
+	// the page fails on the unknown slot, so the show of the first slot isn't counted
+	_, err = sendJSON(http.MethodPost, "/get_page_banners", map[string]interface{}{
+		"slots": []int{slots[0], 1 << 30}, "group": g.ID, "count_show": true,
+	})
+	assert.Error(t, err)
+	impressions, _, err := slotEvents(slots[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), impressions)
+
+	_, err = sendJSON(http.MethodPost, "/get_page_banners", map[string]interface{}{"slots": slots[:1], "group": g.ID, "count_show": true})
+	assert.NoError(t, err)
+	impressions, _, err = slotEvents(slots[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), impressions)
+}
+
+func TestFrequencyCap(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	body, err := sendJSON(http.MethodPost, "/banner", map[string]interface{}{
+		"url":           fmt.Sprintf("https://mybanner.com/capped/%d", time.Now().UnixNano()),
+		"description":   "capped",
+		"frequency_cap": map[string]interface{}{"limit": 1, "period": "hour"},
+	})
+	assert.NoError(t, err)
+	b := repository.Banner{}
+	assert.NoError(t, json.Unmarshal(body, &b))
+	assert.Equal(t, &repository.FrequencyCap{Limit: 1, Period: "hour"}, b.FrequencyCap)
+
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	user := fmt.Sprintf("user-%d", time.Now().UnixNano())
+	_, err = sendJSON(http.MethodPost, "/get_banner", map[string]interface{}{"slot": s.ID, "group": g.ID, "user_id": user, "count_show": true})
+	assert.NoError(t, err)
+
+	// the only banner of the slot has reached its cap for the user, but not for others
+	_, err = sendJSON(http.MethodPost, "/get_banner", map[string]interface{}{"slot": s.ID, "group": g.ID, "user_id": user})
+	assert.Error(t, err)
+	_, err = sendJSON(http.MethodPost, "/get_banner", map[string]interface{}{"slot": s.ID, "group": g.ID, "user_id": user + "-other"})
+	assert.NoError(t, err)
+
+	_, err = sendJSON(http.MethodPost, "/banner", map[string]interface{}{
+		"url":           "https://mybanner.com/capped",
+		"description":   "invalid cap",
+		"frequency_cap": map[string]interface{}{"limit": 1, "period": "week"},
+	})
+	assert.Error(t, err)
+}
+
+func TestGroupSegments(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	seniors, err := addGroup(fmt.Sprintf("seniors %d", suffix))
+	assert.NoError(t, err)
+	others, err := addGroup(fmt.Sprintf("others %d", suffix))
+	assert.NoError(t, err)
+
+	country := fmt.Sprintf("country-%d", suffix)
+	_, err = sendJSON(http.MethodPatch, "/group", map[string]interface{}{
+		"group": seniors.ID,
+		"segment": map[string]interface{}{
+			"priority": 100,
+			"conditions": []map[string]interface{}{
+				{"attribute": "age", "op": "gte", "value": "65"},
+				{"attribute": "country", "op": "eq", "value": country},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	_, err = sendJSON(http.MethodPatch, "/group", map[string]interface{}{"group": others.ID, "fallback": true})
+	assert.NoError(t, err)
+
+	resolved := struct {
+		Group    repository.Group `json:"group"`
+		Fallback bool             `json:"fallback"`
+	}{}
+	body, err := sendJSON(http.MethodPost, "/group/resolve", map[string]interface{}{"attributes": map[string]string{"age": "70", "country": country}})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &resolved))
+	assert.Equal(t, seniors.ID, resolved.Group.ID)
+	assert.False(t, resolved.Fallback)
+
+	body, err = sendJSON(http.MethodPost, "/group/resolve", map[string]interface{}{"attributes": map[string]string{"age": "30", "country": country}})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &resolved))
+	assert.Equal(t, others.ID, resolved.Group.ID)
+	assert.True(t, resolved.Fallback)
+
+	b, err := addBanner("https://mybanner.com/seniors", "seniors")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+	_, err = sendJSON(http.MethodPost, "/get_banner", map[string]interface{}{"slot": s.ID, "attributes": map[string]string{"age": "70", "country": country}})
+	assert.NoError(t, err)
+
+	_, err = sendJSON(http.MethodPatch, "/group", map[string]interface{}{
+		"group":   seniors.ID,
+		"segment": map[string]interface{}{"conditions": []map[string]interface{}{{"attribute": "age", "op": "older"}}},
+	})
+	assert.Error(t, err)
+}
+
+func TestRelationSchedule(t *testing.T) {
+	g, err := addGroup(fmt.Sprintf("schedule %d", time.Now().UnixNano()))
+	assert.NoError(t, err)
+	weekdays, err := addBanner("https://mybanner.com/weekdays", "weekdays")
+	assert.NoError(t, err)
+	always, err := addBanner("https://mybanner.com/always", "always")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, weekdays.ID))
+	assert.NoError(t, addRelation(s.ID, always.ID))
+
+	_, err = sendJSON(http.MethodPut, "/relation/schedule", map[string]interface{}{
+		"slot":     s.ID,
+		"banner":   weekdays.ID,
+		"schedule": map[string]interface{}{"days": []string{"mon", "tue", "wed", "thu", "fri"}, "time_zone": "UTC"},
+	})
+	assert.NoError(t, err)
+
+	preview := func(at string) map[int]bool {
+		body, err := sendJSON(http.MethodGet, fmt.Sprintf("/slot/preview?slot=%d&at=%s", s.ID, at), nil)
+		assert.NoError(t, err)
+		banners := []struct {
+			ID       int  `json:"id"`
+			Eligible bool `json:"eligible"`
+		}{}
+		assert.NoError(t, json.Unmarshal(body, &banners))
+
+		eligible := make(map[int]bool, len(banners))
+		for _, b := range banners {
+			eligible[b.ID] = b.Eligible
+		}
+		return eligible
+	}
+	assert.Equal(t, map[int]bool{weekdays.ID: false, always.ID: true}, preview("2026-10-17T12:00:00Z"))
+	assert.Equal(t, map[int]bool{weekdays.ID: true, always.ID: true}, preview("2026-10-19T12:00:00Z"))
+
+	// Only today is excluded, so get_banner must always choose the other banner.
+	days := make([]string, 0, 6)
+	today := time.Now().UTC().Weekday()
+	for d, name := range []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"} {
+		if time.Weekday(d) != today {
+			days = append(days, name)
+		}
+	}
+	_, err = sendJSON(http.MethodPut, "/relation/schedule", map[string]interface{}{
+		"slot":     s.ID,
+		"banner":   weekdays.ID,
+		"schedule": map[string]interface{}{"days": days},
+	})
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		b, err := getBanner(s.ID, g.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, always.ID, b.ID)
+	}
+
+	_, err = sendJSON(http.MethodDelete, "/relation/schedule", map[string]interface{}{"slot": s.ID, "banner": weekdays.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]bool{weekdays.ID: true, always.ID: true}, preview("2026-10-17T12:00:00Z"))
+
+	_, err = sendJSON(http.MethodPut, "/relation/schedule", map[string]interface{}{
+		"slot":     s.ID,
+		"banner":   weekdays.ID,
+		"schedule": map[string]interface{}{"hours": []map[string]int{{"from": 25, "to": 3}}},
+	})
+	assert.Error(t, err)
+}
+
+func TestCampaigns(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	advertiser := repository.Advertiser{}
+	body, err := sendJSON(http.MethodPost, "/advertiser", map[string]interface{}{"name": fmt.Sprintf("advertiser %d", suffix)})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &advertiser))
+
+	campaign := repository.Campaign{}
+	body, err = sendJSON(http.MethodPost, "/campaign", map[string]interface{}{"advertiser_id": advertiser.ID, "name": "autumn sale"})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &campaign))
+	assert.Equal(t, repository.CampaignActive, campaign.Status)
+
+	g, err := addGroup(fmt.Sprintf("campaign %d", suffix))
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	b, err := addBanner(fmt.Sprintf("https://mybanner.com/campaign/%d", suffix), "campaign")
+	assert.NoError(t, err)
+	_, err = sendJSON(http.MethodPatch, "/banner", map[string]interface{}{"banner": b.ID, "campaign_id": campaign.ID})
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	_, err = sendJSON(http.MethodPost, "/show", map[string]interface{}{"slot": s.ID, "banner": b.ID, "group": g.ID})
+	assert.NoError(t, err)
+
+	rollups := []repository.Rollup{}
+	body, err = sendJSON(http.MethodGet, "/statistic/rollup?level=advertiser", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &rollups))
+	found := false
+	for _, rollup := range rollups {
+		if rollup.ID == advertiser.ID {
+			found = true
+			assert.Equal(t, int64(1), rollup.Impressions)
+		}
+	}
+	assert.True(t, found)
+
+	_, err = sendJSON(http.MethodPost, "/campaign/pause", map[string]interface{}{"campaign": campaign.ID})
+	assert.NoError(t, err)
+	_, err = getBanner(s.ID, g.ID)
+	assert.Error(t, err)
+
+	_, err = sendJSON(http.MethodDelete, "/campaign", map[string]interface{}{"campaign": campaign.ID})
+	assert.Error(t, err)
+	_, err = sendJSON(http.MethodDelete, "/advertiser", map[string]interface{}{"advertiser": advertiser.ID})
+	assert.Error(t, err)
+
+	_, err = sendJSON(http.MethodPost, "/campaign/resume", map[string]interface{}{"campaign": campaign.ID})
+	assert.NoError(t, err)
+	got, err := getBanner(s.ID, g.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, b.ID, got.ID)
+	assert.Equal(t, campaign.ID, got.CampaignID)
+
+	// the campaign of a removed banner is removed as well, but only marked so
+	_, err = sendJSON(http.MethodDelete, "/banner", map[string]int{"banner": b.ID})
+	assert.NoError(t, err)
+	_, err = sendJSON(http.MethodDelete, "/campaign", map[string]interface{}{"campaign": campaign.ID})
+	assert.NoError(t, err)
+	_, err = sendJSON(http.MethodDelete, "/campaign", map[string]interface{}{"campaign": campaign.ID})
+	assert.Error(t, err)
+	_, err = sendJSON(http.MethodPost, "/campaign/resume", map[string]interface{}{"campaign": campaign.ID})
+	assert.Error(t, err)
+	_, err = sendJSON(http.MethodDelete, "/advertiser", map[string]interface{}{"advertiser": advertiser.ID})
+	assert.NoError(t, err)
+}
+
+func TestBudget(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	g, err := addGroup(fmt.Sprintf("budget %d", suffix))
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	b, err := addBanner(fmt.Sprintf("https://mybanner.com/budget/%d", suffix), "budget")
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	_, err = sendJSON(http.MethodPatch, "/banner", map[string]interface{}{
+		"banner": b.ID,
+		"budget": map[string]interface{}{"metric": "impressions", "lifetime": 2, "pacing": "asap"},
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		got, err := getBanner(s.ID, g.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, b.ID, got.ID)
+		_, err = sendJSON(http.MethodPost, "/show", map[string]interface{}{"slot": s.ID, "banner": b.ID, "group": g.ID})
+		assert.NoError(t, err)
+	}
+	_, err = getBanner(s.ID, g.ID)
+	assert.Error(t, err)
+
+	_, err = sendJSON(http.MethodPatch, "/banner", map[string]interface{}{
+		"banner": b.ID,
+		"budget": map[string]interface{}{"metric": "spend", "daily": 10},
+	})
+	assert.Error(t, err)
+}
+
+func TestUpdateBannerFlight(t *testing.T) {
+	b, err := addBanner(fmt.Sprintf("https://mybanner.com/flight/%d", time.Now().UnixNano()), "flight")
+	assert.NoError(t, err)
+
+	end := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	body, err := sendJSON(http.MethodPatch, "/banner", map[string]interface{}{"banner": b.ID, "end_at": end})
+	assert.NoError(t, err)
+	updated := repository.Banner{}
+	assert.NoError(t, json.Unmarshal(body, &updated))
+	if assert.NotNil(t, updated.EndAt) {
+		assert.True(t, end.Equal(*updated.EndAt))
+	}
+
+	// an absent field keeps the end, null removes it
+	body, err = sendJSON(http.MethodPatch, "/banner", map[string]interface{}{"banner": b.ID, "description": "flight"})
+	assert.NoError(t, err)
+	updated = repository.Banner{}
+	assert.NoError(t, json.Unmarshal(body, &updated))
+	assert.NotNil(t, updated.EndAt)
+	body, err = sendJSON(http.MethodPatch, "/banner", map[string]interface{}{"banner": b.ID, "end_at": nil})
+	assert.NoError(t, err)
+	updated = repository.Banner{}
+	assert.NoError(t, json.Unmarshal(body, &updated))
+	assert.Nil(t, updated.EndAt)
+
+	_, err = sendJSON(http.MethodPatch, "/banner", map[string]interface{}{"banner": b.ID, "url": ""})
+	assert.Error(t, err)
+}
+
+func TestGuaranteedDelivery(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	g, err := addGroup(fmt.Sprintf("delivery %d", suffix))
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	sold, err := addBanner(fmt.Sprintf("https://mybanner.com/sold/%d", suffix), "sold")
+	assert.NoError(t, err)
+	other, err := addBanner(fmt.Sprintf("https://mybanner.com/other/%d", suffix), "other")
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, sold.ID))
+	assert.NoError(t, addRelation(s.ID, other.ID))
+
+	_, err = sendJSON(http.MethodPut, "/relation/delivery", map[string]interface{}{
+		"slot": s.ID, "banner": sold.ID, "delivery": map[string]interface{}{"tier": "guaranteed", "share": 0.5},
+	})
+	assert.NoError(t, err)
+	_, err = sendJSON(http.MethodPut, "/relation/delivery", map[string]interface{}{
+		"slot": s.ID, "banner": other.ID, "delivery": map[string]interface{}{"tier": "guaranteed", "share": 0.6},
+	})
+	assert.Error(t, err)
+
+	for i := 0; i < 20; i++ {
+		b, err := getBanner(s.ID, g.ID)
+		assert.NoError(t, err)
+		_, err = sendJSON(http.MethodPost, "/show", map[string]interface{}{"slot": s.ID, "banner": b.ID, "group": g.ID})
+		assert.NoError(t, err)
+	}
+
+	reports := []struct {
+		BannerID    int   `json:"banner"`
+		Impressions int64 `json:"impressions"`
+		OnTrack     bool  `json:"on_track"`
+	}{}
+	body, err := sendJSON(http.MethodGet, fmt.Sprintf("/delivery/report?slot=%d", s.ID), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &reports))
+	assert.Len(t, reports, 1)
+	assert.Equal(t, sold.ID, reports[0].BannerID)
+	assert.GreaterOrEqual(t, reports[0].Impressions, int64(10))
+	assert.True(t, reports[0].OnTrack)
+
+	_, err = sendJSON(http.MethodDelete, "/relation/delivery", map[string]interface{}{"slot": s.ID, "banner": sold.ID})
+	assert.NoError(t, err)
+}
+
+func TestExperiment(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	g, err := addGroup(fmt.Sprintf("experiment %d", suffix))
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		b, err := addBanner(fmt.Sprintf("https://mybanner.com/experiment/%d/%d", suffix, i), "experiment")
+		assert.NoError(t, err)
+		assert.NoError(t, addRelation(s.ID, b.ID))
+	}
+
+	arms := []map[string]interface{}{
+		{"name": "thompson", "weight": 1, "bandit": map[string]interface{}{"algorithm": "thompson", "min_events": 10}},
+		{"name": "ucb", "weight": 1, "bandit": map[string]interface{}{"algorithm": "ucb"}},
+	}
+	e := repository.Experiment{}
+	body, err := sendJSON(http.MethodPost, "/experiment", map[string]interface{}{"slot": s.ID, "name": "ucb vs thompson", "arms": arms})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &e))
+	_, err = sendJSON(http.MethodPost, "/experiment", map[string]interface{}{"slot": s.ID, "name": "another", "arms": arms})
+	assert.Error(t, err)
+
+	for i := 0; i < 20; i++ {
+		resp := struct {
+			repository.Banner
+			repository.ArmRef
+		}{}
+		body, err := sendJSON(http.MethodPost, "/get_banner", map[string]interface{}{
+			"slot": s.ID, "group": g.ID, "user_id": fmt.Sprintf("user %d", i), "count_show": true,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, e.ID, resp.ExperimentID)
+		assert.NotEmpty(t, resp.Arm)
+	}
+
+	experimentShows := func() (int, int64) {
+		report := struct {
+			Report []struct {
+				Arm         string `json:"arm"`
+				Impressions int64  `json:"impressions"`
+			} `json:"report"`
+		}{}
+		body, err := sendJSON(http.MethodGet, fmt.Sprintf("/experiment/report?experiment=%d", e.ID), nil)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(body, &report))
+		total := int64(0)
+		for _, r := range report.Report {
+			total += r.Impressions
+		}
+		return len(report.Report), total
+	}
+	reported, total := experimentShows()
+	assert.Equal(t, 2, reported)
+	assert.Equal(t, int64(20), total)
+
+	// the counters of the experiment are reset and restored together with the ones of its slot
+	body, err = sendJSON(http.MethodPost, "/statistic/reset", map[string]interface{}{"name": fmt.Sprintf("experiment %d", suffix), "slot": s.ID})
+	assert.NoError(t, err)
+	snapshot := repository.Snapshot{}
+	assert.NoError(t, json.Unmarshal(body, &snapshot))
+	_, total = experimentShows()
+	assert.Equal(t, int64(0), total)
+	_, err = sendJSON(http.MethodPost, "/snapshot/restore", map[string]int{"snapshot": snapshot.ID})
+	assert.NoError(t, err)
+	_, total = experimentShows()
+	assert.Equal(t, int64(20), total)
+
+	_, err = sendJSON(http.MethodPost, "/experiment/stop", map[string]interface{}{"experiment": e.ID})
+	assert.NoError(t, err)
+	_, err = sendJSON(http.MethodPost, "/experiment/stop", map[string]interface{}{"experiment": e.ID})
+	assert.Error(t, err)
+}
+
+func TestReport(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	b, err := addBanner("https://mybanner.com/report", "report")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	from := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	_, err = sendJSON(http.MethodPost, "/events", []map[string]interface{}{
+		{"type": "show", "slot": s.ID, "banner": b.ID, "group": g.ID},
+		{"type": "show", "slot": s.ID, "banner": b.ID, "group": g.ID},
+		{"type": "click", "slot": s.ID, "banner": b.ID, "group": g.ID},
+	})
+	assert.NoError(t, err)
+
+	report := struct {
+		Series []struct {
+			SlotID   int `json:"slot"`
+			BannerID int `json:"banner"`
+			Points   []struct {
+				Impressions int64   `json:"impressions"`
+				Clicks      int64   `json:"clicks"`
+				CTR         float64 `json:"ctr"`
+			} `json:"points"`
+		} `json:"series"`
+	}{}
+	body, err := sendJSON(http.MethodGet, "/reports?granularity=hour&group_by=slot,banner&from="+from, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &report))
+	impressions, clicks := int64(0), int64(0)
+	for _, series := range report.Series {
+		if series.SlotID != s.ID {
+			continue
+		}
+		assert.Equal(t, b.ID, series.BannerID)
+		for _, p := range series.Points {
+			impressions += p.Impressions
+			clicks += p.Clicks
+		}
+	}
+	assert.Equal(t, int64(2), impressions)
+	assert.Equal(t, int64(1), clicks)
+
+	_, err = sendJSON(http.MethodGet, "/reports?granularity=minute&from="+from, nil)
+	assert.Error(t, err)
+
+	r, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:8088/reports?group_by=slot&from="+from, nil) //nolint:noctx
+	assert.NoError(t, err)
+	r.Header.Set("Accept", "text/csv")
+	resp, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	csvBody, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(csvBody), "time,slot,impressions,clicks,ctr\n"))
+	assert.Contains(t, string(csvBody), fmt.Sprintf(",%d,2,1,0.5\n", s.ID))
+}
+
+func TestSlotAnalytics(t *testing.T) {
+	g, err := addGroup("group1")
+	assert.NoError(t, err)
+	b, err := addBanner("https://mybanner.com/analytics", "analytics")
+	assert.NoError(t, err)
+	s, err := addSlot()
+	assert.NoError(t, err)
+	assert.NoError(t, addRelation(s.ID, b.ID))
+
+	for i := 0; i < 3; i++ {
+		_, err = sendJSON(http.MethodPost, "/show", map[string]int{"slot": s.ID, "banner": b.ID, "group": g.ID})
+		assert.NoError(t, err)
+	}
+
+	report := analytics.Slot{}
+	body, err := sendJSON(http.MethodGet, fmt.Sprintf("/slot/analytics?slot=%d&min_events=2", s.ID), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, int64(3), report.Impressions)
+	assert.Equal(t, int64(2), report.ColdImpressions)
+	converged := false
+	for _, c := range report.Convergence {
+		converged = converged || (c.BannerID == b.ID && c.GroupID == g.ID && c.ConvergedAt != nil)
+	}
+	assert.True(t, converged)
+
+	_, err = sendJSON(http.MethodGet, "/slot/analytics?slot=-1", nil)
+	assert.Error(t, err)
+}
+
+func TestErrorResponses(t *testing.T) {
+	type errorResponse struct {
+		Error struct {
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+			Fields  []struct {
+				Field   string `json:"field"`
+				Message string `json:"message"`
+			} `json:"fields"`
+		} `json:"error"`
+	}
+	post := func(path, body string) (int, errorResponse) {
+		resp, err := http.Post("http://127.0.0.1:8088"+path, "application/json", strings.NewReader(body)) //nolint:noctx
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		e := errorResponse{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&e))
+		return resp.StatusCode, e
+	}
+
+	status, e := post("/get_banner", `{"group": 1}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, http.StatusBadRequest, e.Error.Status)
+	assert.Len(t, e.Error.Fields, 1)
+	assert.Equal(t, "slot", e.Error.Fields[0].Field)
+
+	for path, body := range map[string]string{"/get_banner": `{"slot": 1}`, "/get_page_banners": `{"slots": [1]}`} {
+		status, e = post(path, body)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Len(t, e.Error.Fields, 1)
+		assert.Equal(t, "group", e.Error.Fields[0].Field)
+	}
+
+	status, e = post("/get_banner", `{"slot": 1, "grup": 1}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, e.Error.Message, "grup")
+
+	status, e = post("/banner", `{"url": "ftp://mybanner.com", "description": "ftp"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Len(t, e.Error.Fields, 1)
+	assert.Equal(t, "url", e.Error.Fields[0].Field)
+
+	status, e = post("/relation", `{"slot": -1, "banner": 1}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "slot", e.Error.Fields[0].Field)
+
+	status, e = post("/unknown", `{}`)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, http.StatusNotFound, e.Error.Status)
+}
@@ -0,0 +1,289 @@
+// Package alert evaluates the alert rules over the banner statistics and the serving
+// failures in the background and notifies about the alerts which start or stop firing.
+package alert
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidRule = errors.New("alert rule must have a name, a known kind and non-negative thresholds, " +
+	"a ctr drop must be less than 1")
+
+// Kind is what the rule watches.
+type Kind string
+
+const (
+	// KindCTRDrop fires when the click rate of a banner in a slot falls sharply against its baseline.
+	KindCTRDrop Kind = "ctr_drop"
+	// KindSlotNotFound fires when the slot responds that there are no banner relations.
+	KindSlotNotFound Kind = "slot_not_found"
+)
+
+// Rule describes when an alert fires, zero slot and banner ids match any slot and banner.
+type Rule struct {
+	Name     string `json:"name"`
+	Kind     Kind   `json:"kind"`
+	SlotID   int    `json:"slot,omitempty"`
+	BannerID int    `json:"banner,omitempty"`
+	// Drop is the relative fall of the click rate in an evaluation interval against the click rate
+	// before it, 0.5 fires when the click rate halves.
+	Drop float64 `json:"drop,omitempty"`
+	// MinImpressions is the number of impressions an interval needs for its click rate to be judged.
+	MinImpressions int64 `json:"min_impressions,omitempty"`
+	// MinFailures is the number of not found responses in an interval which fires slot_not_found.
+	MinFailures int `json:"min_failures,omitempty"`
+}
+
+func (r Rule) Validate() error {
+	if r.Name == "" || (r.Kind != KindCTRDrop && r.Kind != KindSlotNotFound) {
+		return ErrInvalidRule
+	}
+	if r.Drop < 0 || r.Drop >= 1 || r.MinImpressions < 0 || r.MinFailures < 0 {
+		return ErrInvalidRule
+	}
+
+	return nil
+}
+
+func (r Rule) matches(slotID, bannerID int) bool {
+	return (r.SlotID == 0 || r.SlotID == slotID) && (r.BannerID == 0 || r.BannerID == bannerID)
+}
+
+// Status tells whether the alert has started or stopped firing.
+type Status string
+
+const (
+	StatusFiring   Status = "firing"
+	StatusResolved Status = "resolved"
+)
+
+// Alert is the notification sent when a rule starts or stops firing.
+type Alert struct {
+	Rule     string `json:"rule"`
+	Kind     Kind   `json:"kind"`
+	Status   Status `json:"status"`
+	SlotID   int    `json:"slot"`
+	BannerID int    `json:"banner,omitempty"`
+	// BaselineCTR and CTR are the click rates before and in the interval, set by ctr_drop.
+	BaselineCTR float64 `json:"baseline_ctr,omitempty"`
+	CTR         float64 `json:"ctr,omitempty"`
+	// Failures is the number of not found responses in the interval, set by slot_not_found.
+	Failures int       `json:"failures,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Counter holds the total events of a banner in a slot.
+type Counter struct {
+	SlotID      int
+	BannerID    int
+	Impressions int64
+	Clicks      int64
+}
+
+// Source provides the total events of the banners in the slots.
+type Source interface {
+	GetSlotBannerCounters(ctx context.Context) ([]Counter, error)
+}
+
+// Notifier delivers the alerts.
+type Notifier interface {
+	Send(ctx context.Context, payload interface{}) error
+}
+
+type counterKey struct {
+	slotID   int
+	bannerID int
+}
+
+type firingKey struct {
+	rule     string
+	slotID   int
+	bannerID int
+}
+
+// Evaluator checks the rules every interval. The click rates of an interval are the differences
+// of the counters since the previous evaluation, so the first evaluation only takes the baseline.
+type Evaluator struct {
+	rules    []Rule
+	source   Source
+	notifier Notifier
+	interval time.Duration
+
+	mu       sync.Mutex
+	failures map[int]int
+
+	previous map[counterKey]Counter
+	firing   map[firingKey]bool
+}
+
+func NewEvaluator(rules []Rule, source Source, notifier Notifier, interval time.Duration) (*Evaluator, error) {
+	if interval <= 0 {
+		return nil, errors.New("alert evaluation interval must be positive")
+	}
+	names := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+		if names[r.Name] {
+			return nil, ErrInvalidRule
+		}
+		names[r.Name] = true
+	}
+
+	return &Evaluator{
+		rules:    rules,
+		source:   source,
+		notifier: notifier,
+		interval: interval,
+		failures: make(map[int]int),
+		previous: make(map[counterKey]Counter),
+		firing:   make(map[firingKey]bool),
+	}, nil
+}
+
+// SlotNotFound counts a not found response of the slot.
+func (e *Evaluator) SlotNotFound(slotID int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures[slotID]++
+}
+
+// Run evaluates the rules every interval until the context is done.
+func (e *Evaluator) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := e.Evaluate(ctx, now); err != nil {
+				log.Error("failed to evaluate alert rules: ", err.Error())
+			}
+		}
+	}
+}
+
+// Evaluate checks the rules and sends the alerts which start or stop firing,
+// a failure to send an alert is only logged.
+func (e *Evaluator) Evaluate(ctx context.Context, now time.Time) error {
+	e.mu.Lock()
+	failures := e.failures
+	e.failures = make(map[int]int)
+	e.mu.Unlock()
+
+	counters, err := e.source.GetSlotBannerCounters(ctx)
+	if err != nil {
+		return err
+	}
+
+	alerts := append(e.evaluateCTR(counters, now), e.evaluateFailures(failures, now)...)
+	for _, a := range alerts {
+		if err := e.notifier.Send(ctx, a); err != nil {
+			log.WithFields(log.Fields{
+				"rule":      a.Rule,
+				"status":    a.Status,
+				"slot id":   a.SlotID,
+				"banner id": a.BannerID,
+			}).Error("failed to send alert: ", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (e *Evaluator) evaluateCTR(counters []Counter, now time.Time) []Alert {
+	alerts := make([]Alert, 0)
+	for _, c := range counters {
+		key := counterKey{slotID: c.SlotID, bannerID: c.BannerID}
+		prev, ok := e.previous[key]
+		e.previous[key] = c
+		// a reset of the statistics starts a new baseline
+		if !ok || prev.Impressions == 0 || c.Impressions < prev.Impressions || c.Clicks < prev.Clicks {
+			continue
+		}
+
+		impressions := c.Impressions - prev.Impressions
+		baseline := float64(prev.Clicks) / float64(prev.Impressions)
+		if impressions == 0 || baseline == 0 {
+			continue
+		}
+		ctr := float64(c.Clicks-prev.Clicks) / float64(impressions)
+
+		for _, r := range e.rules {
+			if r.Kind != KindCTRDrop || !r.matches(c.SlotID, c.BannerID) || impressions < r.MinImpressions {
+				continue
+			}
+			a := Alert{Rule: r.Name, Kind: r.Kind, SlotID: c.SlotID, BannerID: c.BannerID, BaselineCTR: baseline, CTR: ctr, At: now}
+			if status, changed := e.transition(firingKey{rule: r.Name, slotID: c.SlotID, bannerID: c.BannerID}, ctr < baseline*(1-r.Drop)); changed {
+				a.Status = status
+				alerts = append(alerts, a)
+			}
+		}
+	}
+
+	return alerts
+}
+
+func (e *Evaluator) evaluateFailures(failures map[int]int, now time.Time) []Alert {
+	alerts := make([]Alert, 0)
+	for _, r := range e.rules {
+		if r.Kind != KindSlotNotFound {
+			continue
+		}
+
+		// the slots which fail now and the slots which have been firing
+		slots := make(map[int]bool)
+		for slotID := range failures {
+			slots[slotID] = true
+		}
+		for key, firing := range e.firing {
+			if firing && key.rule == r.Name {
+				slots[key.slotID] = true
+			}
+		}
+
+		minFailures := r.MinFailures
+		if minFailures == 0 {
+			minFailures = 1
+		}
+		for slotID := range slots {
+			if r.SlotID != 0 && r.SlotID != slotID {
+				continue
+			}
+			n := failures[slotID]
+			if status, changed := e.transition(firingKey{rule: r.Name, slotID: slotID}, n >= minFailures); changed {
+				alerts = append(alerts, Alert{Rule: r.Name, Kind: r.Kind, Status: status, SlotID: slotID, Failures: n, At: now})
+			}
+		}
+	}
+
+	return alerts
+}
+
+// transition keeps whether the alert fires and tells when it starts or stops firing.
+func (e *Evaluator) transition(key firingKey, firing bool) (Status, bool) {
+	was := e.firing[key]
+	if firing {
+		e.firing[key] = true
+	} else {
+		delete(e.firing, key)
+	}
+
+	switch {
+	case firing && !was:
+		return StatusFiring, true
+	case !firing && was:
+		return StatusResolved, true
+	}
+
+	return "", false
+}
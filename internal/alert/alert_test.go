@@ -0,0 +1,117 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticSource struct {
+	counters []Counter
+}
+
+func (s *staticSource) GetSlotBannerCounters(_ context.Context) ([]Counter, error) {
+	return s.counters, nil
+}
+
+// receiver collects the alerts delivered to the webhook.
+func receiver(t *testing.T, secret []byte) (*httptest.Server, *[]Alert) {
+	alerts := make([]Alert, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.True(t, webhook.Verify(secret, r.Header.Get(webhook.TimestampHeader), body, r.Header.Get(webhook.SignatureHeader)))
+
+		a := Alert{}
+		assert.NoError(t, json.Unmarshal(body, &a))
+		alerts = append(alerts, a)
+	}))
+
+	return server, &alerts
+}
+
+func TestRuleValidate(t *testing.T) {
+	assert.NoError(t, Rule{Name: "drop", Kind: KindCTRDrop, Drop: 0.5}.Validate())
+	assert.NoError(t, Rule{Name: "empty", Kind: KindSlotNotFound}.Validate())
+
+	assert.Equal(t, ErrInvalidRule, Rule{Kind: KindCTRDrop}.Validate())
+	assert.Equal(t, ErrInvalidRule, Rule{Name: "drop", Kind: "spike"}.Validate())
+	assert.Equal(t, ErrInvalidRule, Rule{Name: "drop", Kind: KindCTRDrop, Drop: 1}.Validate())
+
+	_, err := NewEvaluator([]Rule{{Name: "a", Kind: KindCTRDrop}, {Name: "a", Kind: KindSlotNotFound}}, nil, nil, time.Minute)
+	assert.Equal(t, ErrInvalidRule, err)
+}
+
+func TestCTRDrop(t *testing.T) {
+	secret := []byte("secret")
+	server, alerts := receiver(t, secret)
+	defer server.Close()
+	sender, err := webhook.NewSender(server.URL, secret, 0, 0)
+	assert.NoError(t, err)
+
+	source := &staticSource{counters: []Counter{{SlotID: 1, BannerID: 2, Impressions: 1000, Clicks: 100}}}
+	e, err := NewEvaluator([]Rule{{Name: "drop", Kind: KindCTRDrop, Drop: 0.5, MinImpressions: 100}}, source, sender, time.Minute)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	at := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	// the first evaluation takes the baseline
+	assert.NoError(t, e.Evaluate(ctx, at))
+	assert.Empty(t, *alerts)
+
+	// too few impressions to judge
+	source.counters[0] = Counter{SlotID: 1, BannerID: 2, Impressions: 1050, Clicks: 100}
+	assert.NoError(t, e.Evaluate(ctx, at.Add(time.Minute)))
+	assert.Empty(t, *alerts)
+
+	source.counters[0] = Counter{SlotID: 1, BannerID: 2, Impressions: 1250, Clicks: 101}
+	assert.NoError(t, e.Evaluate(ctx, at.Add(time.Minute*2)))
+	assert.Len(t, *alerts, 1)
+	assert.Equal(t, StatusFiring, (*alerts)[0].Status)
+	assert.Equal(t, 2, (*alerts)[0].BannerID)
+	assert.InDelta(t, 0.005, (*alerts)[0].CTR, 1e-9)
+
+	// the alert isn't repeated while it keeps firing
+	source.counters[0] = Counter{SlotID: 1, BannerID: 2, Impressions: 1450, Clicks: 102}
+	assert.NoError(t, e.Evaluate(ctx, at.Add(time.Minute*3)))
+	assert.Len(t, *alerts, 1)
+
+	source.counters[0] = Counter{SlotID: 1, BannerID: 2, Impressions: 1650, Clicks: 130}
+	assert.NoError(t, e.Evaluate(ctx, at.Add(time.Minute*4)))
+	assert.Len(t, *alerts, 2)
+	assert.Equal(t, StatusResolved, (*alerts)[1].Status)
+}
+
+func TestSlotNotFound(t *testing.T) {
+	secret := []byte("secret")
+	server, alerts := receiver(t, secret)
+	defer server.Close()
+	sender, err := webhook.NewSender(server.URL, secret, 0, 0)
+	assert.NoError(t, err)
+
+	e, err := NewEvaluator([]Rule{{Name: "empty", Kind: KindSlotNotFound, MinFailures: 2}}, &staticSource{}, sender, time.Minute)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	at := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	e.SlotNotFound(1)
+	assert.NoError(t, e.Evaluate(ctx, at))
+	assert.Empty(t, *alerts)
+
+	e.SlotNotFound(1)
+	e.SlotNotFound(1)
+	assert.NoError(t, e.Evaluate(ctx, at.Add(time.Minute)))
+	assert.Equal(t, []Alert{{Rule: "empty", Kind: KindSlotNotFound, Status: StatusFiring, SlotID: 1, Failures: 2, At: at.Add(time.Minute)}}, *alerts)
+
+	assert.NoError(t, e.Evaluate(ctx, at.Add(time.Minute*2)))
+	assert.Len(t, *alerts, 2)
+	assert.Equal(t, StatusResolved, (*alerts)[1].Status)
+}
@@ -0,0 +1,119 @@
+// Package analytics estimates how the slot bandits share the impressions between exploring
+// the banners and exploiting the best one.
+package analytics
+
+import (
+	"sort"
+	"time"
+)
+
+// Relation holds the events of a banner shown to a group in the slot.
+type Relation struct {
+	BannerID    int
+	GroupID     int
+	Impressions int64
+	Clicks      int64
+}
+
+// Rollout tells when a banner was added to the slot for a group and when it got
+// the minimal number of impressions, WarmAt is nil while the banner is cold.
+type Rollout struct {
+	BannerID int
+	GroupID  int
+	AddedAt  time.Time
+	WarmAt   *time.Time
+}
+
+// Convergence is the time it took a new banner to be judged by its click rate.
+type Convergence struct {
+	BannerID    int        `json:"banner"`
+	GroupID     int        `json:"group"`
+	AddedAt     time.Time  `json:"added_at"`
+	ConvergedAt *time.Time `json:"converged_at,omitempty"`
+	Seconds     float64    `json:"seconds,omitempty"`
+}
+
+// Slot sums up the exploration of the slot bandit. The bandit judges a banner by its click rate
+// only after MinEvents impressions in a group, the impressions before it are cold ones.
+// Regret is the number of clicks lost against always showing the best warm banner of the group.
+type Slot struct {
+	SlotID              int     `json:"slot"`
+	MinEvents           int     `json:"min_events"`
+	Impressions         int64   `json:"impressions"`
+	Clicks              int64   `json:"clicks"`
+	CTR                 float64 `json:"ctr"`
+	Regret              float64 `json:"regret"`
+	RegretPerImpression float64 `json:"regret_per_impression"`
+	ColdImpressions     int64   `json:"cold_impressions"`
+	ColdShare           float64 `json:"cold_share"`
+	// ColdBanners counts the banners which are still cold in some group.
+	ColdBanners int `json:"cold_banners"`
+	// MedianConvergence is the median of the seconds the converged new banners took.
+	MedianConvergence float64       `json:"median_convergence_seconds"`
+	Convergence       []Convergence `json:"convergence"`
+}
+
+// Analyze makes the analytics of the slot from the counters of its relations and the rollouts of its new banners.
+func Analyze(slotID, minEvents int, relations []Relation, rollouts []Rollout) Slot {
+	s := Slot{SlotID: slotID, MinEvents: minEvents, Convergence: make([]Convergence, 0, len(rollouts))}
+
+	// the bandit chooses among the banners of a group, so the best banner is found per group
+	best := make(map[int]float64)
+	for _, r := range relations {
+		if r.Impressions > 0 && r.Impressions >= int64(minEvents) {
+			if ctr := float64(r.Clicks) / float64(r.Impressions); ctr > best[r.GroupID] {
+				best[r.GroupID] = ctr
+			}
+		}
+	}
+
+	cold := make(map[int]bool)
+	for _, r := range relations {
+		s.Impressions += r.Impressions
+		s.Clicks += r.Clicks
+		if r.Impressions < int64(minEvents) {
+			s.ColdImpressions += r.Impressions
+			cold[r.BannerID] = true
+		} else {
+			s.ColdImpressions += int64(minEvents)
+		}
+		if r.Impressions > 0 {
+			if lost := best[r.GroupID] - float64(r.Clicks)/float64(r.Impressions); lost > 0 {
+				s.Regret += float64(r.Impressions) * lost
+			}
+		}
+	}
+	s.ColdBanners = len(cold)
+	if s.Impressions > 0 {
+		s.CTR = float64(s.Clicks) / float64(s.Impressions)
+		s.RegretPerImpression = s.Regret / float64(s.Impressions)
+		s.ColdShare = float64(s.ColdImpressions) / float64(s.Impressions)
+	}
+
+	seconds := make([]float64, 0, len(rollouts))
+	for _, r := range rollouts {
+		c := Convergence{BannerID: r.BannerID, GroupID: r.GroupID, AddedAt: r.AddedAt, ConvergedAt: r.WarmAt}
+		if r.WarmAt != nil {
+			c.Seconds = r.WarmAt.Sub(r.AddedAt).Seconds()
+			seconds = append(seconds, c.Seconds)
+		}
+		s.Convergence = append(s.Convergence, c)
+	}
+	s.MedianConvergence = median(seconds)
+
+	return s
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+
+	return (values[n/2-1] + values[n/2]) / 2
+}
@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	relations := []Relation{
+		{BannerID: 1, GroupID: 1, Impressions: 100, Clicks: 10},
+		{BannerID: 2, GroupID: 1, Impressions: 100, Clicks: 5},
+		{BannerID: 3, GroupID: 1, Impressions: 20, Clicks: 10},
+		{BannerID: 1, GroupID: 2, Impressions: 80, Clicks: 4},
+	}
+	s := Analyze(7, 50, relations, nil)
+
+	assert.Equal(t, 7, s.SlotID)
+	assert.Equal(t, int64(300), s.Impressions)
+	assert.Equal(t, int64(29), s.Clicks)
+	// the cold banner 3 doesn't set the best click rate though it's the highest one
+	assert.InDelta(t, 5.0, s.Regret, 1e-9)
+	assert.InDelta(t, 5.0/300, s.RegretPerImpression, 1e-9)
+	assert.Equal(t, int64(50+50+20+50), s.ColdImpressions)
+	assert.InDelta(t, 170.0/300, s.ColdShare, 1e-9)
+	assert.Equal(t, 1, s.ColdBanners)
+	assert.Empty(t, s.Convergence)
+}
+
+func TestAnalyzeConvergence(t *testing.T) {
+	added := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	warm := func(d time.Duration) *time.Time {
+		at := added.Add(d)
+		return &at
+	}
+	s := Analyze(1, 50, nil, []Rollout{
+		{BannerID: 1, GroupID: 1, AddedAt: added, WarmAt: warm(time.Minute)},
+		{BannerID: 2, GroupID: 1, AddedAt: added, WarmAt: warm(3 * time.Minute)},
+		{BannerID: 3, GroupID: 1, AddedAt: added},
+	})
+
+	assert.Len(t, s.Convergence, 3)
+	assert.Equal(t, 60.0, s.Convergence[0].Seconds)
+	assert.Nil(t, s.Convergence[2].ConvergedAt)
+	assert.Equal(t, 120.0, s.MedianConvergence)
+	assert.Zero(t, s.ColdShare)
+}
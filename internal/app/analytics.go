@@ -0,0 +1,55 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bubblesupreme/banner_rotation/internal/analytics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GetSlotAnalytics estimates the regret of the slot bandit against the best banner, the share of the impressions
+// spent on cold banners and how long the new banners took to get warm. The optional min_events parameter
+// replaces the number of impressions after which the slot bandit judges a banner by its click rate.
+func (a *BannersApp) GetSlotAnalytics(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("slot")
+	slotID, err := strconv.Atoi(value)
+	if err != nil {
+		writeError(w, fmt.Sprintf("invalid slot id %q", value), http.StatusBadRequest)
+		return
+	}
+	minEvents := a.minEvents
+	if value := r.URL.Query().Get("min_events"); value != "" {
+		if minEvents, err = strconv.Atoi(value); err != nil || minEvents < 0 {
+			writeError(w, fmt.Sprintf("invalid min events %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	logEntry := log.WithFields(log.Fields{
+		"slot id":    slotID,
+		"min events": minEvents,
+	})
+	relations, err := a.repo.GetSlotRelations(r.Context(), slotID)
+	if err != nil {
+		logEntry.Error("failed to get slot relations: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	rollouts, err := a.repo.GetSlotRollouts(r.Context(), slotID, minEvents)
+	if err != nil {
+		logEntry.Error("failed to get slot rollouts: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := analytics.Analyze(slotID, minEvents, relations, rollouts)
+	if err := json.NewEncoder(w).Encode(&report); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,11 +1,28 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
-
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/alert"
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+	"github.com/bubblesupreme/banner_rotation/internal/delivery"
+	"github.com/bubblesupreme/banner_rotation/internal/experiment"
+	"github.com/bubblesupreme/banner_rotation/internal/fraud"
+	"github.com/bubblesupreme/banner_rotation/internal/idempotency"
+	"github.com/bubblesupreme/banner_rotation/internal/pacing"
 	"github.com/bubblesupreme/banner_rotation/internal/producer"
 	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/schedule"
+	"github.com/bubblesupreme/banner_rotation/internal/segment"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -13,40 +30,209 @@ import (
 type BannersApp struct {
 	repo     repository.BannersRepository
 	producer producer.Producer
+	tokens   *token.Signer
+	// rawIDs accepts the shows and clicks without tokens by their raw ids
+	rawIDs bool
+
+	idempotency idempotency.Store
+	capping     capping.Store
+	// holdout is the percent of the traffic served at random
+	holdout float64
+
+	fraud     *fraud.Detector
+	dropFraud bool
+	alerts    *alert.Evaluator
+	// trustedProxies are the peers whose X-Forwarded-For header tells the client address
+	trustedProxies []*net.IPNet
+	// minEvents is the number of impressions after which the slot bandits judge a banner by its click rate
+	minEvents int
+}
+
+type Option func(a *BannersApp)
+
+// WithImpressionTokens makes GetBanner issue signed impression tokens and
+// requires them instead of raw ids for shows and clicks.
+func WithImpressionTokens(signer *token.Signer) Option {
+	return func(a *BannersApp) {
+		a.tokens = signer
+	}
+}
+
+// WithRawIDs accepts the shows and clicks which carry no impression token by their raw ids,
+// the ones carrying a token are still checked.
+func WithRawIDs() Option {
+	return func(a *BannersApp) {
+		a.rawIDs = true
+	}
 }
 
-func NewBannersApp(repo repository.BannersRepository, producer producer.Producer) *BannersApp {
-	return &BannersApp{
+// WithIdempotency makes repeated clicks and shows with the same idempotency key
+// get the original response instead of being counted again.
+func WithIdempotency(store idempotency.Store) Option {
+	return func(a *BannersApp) {
+		a.idempotency = store
+	}
+}
+
+// WithFrequencyCapping counts the shows to every user, so that banners with
+// a frequency cap aren't served to users who have already seen them enough.
+func WithFrequencyCapping(store capping.Store) Option {
+	return func(a *BannersApp) {
+		a.capping = store
+	}
+}
+
+// WithHoldout serves the percent of the traffic uniformly at random, so that the gain
+// of the bandits over the random serving can be measured.
+func WithHoldout(percent float64) Option {
+	return func(a *BannersApp) {
+		a.holdout = percent
+	}
+}
+
+// WithFraudDetection keeps the suspicious clicks out of the statistics, they are stored
+// for review or, with drop set, just thrown away.
+func WithFraudDetection(detector *fraud.Detector, drop bool) Option {
+	return func(a *BannersApp) {
+		a.fraud = detector
+		a.dropFraud = drop
+	}
+}
+
+// WithTrustedProxies believes the X-Forwarded-For header of the requests coming from the proxies,
+// the client address of the other requests is their peer address.
+func WithTrustedProxies(proxies []*net.IPNet) Option {
+	return func(a *BannersApp) {
+		a.trustedProxies = proxies
+	}
+}
+
+// WithAlerts reports the slots responding that they have no banner relations to the alert evaluator.
+func WithAlerts(evaluator *alert.Evaluator) Option {
+	return func(a *BannersApp) {
+		a.alerts = evaluator
+	}
+}
+
+// WithMinEvents tells the slot analytics after how many impressions the slot bandits
+// stop exploring a banner.
+func WithMinEvents(minEvents int) Option {
+	return func(a *BannersApp) {
+		a.minEvents = minEvents
+	}
+}
+
+func NewBannersApp(repo repository.BannersRepository, producer producer.Producer, opts ...Option) *BannersApp {
+	a := &BannersApp{
 		repo:     repo,
 		producer: producer,
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
 }
 
-func (a *BannersApp) GetBanner(w http.ResponseWriter, r *http.Request) { //nolint:dupl
+// GetBanner chooses a banner to show in the slot to the group. With count_show set
+// the show is counted in the same request: if it can't be counted no banner is returned
+// so the client never shows an uncounted banner, while a failure to publish the show
+// action is only logged because the show itself is already stored.
+func (a *BannersApp) GetBanner(w http.ResponseWriter, r *http.Request) {
 	reqData := struct {
-		SlotID  int `json:"slot"`
-		GroupID int `json:"group"`
+		SlotID     int                `json:"slot" validate:"required,id"`
+		GroupID    int                `json:"group" validate:"id"`
+		Attributes segment.Attributes `json:"attributes"`
+		UserID     string             `json:"user_id"`
+		CountShow  bool               `json:"count_show"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
 		return
 	}
 
-	banner, err := a.repo.GetBanner(r.Context(), reqData.SlotID, reqData.GroupID)
+	var err error
+	if reqData.GroupID, err = a.requestGroup(r.Context(), reqData.GroupID, reqData.Attributes); err != nil {
+		writeErrorStatus(w, err)
+		return
+	}
+
+	arm := a.assignArm(r.Context(), r, reqData.SlotID, reqData.UserID)
+	banner, err := a.repo.GetBanner(r.Context(), reqData.SlotID, reqData.GroupID, arm, a.capFilters(r.Context(), reqData.UserID)...)
 	if err != nil {
+		a.slotFailed(reqData.SlotID, err)
 		log.WithFields(log.Fields{
 			"slot id":  reqData.SlotID,
 			"group id": reqData.GroupID,
 		}).Error("failed to get banner: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), errorStatusCode(err))
 		return
 	}
 
-	if err = json.NewEncoder(w).Encode(&banner); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	resp, imp, err := a.serve(token.Impression{
+		SlotID:     reqData.SlotID,
+		BannerID:   banner.ID,
+		GroupID:    reqData.GroupID,
+		UserID:     reqData.UserID,
+		Experiment: arm.ExperimentID,
+		Arm:        arm.Arm,
+		Holdout:    arm.Holdout,
+	}, banner)
+	if err == nil && reqData.CountShow {
+		err = a.countServed(r.Context(), imp, &resp)
+	}
+	if err != nil {
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	if err = json.NewEncoder(w).Encode(&resp); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// serve prepares the response with the chosen banner and issues the impression token,
+// the impression is returned with the id of the token.
+func (a *BannersApp) serve(imp token.Impression, banner repository.Banner) (bannerResponse, token.Impression, error) {
+	resp := bannerResponse{Banner: banner, ArmRef: impressionArm(imp)}
+
+	var err error
+	if a.tokens != nil {
+		resp.Token, imp, err = a.tokens.Issue(imp)
+		if err != nil {
+			log.Error("failed to issue impression token: ", err.Error())
+			return resp, imp, err
+		}
+		resp.ClickURL = clickURL(resp.Token)
+		resp.PixelURL = pixelURL(resp.Token)
+	}
+
+	return resp, imp, nil
+}
+
+// countServed counts the show of the served impression and marks the response as shown,
+// see GetBanner for the failure semantics.
+func (a *BannersApp) countServed(ctx context.Context, imp token.Impression, resp *bannerResponse) error {
+	if err := a.countShow(ctx, imp); err != nil {
+		impressionLogEntry(imp).Error(err.Error())
+		return err
+	}
+	resp.Shown = true
+
+	if err := a.publishShow(imp); err != nil {
+		impressionLogEntry(imp).Error(err.Error())
+	}
+
+	return nil
+}
+
+// slotFailed reports the slot responding that it has no banner relations to the alerts.
+func (a *BannersApp) slotFailed(slotID int, err error) {
+	if a.alerts != nil && errors.Is(err, repository.ErrNotFound) {
+		a.alerts.SlotNotFound(slotID)
 	}
 }
 
@@ -55,191 +241,312 @@ func (a *BannersApp) AddSlot(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Error("failed to add new slot: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	a.audit(r, repository.AuditAddSlot, nil, slot)
 
 	if err = json.NewEncoder(w).Encode(&slot); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
-func (a *BannersApp) AddBanner(w http.ResponseWriter, r *http.Request) { //nolint:dupl
+func (a *BannersApp) AddBanner(w http.ResponseWriter, r *http.Request) {
 	reqData := struct {
-		BannerURL   string `json:"url"`
-		BannerDescr string `json:"description"`
+		BannerURL    string                   `json:"url" validate:"required,url"`
+		BannerDescr  string                   `json:"description"`
+		Status       repository.BannerStatus  `json:"status"`
+		StartAt      *time.Time               `json:"start_at"`
+		EndAt        *time.Time               `json:"end_at"`
+		Labels       []string                 `json:"labels"`
+		FrequencyCap *repository.FrequencyCap `json:"frequency_cap"`
+		CampaignID   int                      `json:"campaign_id" validate:"id"`
+		Budget       *pacing.Budget           `json:"budget"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
 		return
 	}
 
-	banner, err := a.repo.AddBanner(r.Context(), reqData.BannerURL, reqData.BannerDescr)
-	if err != nil {
+	banner := repository.Banner{
+		URL:         reqData.BannerURL,
+		Description: reqData.BannerDescr,
+		Status:      reqData.Status,
+		StartAt:     reqData.StartAt,
+		EndAt:       reqData.EndAt,
+		Labels:      reqData.Labels,
+		CampaignID:  reqData.CampaignID,
+	}
+	if reqData.FrequencyCap != nil && reqData.FrequencyCap.Limit != 0 {
+		banner.FrequencyCap = reqData.FrequencyCap
+	}
+	if reqData.Budget != nil && !reqData.Budget.Empty() {
+		banner.Budget = reqData.Budget
+	}
+	if banner.Status != "" && !banner.Status.Valid() {
+		writeError(w, fmt.Sprintf("unknown banner status %q", banner.Status), http.StatusBadRequest)
+		return
+	}
+	if err := banner.ValidateFlight(); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := banner.ValidateCap(); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := banner.ValidateBudget(); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the same banner is returned if it exists, the audit log only has the added ones
+	banner, err := a.repo.AddBanner(r.Context(), banner)
+	switch {
+	case errors.Is(err, repository.ErrAlreadyExists):
+	case err != nil:
 		log.WithFields(log.Fields{
 			"url":         reqData.BannerURL,
 			"description": reqData.BannerDescr,
 		}).Error("failed to add new banner: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
+	default:
+		a.audit(r, repository.AuditAddBanner, nil, banner)
 	}
 
+	setETag(w, banner.Version)
 	if err = json.NewEncoder(w).Encode(&banner); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
-func (a *BannersApp) AddRelation(w http.ResponseWriter, r *http.Request) {
+func (a *BannersApp) UpdateBanner(w http.ResponseWriter, r *http.Request) {
 	reqData := struct {
-		SlotID   int `json:"slot"`
-		BannerID int `json:"banner"`
+		BannerID     int                      `json:"banner" validate:"required,id"`
+		BannerURL    *string                  `json:"url" validate:"notempty,url"`
+		BannerDescr  *string                  `json:"description"`
+		StartAt      nullTime                 `json:"start_at"`
+		EndAt        nullTime                 `json:"end_at"`
+		Labels       *[]string                `json:"labels"`
+		FrequencyCap *repository.FrequencyCap `json:"frequency_cap"`
+		CampaignID   *int                     `json:"campaign_id" validate:"id"`
+		Budget       *pacing.Budget           `json:"budget"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := a.repo.AddRelation(r.Context(), reqData.SlotID, reqData.BannerID); err != nil {
+	before, err := a.repo.GetBannerByID(r.Context(), reqData.BannerID)
+	if err != nil {
 		log.WithFields(log.Fields{
-			"slot id":   reqData.SlotID,
 			"banner id": reqData.BannerID,
-		}).Error("failed to add new relation: ", err.Error())
+		}).Error("failed to get banner to update: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	banner, err := a.repo.UpdateBanner(r.Context(), reqData.BannerID, repository.BannerUpdate{
+		URL:          reqData.BannerURL,
+		Description:  reqData.BannerDescr,
+		StartAt:      reqData.StartAt.update(),
+		EndAt:        reqData.EndAt.update(),
+		Labels:       reqData.Labels,
+		FrequencyCap: reqData.FrequencyCap,
+		CampaignID:   reqData.CampaignID,
+		Budget:       reqData.Budget,
+	}, version)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"banner id": reqData.BannerID,
+			"version":   version,
+		}).Error("failed to update banner: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditUpdateBanner, before, banner)
+
+	setETag(w, banner.Version)
+	if err = json.NewEncoder(w).Encode(&banner); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
-func (a *BannersApp) RemoveBanner(w http.ResponseWriter, r *http.Request) {
+func (a *BannersApp) PauseBanner(w http.ResponseWriter, r *http.Request) {
+	a.setBannerStatus(w, r, repository.BannerPaused, repository.AuditPauseBanner)
+}
+
+func (a *BannersApp) ResumeBanner(w http.ResponseWriter, r *http.Request) {
+	a.setBannerStatus(w, r, repository.BannerActive, repository.AuditResumeBanner)
+}
+
+func (a *BannersApp) ArchiveBanner(w http.ResponseWriter, r *http.Request) {
+	a.setBannerStatus(w, r, repository.BannerArchived, repository.AuditArchiveBanner)
+}
+
+func (a *BannersApp) setBannerStatus(w http.ResponseWriter, r *http.Request, status repository.BannerStatus, action string) {
 	reqData := struct {
-		BannerID int `json:"banner"`
+		BannerID int `json:"banner" validate:"required,id"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
 		return
 	}
 
-	if err := a.repo.RemoveBanner(r.Context(), reqData.BannerID); err != nil {
+	before, err := a.repo.GetBannerByID(r.Context(), reqData.BannerID)
+	if err != nil {
 		log.WithFields(log.Fields{
 			"banner id": reqData.BannerID,
-		}).Error("failed to remove banner: ", err.Error())
+		}).Error("failed to get banner to change its status: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	banner, err := a.repo.SetBannerStatus(r.Context(), reqData.BannerID, status)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"banner id": reqData.BannerID,
+			"status":    status,
+		}).Error("failed to change banner status: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, action, before, banner)
+
+	setETag(w, banner.Version)
+	if err = json.NewEncoder(w).Encode(&banner); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
-func (a *BannersApp) RemoveSlot(w http.ResponseWriter, r *http.Request) {
+func (a *BannersApp) AddRelation(w http.ResponseWriter, r *http.Request) {
 	reqData := struct {
-		SlotID int `json:"slot"`
+		SlotID   int `json:"slot" validate:"required,id"`
+		BannerID int `json:"banner" validate:"required,id"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
 		return
 	}
 
-	if err := a.repo.RemoveSlot(r.Context(), reqData.SlotID); err != nil {
+	err := a.repo.AddRelation(r.Context(), reqData.SlotID, reqData.BannerID)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return
+	}
+	if err != nil {
 		log.WithFields(log.Fields{
-			"slot id": reqData.SlotID,
-		}).Error("failed to remove banner: ", err.Error())
+			"slot id":   reqData.SlotID,
+			"banner id": reqData.BannerID,
+		}).Error("failed to add new relation: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	a.audit(r, repository.AuditAddRelation, nil, reqData)
 }
 
-func (a *BannersApp) RemoveRelation(w http.ResponseWriter, r *http.Request) {
+func (a *BannersApp) RemoveBanner(w http.ResponseWriter, r *http.Request) {
 	reqData := struct {
-		SlotID   int `json:"slot"`
-		BannerID int `json:"banner"`
+		BannerID int `json:"banner" validate:"required,id"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
 		return
 	}
 
-	if err := a.repo.RemoveRelation(r.Context(), reqData.SlotID, reqData.BannerID); err != nil {
+	before, err := a.repo.GetBannerByID(r.Context(), reqData.BannerID)
+	if errors.Is(err, repository.ErrNotFound) {
 		log.WithFields(log.Fields{
-			"slot id":   reqData.SlotID,
 			"banner id": reqData.BannerID,
-		}).Error("failed to remove relation: ", err.Error())
+		}).Warning("no banner to remove with the same id or it is already removed")
+		return
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"banner id": reqData.BannerID,
+		}).Error("failed to get banner to remove: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	if err := a.repo.RemoveBanner(r.Context(), reqData.BannerID); err != nil {
+		log.WithFields(log.Fields{
+			"banner id": reqData.BannerID,
+		}).Error("failed to remove banner: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	a.audit(r, repository.AuditRemoveBanner, before, nil)
 }
 
-func (a *BannersApp) Click(w http.ResponseWriter, r *http.Request) { //nolint:dupl
+func (a *BannersApp) RemoveSlot(w http.ResponseWriter, r *http.Request) {
 	reqData := struct {
-		SlotID   int `json:"slot"`
-		BannerID int `json:"banner"`
-		GroupID  int `json:"group"`
+		SlotID int `json:"slot" validate:"required,id"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
 		return
 	}
 
-	logEntry := log.WithFields(log.Fields{
-		"slot id":   reqData.SlotID,
-		"banner id": reqData.BannerID,
-		"group id":  reqData.GroupID,
-	})
-	if err := a.repo.Click(r.Context(), reqData.SlotID, reqData.BannerID, reqData.GroupID); err != nil {
-		logEntry.Error("failed to count the click: ", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	if err := a.repo.RemoveSlot(r.Context(), reqData.SlotID); err != nil {
+		log.WithFields(log.Fields{
+			"slot id": reqData.SlotID,
+		}).Error("failed to remove banner: ", err.Error())
 
-	if err := a.producer.Click(producer.Action{
-		BannerID: reqData.BannerID,
-		SlotID:   reqData.SlotID,
-		GroupID:  reqData.GroupID,
-	}); err != nil {
-		logEntry.Error("failed to publish the click action: ", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	a.audit(r, repository.AuditRemoveSlot, reqData, nil)
 }
 
-func (a *BannersApp) Show(w http.ResponseWriter, r *http.Request) { //nolint:dupl
+func (a *BannersApp) RemoveRelation(w http.ResponseWriter, r *http.Request) {
 	reqData := struct {
-		SlotID   int `json:"slot"`
-		BannerID int `json:"banner"`
-		GroupID  int `json:"group"`
+		SlotID   int `json:"slot" validate:"required,id"`
+		BannerID int `json:"banner" validate:"required,id"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
 		return
 	}
 
-	logEntry := log.WithFields(log.Fields{
-		"slot id":   reqData.SlotID,
-		"banner id": reqData.BannerID,
-		"group id":  reqData.GroupID,
-	})
-	if err := a.repo.Show(r.Context(), reqData.SlotID, reqData.BannerID, reqData.GroupID); err != nil {
-		logEntry.Error("failed to count the showing: ", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	if err := a.repo.RemoveRelation(r.Context(), reqData.SlotID, reqData.BannerID); err != nil {
+		log.WithFields(log.Fields{
+			"slot id":   reqData.SlotID,
+			"banner id": reqData.BannerID,
+		}).Error("failed to remove relation: ", err.Error())
 
-	if err := a.producer.Show(producer.Action{
-		BannerID: reqData.BannerID,
-		SlotID:   reqData.SlotID,
-		GroupID:  reqData.GroupID,
-	}); err != nil {
-		logEntry.Error("failed to publish the show action: ", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	a.audit(r, repository.AuditRemoveRelation, reqData, nil)
 }
 
 func (a *BannersApp) GetAllBanners(w http.ResponseWriter, r *http.Request) {
@@ -247,12 +554,12 @@ func (a *BannersApp) GetAllBanners(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Error("failed to get all available banners: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if err = json.NewEncoder(w).Encode(&banners); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -261,12 +568,12 @@ func (a *BannersApp) GetAllGroups(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Error("failed to get all available social groups: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err.Error(), errorStatusCode(err))
 		return
 	}
 
 	if err := json.NewEncoder(w).Encode(&groups); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -275,37 +582,111 @@ func (a *BannersApp) AddGroup(w http.ResponseWriter, r *http.Request) {
 	reqData := struct {
 		GroupDescr string `json:"description"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
 		return
 	}
 
+	// the same group is returned if it exists, the audit log only has the added ones
 	group, err := a.repo.AddGroup(r.Context(), reqData.GroupDescr)
-	if err != nil {
+	switch {
+	case errors.Is(err, repository.ErrAlreadyExists):
+	case err != nil:
 		log.WithFields(log.Fields{
 			"description": reqData.GroupDescr,
 		}).Error("failed to add new social group: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err.Error(), http.StatusBadRequest)
 		return
+	default:
+		a.audit(r, repository.AuditAddGroup, nil, group)
 	}
 
+	setETag(w, group.Version)
 	if err := json.NewEncoder(w).Encode(&group); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 }
 
+func (a *BannersApp) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		GroupID    int              `json:"group" validate:"required,id"`
+		GroupDescr *string          `json:"description"`
+		Segment    *segment.Segment `json:"segment"`
+		Fallback   *bool            `json:"fallback"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before, err := a.repo.GetGroupByID(r.Context(), reqData.GroupID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"group id": reqData.GroupID,
+		}).Error("failed to get social group to update: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	group, err := a.repo.UpdateGroup(r.Context(), reqData.GroupID, repository.GroupUpdate{
+		Description: reqData.GroupDescr,
+		Segment:     reqData.Segment,
+		Fallback:    reqData.Fallback,
+	}, version)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"group id": reqData.GroupID,
+			"version":  version,
+		}).Error("failed to update social group: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditUpdateGroup, before, group)
+
+	setETag(w, group.Version)
+	if err := json.NewEncoder(w).Encode(&group); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func (a *BannersApp) RemoveGroup(w http.ResponseWriter, r *http.Request) {
 	reqData := struct {
-		GroupID int `json:"group"`
+		GroupID int `json:"group" validate:"required,id"`
 	}{}
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+	if err := decodeRequest(r, &reqData); err != nil {
 		log.Error(parseRequestParamsErr(err))
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeRequestError(w, err)
+		return
+	}
+
+	before, err := a.repo.GetGroupByID(r.Context(), reqData.GroupID)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.WithFields(log.Fields{
+			"group id": reqData.GroupID,
+		}).Warning("no group to remove with the same id or it is already removed")
+		return
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"group id": reqData.GroupID,
+		}).Error("failed to get group to remove: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
 		return
 	}
 
@@ -314,9 +695,123 @@ func (a *BannersApp) RemoveGroup(w http.ResponseWriter, r *http.Request) {
 			"group id": reqData.GroupID,
 		}).Error("failed to remove group: ", err.Error())
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.audit(r, repository.AuditRemoveGroup, before, nil)
+}
+
+func (a *BannersApp) RestoreBanner(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		BannerID int `json:"banner" validate:"required,id"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	if err := a.repo.RestoreBanner(r.Context(), reqData.BannerID); err != nil {
+		log.WithFields(log.Fields{
+			"banner id": reqData.BannerID,
+		}).Error("failed to restore banner: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditRestoreBanner, nil, reqData)
+}
+
+func (a *BannersApp) RestoreSlot(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		SlotID int `json:"slot" validate:"required,id"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
 		return
 	}
+
+	if err := a.repo.RestoreSlot(r.Context(), reqData.SlotID); err != nil {
+		log.WithFields(log.Fields{
+			"slot id": reqData.SlotID,
+		}).Error("failed to restore slot: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditRestoreSlot, nil, reqData)
+}
+
+func (a *BannersApp) RestoreGroup(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		GroupID int `json:"group" validate:"required,id"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	if err := a.repo.RestoreGroup(r.Context(), reqData.GroupID); err != nil {
+		log.WithFields(log.Fields{
+			"group id": reqData.GroupID,
+		}).Error("failed to restore group: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditRestoreGroup, nil, reqData)
+}
+
+// errorStatusCode maps repository errors to the HTTP status code of the response.
+func errorStatusCode(err error) int {
+	var verr *validationError
+	switch {
+	case errors.As(err, &verr):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, repository.ErrNoEligibleBanner):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrStatusTransition), errors.Is(err, repository.ErrAlreadyExists),
+		errors.Is(err, repository.ErrNotShown), errors.Is(err, repository.ErrInUse):
+		return http.StatusConflict
+	case errors.Is(err, token.ErrInvalidToken), errors.Is(err, token.ErrExpiredToken):
+		return http.StatusForbidden
+	case errors.Is(err, errTokenRequired), errors.Is(err, errInvalidBody):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, repository.ErrInvalidFlightTime), errors.Is(err, repository.ErrInvalidCap),
+		errors.Is(err, segment.ErrInvalidSegment), errors.Is(err, schedule.ErrInvalidSchedule),
+		errors.Is(err, pacing.ErrInvalidBudget), errors.Is(err, delivery.ErrInvalidDelivery),
+		errors.Is(err, experiment.ErrInvalidExperiment):
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+// ifMatchVersion reads the expected item version from the If-Match header,
+// zero is returned when the header is absent or matches any version.
+func ifMatchVersion(r *http.Request) (int, error) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" || tag == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(tag, "W/"), `"`))
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid If-Match header %q", tag)
+	}
+
+	return version, nil
+}
+
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(version)))
 }
 
 func parseRequestParamsErr(err error) string {
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type actorKey struct{}
+
+// WithActor stores the name of whoever makes the request, it is written to the audit log.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+
+	return "unknown"
+}
+
+// audit records a successful administrative change. A failure is only logged
+// because the change itself has already been made.
+func (a *BannersApp) audit(r *http.Request, action string, before, after interface{}) {
+	record := repository.AuditRecord{
+		Actor:  actorFromContext(r.Context()),
+		Action: action,
+	}
+
+	logEntry := log.WithFields(log.Fields{
+		"actor":  record.Actor,
+		"action": action,
+	})
+
+	var err error
+	if record.Before, err = marshalAuditState(before); err != nil {
+		logEntry.Error("failed to encode the state before the change: ", err.Error())
+	}
+	if record.After, err = marshalAuditState(after); err != nil {
+		logEntry.Error("failed to encode the state after the change: ", err.Error())
+	}
+
+	if err := a.repo.AddAuditRecord(r.Context(), record); err != nil {
+		logEntry.Error("failed to write audit record: ", err.Error())
+	}
+}
+
+func marshalAuditState(state interface{}) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(state)
+}
+
+func (a *BannersApp) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := a.repo.GetAuditRecords(r.Context(), filter)
+	if err != nil {
+		log.Error("failed to get audit records: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&records); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func parseAuditFilter(r *http.Request) (repository.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := repository.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Limit:  defaultAuditLimit,
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		return filter, err
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxAuditLimit {
+			return filter, fmt.Errorf("limit must be a number from 1 to %d", maxAuditLimit)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter.
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q, expected RFC 3339 format", value)
+	}
+
+	return &t, nil
+}
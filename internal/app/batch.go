@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bubblesupreme/banner_rotation/internal/producer"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const maxBatchEvents = 1000
+
+// batchEvent is an event of the batch, its type tells what happened to the banner.
+type batchEvent struct {
+	eventRequest
+	Type repository.EventType `json:"type"`
+}
+
+// eventResult is the outcome of a single event of the batch.
+type eventResult struct {
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Events counts a batch of shows and clicks. Every event is checked and counted on its own,
+// so the response holds a result for every event in the order of the request. The counted
+// events are published afterwards, a failure to publish them is reported as their result.
+// An event with an event id is handled once, the same as by Click and Show: when it is sent
+// again, in a batch or on its own, it gets the original result.
+func (a *BannersApp) Events(w http.ResponseWriter, r *http.Request) {
+	reqData := make([]batchEvent, 0)
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+	if len(reqData) > maxBatchEvents {
+		writeError(w, fmt.Sprintf("batch can't contain more than %d events", maxBatchEvents), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]eventResult, len(reqData))
+	events := make([]repository.Event, 0, len(reqData))
+	positions := make([]int, 0, len(reqData))
+	imps := make([]token.Impression, 0, len(reqData))
+	flagged := make([]repository.FlaggedEvent, 0)
+	ip := a.clientIP(r)
+	// keys are the reserved idempotency keys of the events, they are settled with the results
+	keys := make([]string, len(reqData))
+	// retryable tells which events failed without any effect, their keys are released
+	retryable := make([]bool, len(reqData))
+	defer func() {
+		// the request is over by now, the keys must be settled even if the client has gone
+		ctx := context.Background()
+		for i, key := range keys {
+			a.settleEvent(ctx, key, results[i], retryable[i])
+		}
+	}()
+	for i, e := range reqData {
+		if e.Type != repository.EventShow && e.Type != repository.EventClick {
+			results[i] = eventResult{Status: http.StatusBadRequest, Error: fmt.Sprintf("unknown event type %q", e.Type)}
+			continue
+		}
+
+		if err := validate(e); err != nil {
+			results[i] = eventResult{Status: http.StatusBadRequest, Error: err.Error()}
+			continue
+		}
+		key, replayed := a.reserveEvent(r.Context(), string(e.Type), e.EventID)
+		if replayed != nil {
+			results[i] = *replayed
+			continue
+		}
+		keys[i] = key
+
+		imp, err := a.impression(e.eventRequest)
+		if err != nil {
+			results[i] = eventResult{Status: errorStatusCode(err), Error: err.Error()}
+			continue
+		}
+		// the events of a batch are screened in their order, so the clicks see the shows before them
+		if e.Type == repository.EventShow {
+			a.addFraudShow(imp)
+		} else if f, ok := a.screenClick(imp, ip, true); ok {
+			flagged = append(flagged, f)
+			results[i] = eventResult{Status: http.StatusOK}
+			continue
+		}
+
+		events = append(events, repository.Event{
+			Type:         e.Type,
+			ImpressionID: imp.ID,
+			SlotID:       imp.SlotID,
+			BannerID:     imp.BannerID,
+			GroupID:      imp.GroupID,
+		})
+		positions = append(positions, i)
+		imps = append(imps, imp)
+	}
+
+	a.storeFlagged(r.Context(), flagged)
+
+	if len(events) > 0 {
+		errs, err := a.repo.ApplyEvents(r.Context(), events)
+		if err != nil {
+			log.Error("failed to apply events: ", err.Error())
+
+			// nothing has been counted, the events can be sent again
+			for _, i := range positions {
+				retryable[i] = true
+			}
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		shows := make([]producer.Action, 0)
+		clicks := make([]producer.Action, 0)
+		// the events in the order of publishing, the shows go before the clicks
+		showEvents := make([]int, 0)
+		clickEvents := make([]int, 0)
+		armEvents := make([]repository.ArmEvent, 0)
+		for j, e := range events {
+			if errs[j] != nil {
+				results[positions[j]] = eventResult{Status: errorStatusCode(errs[j]), Error: errs[j].Error()}
+				retryable[positions[j]] = results[positions[j]].Status >= http.StatusInternalServerError
+				continue
+			}
+
+			results[positions[j]] = eventResult{Status: http.StatusOK}
+			action := producer.Action{BannerID: e.BannerID, SlotID: e.SlotID, GroupID: e.GroupID}
+			if !impressionArm(imps[j]).Empty() {
+				armEvents = append(armEvents, armEvent(imps[j], e.Type))
+			}
+			if e.Type == repository.EventShow {
+				a.addUserShow(r.Context(), imps[j])
+				shows = append(shows, action)
+				showEvents = append(showEvents, j)
+			} else {
+				clicks = append(clicks, action)
+				clickEvents = append(clickEvents, j)
+			}
+		}
+
+		if len(armEvents) > 0 {
+			if err := a.repo.AddArmEvents(r.Context(), armEvents); err != nil {
+				log.Error("failed to count arm events: ", err.Error())
+			}
+		}
+
+		if len(shows) > 0 || len(clicks) > 0 {
+			if sent, err := a.producer.Publish(shows, clicks); err != nil {
+				log.WithField("sent", sent).Error("failed to publish events: ", err.Error())
+
+				// only the events which weren't sent fail, the sent ones mustn't be retried
+				for _, j := range append(showEvents, clickEvents...)[sent:] {
+					results[positions[j]] = eventResult{
+						Status: http.StatusInternalServerError,
+						Error:  fmt.Sprintf("event was counted but failed to be published: %s", err.Error()),
+					}
+				}
+			}
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(&results); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,330 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bubblesupreme/banner_rotation/internal/pacing"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (a *BannersApp) AddAdvertiser(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		Name string `json:"name" validate:"required"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	advertiser, err := a.repo.AddAdvertiser(r.Context(), reqData.Name)
+	if err != nil {
+		log.WithField("name", reqData.Name).Error("failed to add new advertiser: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditAddAdvertiser, nil, advertiser)
+
+	setETag(w, advertiser.Version)
+	if err := json.NewEncoder(w).Encode(&advertiser); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *BannersApp) UpdateAdvertiser(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		AdvertiserID int    `json:"advertiser" validate:"required,id"`
+		Name         string `json:"name" validate:"required"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before, err := a.repo.GetAdvertiserByID(r.Context(), reqData.AdvertiserID)
+	if err != nil {
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	advertiser, err := a.repo.UpdateAdvertiser(r.Context(), reqData.AdvertiserID, reqData.Name, version)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"advertiser id": reqData.AdvertiserID,
+			"version":       version,
+		}).Error("failed to update advertiser: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditUpdateAdvertiser, before, advertiser)
+
+	setETag(w, advertiser.Version)
+	if err := json.NewEncoder(w).Encode(&advertiser); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *BannersApp) RemoveAdvertiser(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		AdvertiserID int `json:"advertiser" validate:"required,id"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	before, err := a.repo.GetAdvertiserByID(r.Context(), reqData.AdvertiserID)
+	if err != nil {
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	if err := a.repo.RemoveAdvertiser(r.Context(), reqData.AdvertiserID); err != nil {
+		log.WithField("advertiser id", reqData.AdvertiserID).Error("failed to remove advertiser: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditRemoveAdvertiser, before, nil)
+}
+
+func (a *BannersApp) GetAllAdvertisers(w http.ResponseWriter, r *http.Request) {
+	advertisers, err := a.repo.GetAllAdvertisers(r.Context())
+	if err != nil {
+		log.Error("failed to get all advertisers: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&advertisers); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *BannersApp) AddCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign := repository.Campaign{}
+	if err := decodeRequest(r, &campaign); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+	if campaign.Name == "" {
+		writeRequestError(w, invalidField("name", "is required"))
+		return
+	}
+	if campaign.Status != "" && !campaign.Status.Valid() {
+		writeError(w, fmt.Sprintf("unknown campaign status %q", campaign.Status), http.StatusBadRequest)
+		return
+	}
+	if campaign.Budget != nil && campaign.Budget.Empty() {
+		campaign.Budget = nil
+	}
+	if err := campaign.ValidateBudget(); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	campaign, err := a.repo.AddCampaign(r.Context(), campaign)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"advertiser id": campaign.AdvertiserID,
+			"name":          campaign.Name,
+		}).Error("failed to add new campaign: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditAddCampaign, nil, campaign)
+
+	setETag(w, campaign.Version)
+	if err := json.NewEncoder(w).Encode(&campaign); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *BannersApp) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		CampaignID int            `json:"campaign" validate:"required,id"`
+		Name       *string        `json:"name"`
+		Budget     *pacing.Budget `json:"budget"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+	if reqData.Name != nil && *reqData.Name == "" {
+		writeRequestError(w, invalidField("name", "is required"))
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before, err := a.repo.GetCampaignByID(r.Context(), reqData.CampaignID)
+	if err != nil {
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	campaign, err := a.repo.UpdateCampaign(r.Context(), reqData.CampaignID, repository.CampaignUpdate{
+		Name:   reqData.Name,
+		Budget: reqData.Budget,
+	}, version)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"campaign id": reqData.CampaignID,
+			"version":     version,
+		}).Error("failed to update campaign: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditUpdateCampaign, before, campaign)
+
+	setETag(w, campaign.Version)
+	if err := json.NewEncoder(w).Encode(&campaign); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *BannersApp) PauseCampaign(w http.ResponseWriter, r *http.Request) {
+	a.setCampaignStatus(w, r, repository.CampaignPaused, repository.AuditPauseCampaign)
+}
+
+func (a *BannersApp) ResumeCampaign(w http.ResponseWriter, r *http.Request) {
+	a.setCampaignStatus(w, r, repository.CampaignActive, repository.AuditResumeCampaign)
+}
+
+func (a *BannersApp) setCampaignStatus(w http.ResponseWriter, r *http.Request, status repository.CampaignStatus, action string) {
+	reqData := struct {
+		CampaignID int `json:"campaign" validate:"required,id"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	before, err := a.repo.GetCampaignByID(r.Context(), reqData.CampaignID)
+	if err != nil {
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	campaign, err := a.repo.SetCampaignStatus(r.Context(), reqData.CampaignID, status)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"campaign id": reqData.CampaignID,
+			"status":      status,
+		}).Error("failed to change campaign status: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, action, before, campaign)
+
+	setETag(w, campaign.Version)
+	if err := json.NewEncoder(w).Encode(&campaign); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *BannersApp) RemoveCampaign(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		CampaignID int `json:"campaign" validate:"required,id"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	before, err := a.repo.GetCampaignByID(r.Context(), reqData.CampaignID)
+	if err != nil {
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	if err := a.repo.RemoveCampaign(r.Context(), reqData.CampaignID); err != nil {
+		log.WithField("campaign id", reqData.CampaignID).Error("failed to remove campaign: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditRemoveCampaign, before, nil)
+}
+
+// GetAllCampaigns lists the campaigns, the optional advertiser parameter selects the campaigns of one advertiser.
+func (a *BannersApp) GetAllCampaigns(w http.ResponseWriter, r *http.Request) {
+	advertiserID := 0
+	if value := r.URL.Query().Get("advertiser"); value != "" {
+		var err error
+		if advertiserID, err = strconv.Atoi(value); err != nil {
+			writeError(w, fmt.Sprintf("invalid advertiser id %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	campaigns, err := a.repo.GetCampaigns(r.Context(), advertiserID)
+	if err != nil {
+		log.Error("failed to get campaigns: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&campaigns); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetRollup sums the banner counters up to the campaign or, with level=advertiser, to the advertiser level.
+func (a *BannersApp) GetRollup(w http.ResponseWriter, r *http.Request) {
+	level := repository.RollupLevel(r.URL.Query().Get("level"))
+	if level == "" {
+		level = repository.RollupCampaign
+	}
+	if !level.Valid() {
+		writeError(w, fmt.Sprintf("unknown rollup level %q", level), http.StatusBadRequest)
+		return
+	}
+
+	rollups, err := a.repo.GetRollup(r.Context(), level)
+	if err != nil {
+		log.WithField("level", level).Error("failed to roll up statistic: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&rollups); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
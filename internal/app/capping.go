@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// capFilters reject the banners which have been shown to the user as many times as their
+// frequency caps allow. The shows of the user are read once, when the first capped banner
+// is checked. The caps aren't applied to anonymous requests, nor when the shows can't be
+// read, so that a failure of the store never leaves the slot empty.
+func (a *BannersApp) capFilters(ctx context.Context, userID string) []repository.BannerFilter {
+	if a.capping == nil || userID == "" {
+		return nil
+	}
+
+	now := time.Now()
+	var (
+		shows  capping.UserShows
+		loaded bool
+		err    error
+	)
+	return []repository.BannerFilter{func(b repository.Banner) bool {
+		if b.FrequencyCap == nil {
+			return true
+		}
+
+		if !loaded {
+			loaded = true
+			if shows, err = a.capping.Shows(ctx, userID, now); err != nil {
+				log.WithField("user id", userID).Error("failed to get user shows, frequency caps aren't applied: ", err.Error())
+			}
+		}
+		if err != nil {
+			return true
+		}
+
+		return shows.Get(b.ID, b.FrequencyCap.Period) < b.FrequencyCap.Limit
+	}}
+}
+
+// addUserShow counts the show of the impression to its user for the frequency caps.
+// A failure is only logged because the show itself has already been counted.
+func (a *BannersApp) addUserShow(ctx context.Context, imp token.Impression) {
+	if a.capping == nil || imp.UserID == "" {
+		return
+	}
+
+	if err := a.capping.AddShow(ctx, imp.UserID, imp.BannerID, time.Now()); err != nil {
+		impressionLogEntry(imp).WithField("user id", imp.UserID).Error("failed to count user show: ", err.Error())
+	}
+}
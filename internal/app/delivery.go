@@ -0,0 +1,90 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bubblesupreme/banner_rotation/internal/delivery"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type relationDelivery struct {
+	SlotID   int                `json:"slot" validate:"required,id"`
+	BannerID int                `json:"banner" validate:"required,id"`
+	Delivery *delivery.Settings `json:"delivery,omitempty"`
+}
+
+func (a *BannersApp) SetRelationDelivery(w http.ResponseWriter, r *http.Request) {
+	reqData := relationDelivery{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+	if reqData.Delivery == nil {
+		writeRequestError(w, invalidField("delivery", "is required"))
+		return
+	}
+
+	if err := a.repo.SetRelationDelivery(r.Context(), reqData.SlotID, reqData.BannerID, reqData.Delivery); err != nil {
+		log.WithFields(log.Fields{
+			"slot id":   reqData.SlotID,
+			"banner id": reqData.BannerID,
+		}).Error("failed to set relation delivery: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditSetDelivery, nil, reqData)
+}
+
+func (a *BannersApp) RemoveRelationDelivery(w http.ResponseWriter, r *http.Request) {
+	reqData := relationDelivery{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+	reqData.Delivery = nil
+
+	if err := a.repo.SetRelationDelivery(r.Context(), reqData.SlotID, reqData.BannerID, nil); err != nil {
+		log.WithFields(log.Fields{
+			"slot id":   reqData.SlotID,
+			"banner id": reqData.BannerID,
+		}).Error("failed to reset relation delivery: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditRemoveDelivery, reqData, nil)
+}
+
+// GetDeliveryReport tells whether the guaranteed banners get their shares, the optional slot parameter selects one slot.
+func (a *BannersApp) GetDeliveryReport(w http.ResponseWriter, r *http.Request) {
+	slotID := 0
+	if value := r.URL.Query().Get("slot"); value != "" {
+		var err error
+		if slotID, err = strconv.Atoi(value); err != nil {
+			writeError(w, fmt.Sprintf("invalid slot id %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	reports, err := a.repo.GetDeliveryReport(r.Context(), slotID)
+	if err != nil {
+		log.WithField("slot id", slotID).Error("failed to get delivery report: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&reports); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
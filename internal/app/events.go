@@ -0,0 +1,230 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bubblesupreme/banner_rotation/internal/producer"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errTokenRequired = errors.New("impression token is required")
+
+type bannerResponse struct {
+	repository.Banner
+	Token    string `json:"token,omitempty"`
+	ClickURL string `json:"click_url,omitempty"`
+	PixelURL string `json:"pixel_url,omitempty"`
+	// Shown tells that the show has already been counted by GetBanner,
+	// the client must not report it again.
+	Shown bool `json:"shown,omitempty"`
+	// ArmRef is the experiment arm or the holdout which has chosen the banner,
+	// clients without tokens report it back with the events.
+	repository.ArmRef
+}
+
+// publishError is returned when an event has been counted but couldn't be published.
+type publishError struct {
+	action string
+	err    error
+}
+
+func (e *publishError) Error() string {
+	return fmt.Sprintf("failed to publish the %s action: %v", e.action, e.err)
+}
+
+func (e *publishError) Unwrap() error {
+	return e.err
+}
+
+// eventRequest refers to a served banner by its impression token or,
+// when the tokens are disabled or the raw ids are allowed, by the raw ids.
+type eventRequest struct {
+	Token    string `json:"token"`
+	SlotID   int    `json:"slot" validate:"id"`
+	BannerID int    `json:"banner" validate:"id"`
+	GroupID  int    `json:"group" validate:"id"`
+	// UserID is taken into account by the frequency caps, it is carried by the token if there is one.
+	UserID string `json:"user_id"`
+	// EventID lets a client retry the event safely, the same as the Idempotency-Key header.
+	EventID string `json:"event_id"`
+	// ArmRef is counted in the statistics of the experiment arm or of the holdout,
+	// it is carried by the token if there is one.
+	repository.ArmRef
+}
+
+func (a *BannersApp) Click(w http.ResponseWriter, r *http.Request) { //nolint:dupl
+	reqData := eventRequest{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	a.idempotent(w, r, "click", reqData.EventID, func(w http.ResponseWriter) error {
+		imp, err := a.impression(reqData)
+		if err != nil {
+			log.Warning("click was rejected: ", err.Error())
+
+			writeError(w, err.Error(), errorStatusCode(err))
+			return err
+		}
+
+		if err := a.click(r.Context(), imp, a.clientIP(r)); err != nil {
+			impressionLogEntry(imp).Error(err.Error())
+
+			writeError(w, err.Error(), errorStatusCode(err))
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (a *BannersApp) Show(w http.ResponseWriter, r *http.Request) { //nolint:dupl
+	reqData := eventRequest{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	a.idempotent(w, r, "show", reqData.EventID, func(w http.ResponseWriter) error {
+		imp, err := a.impression(reqData)
+		if err != nil {
+			log.Warning("show was rejected: ", err.Error())
+
+			writeError(w, err.Error(), errorStatusCode(err))
+			return err
+		}
+
+		if err := a.show(r.Context(), imp); err != nil {
+			impressionLogEntry(imp).Error(err.Error())
+
+			writeError(w, err.Error(), errorStatusCode(err))
+			return err
+		}
+
+		return nil
+	})
+}
+
+// impression resolves the event request into the impression it refers to.
+func (a *BannersApp) impression(req eventRequest) (token.Impression, error) {
+	if a.tokens == nil || (a.rawIDs && req.Token == "") {
+		// the raw ids are required when there is no token to carry them
+		verr := &validationError{}
+		if req.SlotID == 0 {
+			verr.add("slot", "is required")
+		}
+		if req.BannerID == 0 {
+			verr.add("banner", "is required")
+		}
+		if req.GroupID == 0 {
+			verr.add("group", "is required")
+		}
+		if len(verr.Fields) > 0 {
+			return token.Impression{}, verr
+		}
+
+		return token.Impression{
+			SlotID:     req.SlotID,
+			BannerID:   req.BannerID,
+			GroupID:    req.GroupID,
+			UserID:     req.UserID,
+			Experiment: req.ExperimentID,
+			Arm:        req.Arm,
+			Holdout:    req.Holdout,
+		}, nil
+	}
+
+	if req.Token == "" {
+		return token.Impression{}, errTokenRequired
+	}
+
+	return a.tokens.Parse(req.Token)
+}
+
+// click counts the click and publishes it, impressions without an id come from raw ids.
+// A click flagged by the fraud detector is silently left uncounted.
+func (a *BannersApp) click(ctx context.Context, imp token.Impression, ip string) error {
+	if flagged, ok := a.screenClick(imp, ip, false); ok {
+		a.storeFlagged(ctx, []repository.FlaggedEvent{flagged})
+		return nil
+	}
+
+	var err error
+	if imp.ID == "" {
+		err = a.repo.Click(ctx, imp.SlotID, imp.BannerID, imp.GroupID)
+	} else {
+		err = a.repo.ClickImpression(ctx, imp.ID, imp.SlotID, imp.BannerID, imp.GroupID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to count the click: %w", err)
+	}
+	a.addArmEvent(ctx, imp, repository.EventClick)
+
+	if err := a.producer.Click(impressionAction(imp)); err != nil {
+		return &publishError{action: "click", err: err}
+	}
+
+	return nil
+}
+
+// show counts the show and publishes it, impressions without an id come from raw ids.
+func (a *BannersApp) show(ctx context.Context, imp token.Impression) error {
+	if err := a.countShow(ctx, imp); err != nil {
+		return err
+	}
+
+	return a.publishShow(imp)
+}
+
+func (a *BannersApp) countShow(ctx context.Context, imp token.Impression) error {
+	var err error
+	if imp.ID == "" {
+		err = a.repo.Show(ctx, imp.SlotID, imp.BannerID, imp.GroupID)
+	} else {
+		err = a.repo.ShowImpression(ctx, imp.ID, imp.SlotID, imp.BannerID, imp.GroupID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to count the showing: %w", err)
+	}
+	a.addUserShow(ctx, imp)
+	a.addArmEvent(ctx, imp, repository.EventShow)
+	a.addFraudShow(imp)
+
+	return nil
+}
+
+func (a *BannersApp) publishShow(imp token.Impression) error {
+	if err := a.producer.Show(impressionAction(imp)); err != nil {
+		return &publishError{action: "show", err: err}
+	}
+
+	return nil
+}
+
+func impressionAction(imp token.Impression) producer.Action {
+	return producer.Action{
+		BannerID: imp.BannerID,
+		SlotID:   imp.SlotID,
+		GroupID:  imp.GroupID,
+	}
+}
+
+func impressionLogEntry(imp token.Impression) *log.Entry {
+	return log.WithFields(log.Fields{
+		"impression id": imp.ID,
+		"slot id":       imp.SlotID,
+		"banner id":     imp.BannerID,
+		"group id":      imp.GroupID,
+	})
+}
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bubblesupreme/banner_rotation/internal/experiment"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+type experimentReport struct {
+	repository.Experiment
+	Arms []experiment.ArmReport `json:"report"`
+}
+
+func (a *BannersApp) AddExperiment(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		SlotID int              `json:"slot" validate:"required,id"`
+		Name   string           `json:"name" validate:"required"`
+		Arms   []experiment.Arm `json:"arms" validate:"required"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	e, err := a.repo.AddExperiment(r.Context(), repository.Experiment{SlotID: reqData.SlotID, Name: reqData.Name, Arms: reqData.Arms})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"slot id": reqData.SlotID,
+			"name":    reqData.Name,
+		}).Error("failed to add experiment: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditAddExperiment, nil, e)
+
+	if err = json.NewEncoder(w).Encode(&e); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetExperiments returns the experiments, the optional slot parameter selects one slot.
+func (a *BannersApp) GetExperiments(w http.ResponseWriter, r *http.Request) {
+	slotID := 0
+	if value := r.URL.Query().Get("slot"); value != "" {
+		var err error
+		if slotID, err = strconv.Atoi(value); err != nil {
+			writeError(w, fmt.Sprintf("invalid slot id %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	experiments, err := a.repo.GetExperiments(r.Context(), slotID)
+	if err != nil {
+		log.WithField("slot id", slotID).Error("failed to get experiments: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&experiments); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// StopExperiment gives the traffic of the slot back to its bandit, the statistics of the arms are kept for the report.
+func (a *BannersApp) StopExperiment(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		ExperimentID int `json:"experiment" validate:"required,id"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	e, err := a.repo.StopExperiment(r.Context(), reqData.ExperimentID)
+	if err != nil {
+		log.WithField("experiment id", reqData.ExperimentID).Error("failed to stop experiment: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditStopExperiment, nil, e)
+
+	if err = json.NewEncoder(w).Encode(&e); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetExperimentReport compares the click rates and the regret of the experiment arms.
+func (a *BannersApp) GetExperimentReport(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("experiment")
+	experimentID, err := strconv.Atoi(value)
+	if err != nil {
+		writeError(w, fmt.Sprintf("invalid experiment id %q", value), http.StatusBadRequest)
+		return
+	}
+
+	e, err := a.repo.GetExperimentByID(r.Context(), experimentID)
+	if err != nil {
+		log.WithField("experiment id", experimentID).Error("failed to get experiment: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	counters, err := a.repo.GetExperimentCounters(r.Context(), experimentID)
+	if err != nil {
+		log.WithField("experiment id", experimentID).Error("failed to get experiment counters: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := experimentReport{Experiment: e, Arms: experiment.Compare(e.Arms, counters)}
+	if err := json.NewEncoder(w).Encode(&report); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetHoldoutReport compares the click rates of the bandits and of the holdout, the optional slot parameter selects one slot.
+func (a *BannersApp) GetHoldoutReport(w http.ResponseWriter, r *http.Request) {
+	slotID := 0
+	if value := r.URL.Query().Get("slot"); value != "" {
+		var err error
+		if slotID, err = strconv.Atoi(value); err != nil {
+			writeError(w, fmt.Sprintf("invalid slot id %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	reports, err := a.repo.GetHoldoutReport(r.Context(), slotID)
+	if err != nil {
+		log.WithField("slot id", slotID).Error("failed to get holdout report: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&reports); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// assignArm puts the request into the holdout or into an arm of the experiment running in the slot.
+// The unit of the split is the user, anonymous requests are split by their request id or at random.
+// The slot bandit serves the request when the experiment can't be read.
+func (a *BannersApp) assignArm(ctx context.Context, r *http.Request, slotID int, userID string) repository.ArmRef {
+	unit := userID
+	if unit == "" {
+		unit = r.Header.Get(requestIDHeader)
+	}
+	if unit == "" {
+		unit = randomUnit()
+	}
+	if experiment.InHoldout(unit, a.holdout) {
+		return repository.ArmRef{Holdout: true}
+	}
+
+	e, err := a.repo.GetRunningExperiment(ctx, slotID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.ArmRef{}
+	}
+	if err != nil {
+		log.WithField("slot id", slotID).Error("failed to get running experiment, slot bandit is used: ", err.Error())
+		return repository.ArmRef{}
+	}
+
+	return repository.ArmRef{ExperimentID: e.ID, Arm: experiment.Assign(e.ID, unit, e.Arms).Name}
+}
+
+func randomUnit() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Error("failed to generate random unit: ", err.Error())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// addArmEvent counts the event of the impression in the statistics of its arm or of the holdout.
+// A failure is only logged because the event itself has already been counted.
+func (a *BannersApp) addArmEvent(ctx context.Context, imp token.Impression, eventType repository.EventType) {
+	if impressionArm(imp).Empty() {
+		return
+	}
+
+	if err := a.repo.AddArmEvents(ctx, []repository.ArmEvent{armEvent(imp, eventType)}); err != nil {
+		impressionLogEntry(imp).WithFields(log.Fields{
+			"arm":     imp.Arm,
+			"holdout": imp.Holdout,
+		}).Error("failed to count arm event: ", err.Error())
+	}
+}
+
+func impressionArm(imp token.Impression) repository.ArmRef {
+	return repository.ArmRef{ExperimentID: imp.Experiment, Arm: imp.Arm, Holdout: imp.Holdout}
+}
+
+func armEvent(imp token.Impression, eventType repository.EventType) repository.ArmEvent {
+	return repository.ArmEvent{
+		ArmRef: impressionArm(imp),
+		Event: repository.Event{
+			Type:         eventType,
+			ImpressionID: imp.ID,
+			SlotID:       imp.SlotID,
+			BannerID:     imp.BannerID,
+			GroupID:      imp.GroupID,
+		},
+	}
+}
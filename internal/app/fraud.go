@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/fraud"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultFraudLimit = 100
+	maxFraudLimit     = 1000
+)
+
+// screenClick checks the click with the fraud detector. A suspicious click is returned
+// as a flagged event and must not be counted, so it never gets into the bandit statistics.
+// Delayed clicks are reported some time after they happen, so their timing isn't checked.
+func (a *BannersApp) screenClick(imp token.Impression, ip string, delayed bool) (repository.FlaggedEvent, bool) {
+	if a.fraud == nil {
+		return repository.FlaggedEvent{}, false
+	}
+
+	e := fraudEvent(imp, ip)
+	e.Delayed = delayed
+	reasons := a.fraud.Click(e, time.Now())
+	if len(reasons) == 0 {
+		return repository.FlaggedEvent{}, false
+	}
+	impressionLogEntry(imp).WithFields(log.Fields{
+		"ip":      ip,
+		"reasons": reasons,
+	}).Warning("click looks fraudulent and isn't counted")
+
+	return repository.FlaggedEvent{
+		Type:         repository.EventClick,
+		ImpressionID: imp.ID,
+		SlotID:       imp.SlotID,
+		BannerID:     imp.BannerID,
+		GroupID:      imp.GroupID,
+		UserID:       imp.UserID,
+		IP:           ip,
+		Reasons:      reasons,
+	}, true
+}
+
+// storeFlagged keeps the flagged events for review unless they are dropped.
+// A failure is only logged because the events are not counted anyway.
+func (a *BannersApp) storeFlagged(ctx context.Context, events []repository.FlaggedEvent) {
+	if a.dropFraud || len(events) == 0 {
+		return
+	}
+
+	if err := a.repo.AddFlaggedEvents(ctx, events); err != nil {
+		log.Error("failed to store flagged events: ", err.Error())
+	}
+}
+
+// addFraudShow lets the fraud detector check the clicks of the shown impression.
+func (a *BannersApp) addFraudShow(imp token.Impression) {
+	if a.fraud == nil {
+		return
+	}
+
+	a.fraud.Show(fraudEvent(imp, ""), time.Now())
+}
+
+func fraudEvent(imp token.Impression, ip string) fraud.Event {
+	return fraud.Event{
+		ImpressionID: imp.ID,
+		SlotID:       imp.SlotID,
+		BannerID:     imp.BannerID,
+		GroupID:      imp.GroupID,
+		UserID:       imp.UserID,
+		IP:           ip,
+	}
+}
+
+// clientIP takes the client address from the peer address. X-Forwarded-For is only believed when
+// the peer is a trusted proxy: the entries are walked from the right, where the proxies append them,
+// and the first address which isn't a trusted proxy is the client.
+func (a *BannersApp) clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !a.trustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		entry := strings.TrimSpace(forwarded[i])
+		if entry == "" {
+			continue
+		}
+		ip = entry
+		if !a.trustedProxy(ip) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func (a *BannersApp) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range a.trustedProxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetFraudReport counts the flagged events by their reasons and lists the latest of them.
+// The events are selected by the optional slot, from and to parameters, limit caps the list.
+func (a *BannersApp) GetFraudReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := repository.FraudFilter{Limit: defaultFraudLimit}
+
+	var err error
+	if value := query.Get("slot"); value != "" {
+		if filter.SlotID, err = strconv.Atoi(value); err != nil {
+			writeError(w, "invalid slot id "+strconv.Quote(value), http.StatusBadRequest)
+			return
+		}
+	}
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxFraudLimit {
+			writeError(w, "limit must be a number from 1 to "+strconv.Itoa(maxFraudLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := a.repo.GetFraudReport(r.Context(), filter)
+	if err != nil {
+		log.Error("failed to get fraud report: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&report); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bubblesupreme/banner_rotation/internal/idempotency"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// idempotent handles the request once per idempotency key, a repeated delivery with the
+// same key gets the remembered response. The key is taken from the Idempotency-Key header
+// or from the event id of the request, requests without a key are always handled.
+//
+// A response is remembered unless the request failed without any effect, i.e. with
+// a server error before the event was counted, such a request can be retried.
+func (a *BannersApp) idempotent(w http.ResponseWriter, r *http.Request, scope, eventID string, handle func(w http.ResponseWriter) error) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		key = eventID
+	}
+	if a.idempotency == nil || key == "" {
+		_ = handle(w)
+		return
+	}
+	key = scope + ":" + key
+	logEntry := log.WithField("idempotency key", key)
+
+	resp, err := a.idempotency.Reserve(r.Context(), key)
+	switch {
+	case errors.Is(err, idempotency.ErrInProgress):
+		logEntry.Warning(err.Error())
+
+		writeError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		logEntry.Error("failed to reserve idempotency key: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	case resp != nil:
+		logEntry.Info("repeated request gets the original response")
+
+		replayResponse(w, resp)
+		return
+	}
+
+	rec := &responseRecorder{ResponseWriter: w}
+	err = handle(rec)
+
+	// the request is over by now, the key must be settled even if the client has gone
+	ctx := context.Background()
+	pubErr := &publishError{}
+	if err != nil && errorStatusCode(err) >= http.StatusInternalServerError && !errors.As(err, &pubErr) {
+		if err := a.idempotency.Release(ctx, key); err != nil {
+			logEntry.Error("failed to release idempotency key: ", err.Error())
+		}
+		return
+	}
+
+	if err := a.idempotency.Save(ctx, key, rec.response()); err != nil {
+		logEntry.Error("failed to save response for idempotency key: ", err.Error())
+	}
+}
+
+// reserveEvent claims the event id of a batch event under the scope of its type, the same scope
+// as the one of the single event handlers, so that an event sent again in any way isn't counted twice.
+// It returns the key to settle once the event is handled or the result of the event handled before.
+func (a *BannersApp) reserveEvent(ctx context.Context, scope, eventID string) (string, *eventResult) {
+	if a.idempotency == nil || eventID == "" {
+		return "", nil
+	}
+	key := scope + ":" + eventID
+	logEntry := log.WithField("idempotency key", key)
+
+	resp, err := a.idempotency.Reserve(ctx, key)
+	switch {
+	case errors.Is(err, idempotency.ErrInProgress):
+		logEntry.Warning(err.Error())
+		return "", &eventResult{Status: http.StatusConflict, Error: err.Error()}
+	case err != nil:
+		logEntry.Error("failed to reserve idempotency key: ", err.Error())
+		return "", &eventResult{Status: http.StatusInternalServerError, Error: err.Error()}
+	case resp != nil:
+		logEntry.Info("repeated event gets the original result")
+		return "", replayedResult(resp)
+	}
+
+	return key, nil
+}
+
+// settleEvent remembers the result of the batch event as the response of a single event request,
+// or frees the key if the event failed without any effect.
+func (a *BannersApp) settleEvent(ctx context.Context, key string, result eventResult, retryable bool) {
+	if key == "" {
+		return
+	}
+	logEntry := log.WithField("idempotency key", key)
+
+	if retryable {
+		if err := a.idempotency.Release(ctx, key); err != nil {
+			logEntry.Error("failed to release idempotency key: ", err.Error())
+		}
+		return
+	}
+
+	resp := idempotency.Response{StatusCode: result.Status}
+	if result.Error != "" {
+		body, err := json.Marshal(errorResponse{Error: errorBody{Status: result.Status, Message: result.Error}})
+		if err != nil {
+			logEntry.Error("failed to encode result for idempotency key: ", err.Error())
+			return
+		}
+		resp.ContentType = jsonContentType
+		resp.Body = append(body, '\n')
+	}
+	if err := a.idempotency.Save(ctx, key, resp); err != nil {
+		logEntry.Error("failed to save result for idempotency key: ", err.Error())
+	}
+}
+
+// replayedResult turns the remembered response into the result of a batch event.
+func replayedResult(resp *idempotency.Response) *eventResult {
+	result := &eventResult{Status: resp.StatusCode}
+	if resp.StatusCode == http.StatusOK {
+		return result
+	}
+
+	body := errorResponse{}
+	if err := json.Unmarshal(resp.Body, &body); err == nil && body.Error.Message != "" {
+		result.Error = body.Error.Message
+	} else {
+		result.Error = string(bytes.TrimSpace(resp.Body))
+	}
+
+	return result
+}
+
+func replayResponse(w http.ResponseWriter, resp *idempotency.Response) {
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(resp.StatusCode)
+	if _, err := w.Write(resp.Body); err != nil {
+		log.Error("failed to write remembered response: ", err.Error())
+	}
+}
+
+// responseRecorder passes the response through and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *responseRecorder) response() idempotency.Response {
+	resp := idempotency.Response{
+		StatusCode:  rec.statusCode,
+		ContentType: rec.Header().Get("Content-Type"),
+		Body:        rec.body.Bytes(),
+	}
+	if resp.StatusCode == 0 {
+		resp.StatusCode = http.StatusOK
+	}
+
+	return resp
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/segment"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const maxPageSlots = 20
+
+// pageSlot is the banner chosen for a slot of the page, it is empty when
+// every banner of the slot is already on the page or competes with one.
+type pageSlot struct {
+	SlotID int             `json:"slot"`
+	Banner *bannerResponse `json:"banner"`
+}
+
+// GetPageBanners chooses banners for all the slots of a page. The slots are filled in the
+// requested order and every banner appears on the page at most once, neither do banners
+// sharing a label, so competitors never stand side by side.
+// With count_show set the shows are counted only once the whole page is chosen, so a failed
+// request counts nothing. A banner whose show can't be counted stays on the page without
+// the shown flag, the client reports its show then.
+func (a *BannersApp) GetPageBanners(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		SlotIDs    []int              `json:"slots" validate:"required,id"`
+		GroupID    int                `json:"group" validate:"id"`
+		Attributes segment.Attributes `json:"attributes"`
+		UserID     string             `json:"user_id"`
+		CountShow  bool               `json:"count_show"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+	if err := validatePageSlots(reqData.SlotIDs); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if reqData.GroupID, err = a.requestGroup(r.Context(), reqData.GroupID, reqData.Attributes); err != nil {
+		writeErrorStatus(w, err)
+		return
+	}
+
+	shown := make(map[int]bool)
+	labels := make(map[string]bool)
+	notShown := func(b repository.Banner) bool {
+		return !shown[b.ID] && !b.SharesLabel(labels)
+	}
+
+	filters := append(a.capFilters(r.Context(), reqData.UserID), notShown)
+
+	page := make([]pageSlot, 0, len(reqData.SlotIDs))
+	// served holds the impressions of the page slots, the empty slots have none
+	served := make([]token.Impression, 0, len(reqData.SlotIDs))
+	for _, slotID := range reqData.SlotIDs {
+		logEntry := log.WithFields(log.Fields{
+			"slot id":  slotID,
+			"group id": reqData.GroupID,
+		})
+
+		arm := a.assignArm(r.Context(), r, slotID, reqData.UserID)
+		banner, err := a.repo.GetBanner(r.Context(), slotID, reqData.GroupID, arm, filters...)
+		if errors.Is(err, repository.ErrNoEligibleBanner) {
+			logEntry.Warning("slot of the page is left empty: ", err.Error())
+			page = append(page, pageSlot{SlotID: slotID})
+			served = append(served, token.Impression{})
+			continue
+		}
+		if err != nil {
+			a.slotFailed(slotID, err)
+			logEntry.Error("failed to get banner: ", err.Error())
+
+			writeError(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		shown[banner.ID] = true
+		for _, l := range banner.Labels {
+			labels[l] = true
+		}
+
+		resp, imp, err := a.serve(token.Impression{
+			SlotID:     slotID,
+			BannerID:   banner.ID,
+			GroupID:    reqData.GroupID,
+			UserID:     reqData.UserID,
+			Experiment: arm.ExperimentID,
+			Arm:        arm.Arm,
+			Holdout:    arm.Holdout,
+		}, banner)
+		if err != nil {
+			writeError(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		page = append(page, pageSlot{SlotID: slotID, Banner: &resp})
+		served = append(served, imp)
+	}
+
+	if reqData.CountShow {
+		for i, slot := range page {
+			if slot.Banner != nil {
+				// the failure is logged, the banner is left without the shown flag
+				_ = a.countServed(r.Context(), served[i], slot.Banner)
+			}
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(&page); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func validatePageSlots(slotIDs []int) error {
+	if len(slotIDs) == 0 {
+		return fmt.Errorf("page must have at least one slot")
+	}
+	if len(slotIDs) > maxPageSlots {
+		return fmt.Errorf("page can't have more than %d slots", maxPageSlots)
+	}
+
+	seen := make(map[int]bool, len(slotIDs))
+	for _, id := range slotIDs {
+		if seen[id] {
+			return fmt.Errorf("slot %d is requested twice", id)
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
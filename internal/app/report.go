@@ -0,0 +1,220 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxReportBuckets = 10000
+	csvFlushRows     = 100
+)
+
+var granularityLengths = map[repository.Granularity]time.Duration{
+	repository.GranularityHour: time.Hour,
+	repository.GranularityDay:  24 * time.Hour,
+	repository.GranularityWeek: 7 * 24 * time.Hour,
+}
+
+type reportPoint struct {
+	Time        time.Time `json:"time"`
+	Impressions int64     `json:"impressions"`
+	Clicks      int64     `json:"clicks"`
+	CTR         float64   `json:"ctr"`
+}
+
+// reportSeries holds the points of a combination of the dimensions, the dimensions
+// which the report isn't grouped by are left out.
+type reportSeries struct {
+	SlotID     int           `json:"slot,omitempty"`
+	BannerID   int           `json:"banner,omitempty"`
+	GroupID    int           `json:"group,omitempty"`
+	CampaignID int           `json:"campaign,omitempty"`
+	Points     []reportPoint `json:"points"`
+}
+
+type reportResponse struct {
+	From        time.Time              `json:"from"`
+	To          time.Time              `json:"to"`
+	Granularity repository.Granularity `json:"granularity"`
+	GroupBy     []repository.Dimension `json:"group_by"`
+	Series      []reportSeries         `json:"series"`
+}
+
+// GetReport returns the impressions, the clicks and the click rate of the events from the from parameter
+// up to the to parameter or now, summed up by the granularity (hour, day or week, day by default) for
+// every combination of the comma separated group_by dimensions (slot, banner, group, campaign).
+// The report is streamed as CSV when the client accepts text/csv.
+func (a *BannersApp) GetReport(w http.ResponseWriter, r *http.Request) {
+	query, err := parseReportQuery(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logEntry := log.WithFields(log.Fields{
+		"from":        query.From,
+		"to":          query.To,
+		"granularity": query.Granularity,
+		"group by":    query.GroupBy,
+	})
+	if strings.Contains(r.Header.Get("Accept"), csvContentType) {
+		a.writeCSVReport(w, r, query, logEntry)
+		return
+	}
+
+	resp := reportResponse{From: query.From, To: query.To, Granularity: query.Granularity, GroupBy: query.GroupBy, Series: make([]reportSeries, 0)}
+	err = a.repo.GetReport(r.Context(), query, func(row repository.ReportRow) error {
+		// the rows come ordered by the dimensions, so a series ends when they change
+		if n := len(resp.Series); n == 0 || !sameSeries(resp.Series[n-1], row) {
+			resp.Series = append(resp.Series, reportSeries{
+				SlotID:     row.SlotID,
+				BannerID:   row.BannerID,
+				GroupID:    row.GroupID,
+				CampaignID: row.CampaignID,
+			})
+		}
+		s := &resp.Series[len(resp.Series)-1]
+		s.Points = append(s.Points, reportPoint{Time: row.Time, Impressions: row.Impressions, Clicks: row.Clicks, CTR: row.CTR})
+		return nil
+	})
+	if err != nil {
+		logEntry.Error("failed to get report: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&resp); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeCSVReport streams the rows of the report, the header is written with the first row
+// so that a failing query still responds with an error status.
+func (a *BannersApp) writeCSVReport(w http.ResponseWriter, r *http.Request, query repository.ReportQuery, logEntry *log.Entry) {
+	header := []string{"time"}
+	for _, d := range query.GroupBy {
+		header = append(header, string(d))
+	}
+	header = append(header, "impressions", "clicks", "ctr")
+
+	cw := csv.NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+	rows := 0
+	writeHeader := func() error {
+		w.Header().Set("Content-Type", csvContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="report.csv"`)
+		return cw.Write(header)
+	}
+
+	err := a.repo.GetReport(r.Context(), query, func(row repository.ReportRow) error {
+		if rows == 0 {
+			if err := writeHeader(); err != nil {
+				return err
+			}
+		}
+		record := []string{row.Time.Format(time.RFC3339)}
+		for _, d := range query.GroupBy {
+			record = append(record, strconv.Itoa(row.Dimension(d)))
+		}
+		record = append(record,
+			strconv.FormatInt(row.Impressions, 10),
+			strconv.FormatInt(row.Clicks, 10),
+			strconv.FormatFloat(row.CTR, 'f', -1, 64))
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%csvFlushRows == 0 {
+			cw.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return cw.Error()
+	})
+	if err != nil {
+		logEntry.WithField("rows", rows).Error("failed to stream report: ", err.Error())
+
+		if rows == 0 {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if rows == 0 {
+		if err := writeHeader(); err != nil {
+			logEntry.Error("failed to write report: ", err.Error())
+			return
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		logEntry.Error("failed to write report: ", err.Error())
+	}
+}
+
+func parseReportQuery(r *http.Request) (repository.ReportQuery, error) {
+	query := repository.ReportQuery{Granularity: repository.GranularityDay, GroupBy: make([]repository.Dimension, 0)}
+
+	from, err := parseTimeParam(r.URL.Query().Get("from"))
+	if err != nil {
+		return query, err
+	}
+	if from == nil {
+		return query, errors.New("report needs the from time")
+	}
+	to, err := parseTimeParam(r.URL.Query().Get("to"))
+	if err != nil {
+		return query, err
+	}
+	query.From, query.To = from.UTC(), time.Now().UTC()
+	if to != nil {
+		query.To = to.UTC()
+	}
+	if !query.From.Before(query.To) {
+		return query, errors.New("report from time must be before its to time")
+	}
+
+	if value := r.URL.Query().Get("granularity"); value != "" {
+		query.Granularity = repository.Granularity(value)
+		if !query.Granularity.Valid() {
+			return query, fmt.Errorf("unknown granularity %q", value)
+		}
+	}
+	if query.To.Sub(query.From)/granularityLengths[query.Granularity] >= maxReportBuckets {
+		return query, fmt.Errorf("report can't contain more than %d %s buckets", maxReportBuckets, query.Granularity)
+	}
+
+	if value := r.URL.Query().Get("group_by"); value != "" {
+		seen := make(map[repository.Dimension]bool)
+		for _, name := range strings.Split(value, ",") {
+			d := repository.Dimension(strings.TrimSpace(name))
+			if !d.Valid() {
+				return query, fmt.Errorf("unknown dimension %q", name)
+			}
+			if !seen[d] {
+				seen[d] = true
+				query.GroupBy = append(query.GroupBy, d)
+			}
+		}
+	}
+
+	return query, nil
+}
+
+func sameSeries(s reportSeries, row repository.ReportRow) bool {
+	return s.SlotID == row.SlotID && s.BannerID == row.BannerID && s.GroupID == row.GroupID && s.CampaignID == row.CampaignID
+}
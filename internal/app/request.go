@@ -0,0 +1,264 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const jsonContentType = "application/json"
+
+var errInvalidBody = errors.New("invalid request body")
+
+// fieldError tells what is wrong with a field of the request.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationError lists all the invalid fields of a request.
+type validationError struct {
+	Fields []fieldError
+}
+
+func (e *validationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, f.Field+" "+f.Message)
+	}
+
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// invalidField is the validation error of a single field.
+func invalidField(field, message string) error {
+	verr := &validationError{}
+	verr.add(field, message)
+
+	return verr
+}
+
+func (e *validationError) add(field, message string) {
+	e.Fields = append(e.Fields, fieldError{Field: field, Message: message})
+}
+
+// errorResponse is the envelope of all the error responses.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Status  int          `json:"status"`
+	Message string       `json:"message"`
+	Fields  []fieldError `json:"fields,omitempty"`
+}
+
+// writeError responds with the error envelope, it takes the place of http.Error
+// so that every endpoint reports the errors in the same format.
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeErrorBody(w, errorBody{Status: status, Message: message})
+}
+
+// writeRequestError responds to a request which couldn't be decoded or validated, the invalid fields are listed.
+func writeRequestError(w http.ResponseWriter, err error) {
+	body := errorBody{Status: http.StatusBadRequest, Message: err.Error()}
+	var verr *validationError
+	if errors.As(err, &verr) {
+		body.Fields = verr.Fields
+	}
+
+	writeErrorBody(w, body)
+}
+
+// writeErrorStatus responds with the status of the error, the invalid fields are listed for the validation errors.
+func writeErrorStatus(w http.ResponseWriter, err error) {
+	var verr *validationError
+	if errors.As(err, &verr) {
+		writeRequestError(w, err)
+		return
+	}
+
+	writeError(w, err.Error(), errorStatusCode(err))
+}
+
+func writeErrorBody(w http.ResponseWriter, body errorBody) {
+	w.Header().Del("Content-Disposition")
+	w.Header().Set("Content-Type", jsonContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(body.Status)
+	if err := json.NewEncoder(w).Encode(errorResponse{Error: body}); err != nil {
+		log.Error("failed to write error response: ", err.Error())
+	}
+}
+
+// NotFound responds to the requests of unknown paths.
+func (a *BannersApp) NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, fmt.Sprintf("path %s is not found", r.URL.Path), http.StatusNotFound)
+}
+
+// MethodNotAllowed responds to the requests of known paths with an unsupported method.
+func (a *BannersApp) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, fmt.Sprintf("method %s is not allowed for path %s", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+}
+
+// decodeRequest reads the JSON body into v and checks the fields of v by their validate tags.
+// Unknown fields and anything after the JSON value are rejected.
+func decodeRequest(r *http.Request, v interface{}) error {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		return fmt.Errorf("%w: %s", errInvalidBody, err.Error())
+	}
+	if _, err := d.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: body must hold a single JSON value", errInvalidBody)
+	}
+
+	return validate(v)
+}
+
+// validate checks the fields of the struct by their validate tags, the embedded structs are checked too.
+// The tag holds comma separated rules:
+//   - required: the field must be set to a non-zero value,
+//   - notempty: a set pointer must not point to a zero value, a pointer which isn't set is fine,
+//   - id: a number must not be negative, zero means the id isn't set, the numbers of a slice must be positive,
+//   - url: a set string must be an absolute http or https URL.
+//
+// Pointer fields are checked when they are set.
+func validate(v interface{}) error {
+	verr := &validationError{}
+	validateStruct(reflect.ValueOf(v), verr)
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+func validateStruct(v reflect.Value, verr *validationError) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			validateStruct(v.Field(i), verr)
+			continue
+		}
+		tag := f.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		validateField(jsonName(f), v.Field(i), strings.Split(tag, ","), verr)
+	}
+}
+
+func validateField(name string, v reflect.Value, rules []string, verr *validationError) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if hasRule(rules, "required") {
+				verr.add(name, "is required")
+			}
+			return
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		switch rule {
+		case "required":
+			if v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+				verr.add(name, "is required")
+				return
+			}
+		case "notempty":
+			if v.IsZero() {
+				verr.add(name, "must not be empty")
+				return
+			}
+		case "id":
+			if v.Kind() == reflect.Slice {
+				for i := 0; i < v.Len(); i++ {
+					if v.Index(i).Int() <= 0 {
+						verr.add(fmt.Sprintf("%s[%d]", name, i), "must be a positive id")
+					}
+				}
+			} else if v.Int() < 0 {
+				verr.add(name, "must be a positive id")
+			}
+		case "url":
+			if s := v.String(); s != "" && !validURL(s) {
+				verr.add(name, "must be an absolute http or https URL")
+			}
+		}
+	}
+}
+
+// nullTime is a time field of a request which tells an absent field from an explicit null.
+type nullTime struct {
+	// Set tells that the field is in the request, Time is nil if the field is null.
+	Set  bool
+	Time *time.Time
+}
+
+func (t *nullTime) UnmarshalJSON(b []byte) error {
+	t.Set = true
+	if string(b) == "null" {
+		t.Time = nil
+		return nil
+	}
+
+	return json.Unmarshal(b, &t.Time)
+}
+
+// update returns the time for the update of an item: nil leaves the time as it is,
+// the zero time removes it.
+func (t nullTime) update() *time.Time {
+	if !t.Set {
+		return nil
+	}
+	if t.Time == nil {
+		return &time.Time{}
+	}
+
+	return t.Time
+}
+
+func validURL(s string) bool {
+	u, err := url.ParseRequestURI(s)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func hasRule(rules []string, rule string) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+
+	return false
+}
+
+func jsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+
+	return f.Name
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type embeddedRequest struct {
+	SlotID int `json:"slot" validate:"required,id"`
+}
+
+type testRequest struct {
+	embeddedRequest
+	BannerIDs []int   `json:"banners" validate:"required,id"`
+	GroupIDs  []int   `json:"groups" validate:"id"`
+	URL       string  `json:"url" validate:"url"`
+	Name      *string `json:"name" validate:"required"`
+	Link      *string `json:"link" validate:"notempty,url"`
+	Untagged  int     `json:"untagged"`
+}
+
+func TestDecodeRequest(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		fields []fieldError
+		// invalid tells that the body can't be decoded at all
+		invalid bool
+	}{
+		{
+			name: "valid",
+			body: `{"slot": 1, "banners": [1, 2], "groups": [3], "url": "https://mybanner.com", "name": "n", "untagged": -1}`,
+		},
+		{
+			name: "missing required fields",
+			body: `{}`,
+			fields: []fieldError{
+				{Field: "slot", Message: "is required"},
+				{Field: "banners", Message: "is required"},
+				{Field: "name", Message: "is required"},
+			},
+		},
+		{
+			name: "empty required slice",
+			body: `{"slot": 1, "banners": [], "name": "n"}`,
+			fields: []fieldError{
+				{Field: "banners", Message: "is required"},
+			},
+		},
+		{
+			name: "empty required pointer",
+			body: `{"slot": 1, "banners": [1], "name": ""}`,
+			fields: []fieldError{
+				{Field: "name", Message: "is required"},
+			},
+		},
+		{
+			name: "negative id of embedded struct",
+			body: `{"slot": -1, "banners": [1], "name": "n"}`,
+			fields: []fieldError{
+				{Field: "slot", Message: "must be a positive id"},
+			},
+		},
+		{
+			name: "invalid ids of slices",
+			body: `{"slot": 1, "banners": [1, 0], "groups": [-2, 3], "name": "n"}`,
+			fields: []fieldError{
+				{Field: "banners[1]", Message: "must be a positive id"},
+				{Field: "groups[0]", Message: "must be a positive id"},
+			},
+		},
+		{
+			name: "relative url",
+			body: `{"slot": 1, "banners": [1], "name": "n", "url": "/banner"}`,
+			fields: []fieldError{
+				{Field: "url", Message: "must be an absolute http or https URL"},
+			},
+		},
+		{
+			name: "url of another scheme",
+			body: `{"slot": 1, "banners": [1], "name": "n", "url": "ftp://mybanner.com"}`,
+			fields: []fieldError{
+				{Field: "url", Message: "must be an absolute http or https URL"},
+			},
+		},
+		{
+			name: "invalid url of pointer",
+			body: `{"slot": 1, "banners": [1], "name": "n", "link": "mybanner.com"}`,
+			fields: []fieldError{
+				{Field: "link", Message: "must be an absolute http or https URL"},
+			},
+		},
+		{
+			name: "empty set pointer",
+			body: `{"slot": 1, "banners": [1], "name": "n", "link": ""}`,
+			fields: []fieldError{
+				{Field: "link", Message: "must not be empty"},
+			},
+		},
+		{
+			name:    "unknown field",
+			body:    `{"slot": 1, "banners": [1], "name": "n", "unknown": 1}`,
+			invalid: true,
+		},
+		{
+			name:    "trailing JSON",
+			body:    `{"slot": 1, "banners": [1], "name": "n"} {}`,
+			invalid: true,
+		},
+		{
+			name:    "trailing garbage",
+			body:    `{"slot": 1, "banners": [1], "name": "n"} x`,
+			invalid: true,
+		},
+		{
+			name:    "wrong type",
+			body:    `{"slot": "1", "banners": [1], "name": "n"}`,
+			invalid: true,
+		},
+		{
+			name:    "empty body",
+			body:    ``,
+			invalid: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+			err := decodeRequest(r, &testRequest{})
+
+			switch {
+			case c.invalid:
+				assert.True(t, errors.Is(err, errInvalidBody), err)
+			case c.fields == nil:
+				assert.NoError(t, err)
+			default:
+				var verr *validationError
+				assert.True(t, errors.As(err, &verr), err)
+				if verr != nil {
+					assert.Equal(t, c.fields, verr.Fields)
+				}
+			}
+		})
+	}
+}
+
+func TestNullTime(t *testing.T) {
+	req := struct {
+		Absent nullTime `json:"absent"`
+		Null   nullTime `json:"null"`
+		Value  nullTime `json:"value"`
+	}{}
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"null": null, "value": "2021-05-01T10:00:00Z"}`))
+	assert.NoError(t, decodeRequest(r, &req))
+
+	assert.Nil(t, req.Absent.update())
+	assert.Equal(t, &time.Time{}, req.Null.update())
+	assert.Equal(t, time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC), *req.Value.update())
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"value": "tomorrow"}`))
+	assert.True(t, errors.Is(decodeRequest(r, &req), errInvalidBody))
+}
+
+func TestValidateNilPointer(t *testing.T) {
+	assert.NoError(t, validate((*testRequest)(nil)))
+	assert.NoError(t, validate(struct{ Value int }{Value: -1}))
+}
+
+func TestWriteRequestError(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeRequestError(w, invalidField("group", "or attributes is required"))
+
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, jsonContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error": {"status": 400, "message": "invalid request: group or attributes is required",
+		"fields": [{"field": "group", "message": "or attributes is required"}]}}`, w.Body.String())
+}
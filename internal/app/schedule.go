@@ -0,0 +1,109 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/schedule"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// previewBanner is a banner of the slot with its eligibility at the preview time.
+type previewBanner struct {
+	repository.SlotBanner
+	Eligible bool `json:"eligible"`
+}
+
+type relationSchedule struct {
+	SlotID   int                `json:"slot" validate:"required,id"`
+	BannerID int                `json:"banner" validate:"required,id"`
+	Schedule *schedule.Schedule `json:"schedule,omitempty"`
+}
+
+func (a *BannersApp) SetRelationSchedule(w http.ResponseWriter, r *http.Request) {
+	reqData := relationSchedule{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+	if reqData.Schedule == nil {
+		writeRequestError(w, invalidField("schedule", "is required"))
+		return
+	}
+
+	if err := a.repo.SetRelationSchedule(r.Context(), reqData.SlotID, reqData.BannerID, reqData.Schedule); err != nil {
+		log.WithFields(log.Fields{
+			"slot id":   reqData.SlotID,
+			"banner id": reqData.BannerID,
+		}).Error("failed to set relation schedule: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditSetSchedule, nil, reqData)
+}
+
+func (a *BannersApp) RemoveRelationSchedule(w http.ResponseWriter, r *http.Request) {
+	reqData := relationSchedule{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+	reqData.Schedule = nil
+
+	if err := a.repo.SetRelationSchedule(r.Context(), reqData.SlotID, reqData.BannerID, nil); err != nil {
+		log.WithFields(log.Fields{
+			"slot id":   reqData.SlotID,
+			"banner id": reqData.BannerID,
+		}).Error("failed to remove relation schedule: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditRemoveSchedule, reqData, nil)
+}
+
+// PreviewSlot lists the banners of the slot and tells which of them could be shown at the given time.
+func (a *BannersApp) PreviewSlot(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	slotID, err := strconv.Atoi(query.Get("slot"))
+	if err != nil {
+		writeError(w, fmt.Sprintf("invalid slot id %q", query.Get("slot")), http.StatusBadRequest)
+		return
+	}
+	at, err := parseTimeParam(query.Get("at"))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if at == nil {
+		now := time.Now()
+		at = &now
+	}
+
+	banners, err := a.repo.GetSlotBanners(r.Context(), slotID)
+	if err != nil {
+		log.WithField("slot id", slotID).Error("failed to get slot banners: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	preview := make([]previewBanner, 0, len(banners))
+	for _, b := range banners {
+		preview = append(preview, previewBanner{SlotBanner: b, Eligible: b.Eligible(*at)})
+	}
+
+	if err := json.NewEncoder(w).Encode(&preview); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/segment"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errNoGroup = fmt.Errorf("no group matches the user attributes: %w", repository.ErrNotFound)
+
+// resolvedGroup is the group which user attributes are mapped to.
+type resolvedGroup struct {
+	Group repository.Group `json:"group"`
+	// Fallback tells that no segment matches the attributes.
+	Fallback bool `json:"fallback"`
+}
+
+// resolveGroup maps the user attributes to the first group whose segment matches them or,
+// if there is no such group, to the fallback group.
+func (a *BannersApp) resolveGroup(ctx context.Context, attrs segment.Attributes) (resolvedGroup, error) {
+	groups, err := a.repo.GetAllGroups(ctx)
+	if err != nil {
+		return resolvedGroup{}, err
+	}
+
+	candidates := make([]segment.Candidate, 0, len(groups))
+	byID := make(map[int]repository.Group, len(groups))
+	var fallback *repository.Group
+	for i, g := range groups {
+		byID[g.ID] = g
+		if g.Fallback {
+			fallback = &groups[i]
+		}
+		if g.Segment != nil {
+			candidates = append(candidates, segment.Candidate{GroupID: g.ID, Segment: *g.Segment})
+		}
+	}
+
+	if id, ok := segment.Resolve(candidates, attrs); ok {
+		return resolvedGroup{Group: byID[id]}, nil
+	}
+	if fallback != nil {
+		return resolvedGroup{Group: *fallback, Fallback: true}, nil
+	}
+
+	return resolvedGroup{}, errNoGroup
+}
+
+// requestGroup returns the group of the request, the group id given explicitly
+// takes precedence over the user attributes, one of them is required.
+func (a *BannersApp) requestGroup(ctx context.Context, groupID int, attrs segment.Attributes) (int, error) {
+	if groupID != 0 {
+		return groupID, nil
+	}
+	if attrs == nil {
+		return 0, invalidField("group", "or attributes is required")
+	}
+
+	resolved, err := a.resolveGroup(ctx, attrs)
+	if err != nil {
+		log.WithField("attributes", attrs).Warning("failed to resolve group: ", err.Error())
+		return 0, err
+	}
+
+	return resolved.Group.ID, nil
+}
+
+// ResolveGroup shows which group the user attributes are mapped to, it lets segments be tested.
+func (a *BannersApp) ResolveGroup(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		Attributes segment.Attributes `json:"attributes"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	resolved, err := a.resolveGroup(r.Context(), reqData.Attributes)
+	if err != nil {
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&resolved); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
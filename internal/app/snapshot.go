@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type snapshotFunc = func(ctx context.Context, name, comment string, scope repository.StatisticScope) (repository.Snapshot, error)
+
+type snapshotRequest struct {
+	Name    string `json:"name" validate:"required"`
+	Comment string `json:"comment"`
+	SlotID  int    `json:"slot" validate:"id"`
+	GroupID int    `json:"group" validate:"id"`
+}
+
+func (a *BannersApp) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	a.snapshot(w, r, a.repo.CreateSnapshot, repository.AuditAddSnapshot)
+}
+
+func (a *BannersApp) ResetStatistic(w http.ResponseWriter, r *http.Request) {
+	a.snapshot(w, r, a.repo.ResetStatistic, repository.AuditResetStatistic)
+}
+
+func (a *BannersApp) snapshot(w http.ResponseWriter, r *http.Request, fn snapshotFunc, action string) {
+	reqData := snapshotRequest{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	scope := repository.StatisticScope{
+		SlotID:  reqData.SlotID,
+		GroupID: reqData.GroupID,
+	}
+	if scope.Empty() {
+		writeRequestError(w, invalidField("slot", "or group is required"))
+		return
+	}
+
+	snapshot, err := fn(r.Context(), reqData.Name, reqData.Comment, scope)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"name":     reqData.Name,
+			"slot id":  reqData.SlotID,
+			"group id": reqData.GroupID,
+		}).Error("failed to snapshot statistic: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, action, nil, snapshot)
+
+	if err := json.NewEncoder(w).Encode(&snapshot); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *BannersApp) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	reqData := struct {
+		SnapshotID int `json:"snapshot" validate:"required,id"`
+	}{}
+	if err := decodeRequest(r, &reqData); err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeRequestError(w, err)
+		return
+	}
+
+	snapshot, err := a.repo.RestoreSnapshot(r.Context(), reqData.SnapshotID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"snapshot id": reqData.SnapshotID,
+		}).Error("failed to restore statistic snapshot: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+	a.audit(r, repository.AuditRestoreStatistic, nil, snapshot)
+
+	if err := json.NewEncoder(w).Encode(&snapshot); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *BannersApp) GetSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := a.repo.GetSnapshots(r.Context())
+	if err != nil {
+		log.Error("failed to get statistic snapshots: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&snapshots); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
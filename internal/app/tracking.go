@@ -0,0 +1,89 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/token"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// transparentPixel is a 1x1 transparent GIF image.
+var transparentPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+func clickURL(t string) string {
+	return "/c/" + t
+}
+
+func pixelURL(t string) string {
+	return "/i/" + t + ".gif"
+}
+
+// ClickRedirect counts the click of the token impression and redirects to the banner.
+// A user is redirected even when the click isn't counted, e.g. it is repeated or the token
+// has expired, only forged tokens are rejected.
+func (a *BannersApp) ClickRedirect(w http.ResponseWriter, r *http.Request) {
+	if a.tokens == nil {
+		writeError(w, "impression tokens are disabled", http.StatusNotFound)
+		return
+	}
+
+	imp, err := a.tokens.Parse(mux.Vars(r)["token"])
+	switch {
+	case errors.Is(err, token.ErrExpiredToken):
+		impressionLogEntry(imp).Warning("click of expired impression isn't counted")
+	case err != nil:
+		log.Warning("click was rejected: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	default:
+		if err := a.click(r.Context(), imp, a.clientIP(r)); err != nil {
+			impressionLogEntry(imp).Warning(err.Error())
+		}
+	}
+
+	banner, err := a.repo.GetBannerByID(r.Context(), imp.BannerID)
+	if err != nil {
+		impressionLogEntry(imp).Error("failed to get banner to redirect: ", err.Error())
+
+		writeError(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	w.Header().Del("Content-Type")
+	http.Redirect(w, r, banner.URL, http.StatusFound)
+}
+
+// TrackingPixel counts the show of the token impression and responds with a transparent
+// GIF image whatever the outcome, so that a page never shows a broken image.
+func (a *BannersApp) TrackingPixel(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	if a.tokens == nil {
+		status = http.StatusNotFound
+	} else if imp, err := a.tokens.Parse(mux.Vars(r)["token"]); err != nil {
+		log.Warning("show was rejected: ", err.Error())
+		status = errorStatusCode(err)
+	} else if err := a.show(r.Context(), imp); err != nil {
+		impressionLogEntry(imp).Warning(err.Error())
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			status = http.StatusOK
+		} else {
+			status = errorStatusCode(err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if _, err := w.Write(transparentPixel); err != nil {
+		log.Error("failed to write tracking pixel: ", err.Error())
+	}
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/transfer"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const csvContentType = "text/csv"
+
+func (a *BannersApp) Import(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			log.Error(parseRequestParamsErr(err))
+
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := transfer.FormatJSON
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == csvContentType {
+		format = transfer.FormatCSV
+	}
+
+	config, err := transfer.Decode(r.Body, format)
+	if err != nil {
+		log.Error(parseRequestParamsErr(err))
+
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := a.repo.Import(r.Context(), config, dryRun)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"format":  format,
+			"dry run": dryRun,
+		}).Error("failed to import configuration: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if !dryRun {
+		a.audit(r, repository.AuditImport, nil, report)
+	}
+
+	if err := json.NewEncoder(w).Encode(&report); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *BannersApp) Export(w http.ResponseWriter, r *http.Request) {
+	format := transfer.FormatJSON
+	if value := r.URL.Query().Get("format"); value != "" {
+		var err error
+		if format, err = transfer.ParseFormat(value); err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if strings.Contains(r.Header.Get("Accept"), csvContentType) {
+		format = transfer.FormatCSV
+	}
+
+	config, err := a.repo.Export(r.Context())
+	if err != nil {
+		log.Error("failed to export configuration: ", err.Error())
+
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == transfer.FormatCSV {
+		w.Header().Set("Content-Type", csvContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="banners.csv"`)
+	}
+	if err := transfer.Encode(w, config, format); err != nil {
+		log.Error("failed to encode configuration: ", err.Error())
+	}
+}
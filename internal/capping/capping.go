@@ -0,0 +1,59 @@
+// Package capping counts the shows of banners to users, so that a banner with
+// a frequency cap isn't shown to the same user more often than the cap allows.
+package capping
+
+import (
+	"context"
+	"time"
+)
+
+type Period string
+
+const (
+	PeriodHour Period = "hour"
+	PeriodDay  Period = "day"
+)
+
+// Periods lists all the periods, the shows are counted for each of them.
+var Periods = []Period{PeriodHour, PeriodDay}
+
+func (p Period) Valid() bool {
+	return p == PeriodHour || p == PeriodDay
+}
+
+func (p Period) Duration() time.Duration {
+	if p == PeriodHour {
+		return time.Hour
+	}
+
+	return 24 * time.Hour
+}
+
+// Start returns the beginning of the period which the time falls in, days start at midnight UTC.
+func (p Period) Start(at time.Time) time.Time {
+	return at.UTC().Truncate(p.Duration())
+}
+
+// UserShows holds the shows of the banners to a user by the banner id and the period.
+type UserShows map[int]map[Period]int
+
+// Get returns how many times the banner was shown in the period.
+func (s UserShows) Get(bannerID int, period Period) int {
+	return s[bannerID][period]
+}
+
+// Add adds the shows of the banner in the period.
+func (s UserShows) Add(bannerID int, period Period, shows int) {
+	if s[bannerID] == nil {
+		s[bannerID] = make(map[Period]int, len(Periods))
+	}
+	s[bannerID][period] += shows
+}
+
+type Store interface {
+	// AddShow counts the show of the banner to the user in the current periods.
+	AddShow(ctx context.Context, userID string, bannerID int, at time.Time) error
+	// Shows returns how many times each banner was shown to the user in the periods which the time falls in,
+	// so that the caps of all the candidate banners are checked with a single lookup.
+	Shows(ctx context.Context, userID string, at time.Time) (UserShows, error)
+}
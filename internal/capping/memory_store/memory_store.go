@@ -0,0 +1,80 @@
+package memorystore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+)
+
+// sweepInterval is how often the counters of the past periods are removed.
+const sweepInterval = time.Minute
+
+type key struct {
+	bannerID int
+	period   capping.Period
+	start    time.Time
+}
+
+// store keeps the counters of every user in memory until their periods are over.
+type store struct {
+	mu        sync.Mutex
+	shows     map[string]map[key]int
+	lastSweep time.Time
+}
+
+func NewStore() capping.Store {
+	return &store{
+		shows: make(map[string]map[key]int),
+	}
+}
+
+func (s *store) AddShow(_ context.Context, userID string, bannerID int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(at)
+	userShows, ok := s.shows[userID]
+	if !ok {
+		userShows = make(map[key]int)
+		s.shows[userID] = userShows
+	}
+	for _, p := range capping.Periods {
+		userShows[key{bannerID: bannerID, period: p, start: p.Start(at)}]++
+	}
+
+	return nil
+}
+
+func (s *store) Shows(_ context.Context, userID string, at time.Time) (capping.UserShows, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shows := make(capping.UserShows)
+	for k, n := range s.shows[userID] {
+		if k.start.Equal(k.period.Start(at)) {
+			shows.Add(k.bannerID, k.period, n)
+		}
+	}
+
+	return shows, nil
+}
+
+func (s *store) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for userID, userShows := range s.shows {
+		for k := range userShows {
+			if !now.Before(k.start.Add(k.period.Duration())) {
+				delete(userShows, k)
+			}
+		}
+		if len(userShows) == 0 {
+			delete(s.shows, userID)
+		}
+	}
+}
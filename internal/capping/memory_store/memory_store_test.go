@@ -0,0 +1,67 @@
+package memorystore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShows(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	at := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	assert.NoError(t, s.AddShow(ctx, "user", 1, at))
+	assert.NoError(t, s.AddShow(ctx, "user", 1, at.Add(10*time.Minute)))
+	assert.NoError(t, s.AddShow(ctx, "other", 1, at))
+	assert.NoError(t, s.AddShow(ctx, "user", 2, at))
+
+	shows, err := s.Shows(ctx, "user", at)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, shows.Get(1, capping.PeriodHour))
+	assert.Equal(t, 1, shows.Get(2, capping.PeriodHour))
+	assert.Equal(t, 0, shows.Get(3, capping.PeriodHour))
+
+	assert.NoError(t, s.AddShow(ctx, "user", 1, at.Add(time.Hour)))
+	shows, err = s.Shows(ctx, "user", at.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, shows.Get(1, capping.PeriodHour))
+	assert.Equal(t, 3, shows.Get(1, capping.PeriodDay))
+
+	shows, err = s.Shows(ctx, "user", at.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, shows.Get(1, capping.PeriodDay))
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	at := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	assert.NoError(t, s.AddShow(ctx, "user", 1, at))
+	assert.NoError(t, s.AddShow(ctx, "other", 1, at))
+	assert.Len(t, s.(*store).shows["user"], 2)
+
+	// the hour is over, only the day counter is left
+	assert.NoError(t, s.AddShow(ctx, "user", 2, at.Add(time.Hour)))
+	assert.Len(t, s.(*store).shows["user"], 3)
+	assert.Len(t, s.(*store).shows["other"], 1)
+
+	// the users without counters are removed too
+	assert.NoError(t, s.AddShow(ctx, "user", 2, at.Add(48*time.Hour)))
+	assert.Len(t, s.(*store).shows["user"], 2)
+	assert.NotContains(t, s.(*store).shows, "other")
+}
+
+func TestPeriodStart(t *testing.T) {
+	at := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC), capping.PeriodHour.Start(at))
+	assert.Equal(t, time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC), capping.PeriodDay.Start(at))
+	assert.True(t, capping.PeriodDay.Valid())
+	assert.False(t, capping.Period("week").Valid())
+}
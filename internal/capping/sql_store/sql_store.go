@@ -0,0 +1,76 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+)
+
+// store keeps the counters in the user_shows table, so that they are
+// shared by all the instances of the service.
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) capping.Store {
+	return &store{
+		db: db,
+	}
+}
+
+func (s *store) AddShow(ctx context.Context, userID string, bannerID int, at time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, p := range capping.Periods {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO user_shows (user_id, banner_id, period, period_start, shows) VALUES ($1, $2, $3, $4, 1)
+ON CONFLICT (user_id, banner_id, period, period_start) DO UPDATE SET shows = user_shows.shows + 1;`,
+			userID, bannerID, p, p.Start(at)); err != nil {
+			return err
+		}
+	}
+
+	// the longest period which has already started is kept, everything before it is over
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_shows WHERE user_id = $1 AND period_start < $2;",
+		userID, capping.PeriodDay.Start(at)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *store) Shows(ctx context.Context, userID string, at time.Time) (capping.UserShows, error) {
+	conditions := make([]string, 0, len(capping.Periods))
+	args := []interface{}{userID}
+	for _, p := range capping.Periods {
+		conditions = append(conditions, fmt.Sprintf("(period = $%d AND period_start = $%d)", len(args)+1, len(args)+2))
+		args = append(args, p, p.Start(at))
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT banner_id, period, shows FROM user_shows WHERE user_id = $1 AND ("+ //nolint:gosec
+		strings.Join(conditions, " OR ")+");", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shows := make(capping.UserShows)
+	for rows.Next() {
+		bannerID, period, n := 0, capping.Period(""), 0
+		if err := rows.Scan(&bannerID, &period, &n); err != nil {
+			return nil, err
+		}
+		shows.Add(bannerID, period, n)
+	}
+
+	return shows, rows.Err()
+}
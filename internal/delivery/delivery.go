@@ -0,0 +1,119 @@
+// Package delivery splits the traffic of a slot between the delivery tiers:
+// guaranteed banners get their contracted share of the slot impressions,
+// the rest goes to the priority banners and then to the standard ones,
+// which the bandit chooses from.
+package delivery
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrInvalidDelivery = errors.New("delivery must have a known tier, guaranteed banners need a share " +
+	"between 0 and 1 and the shares of a slot can't exceed 1 in total")
+
+type Tier string
+
+const (
+	TierGuaranteed Tier = "guaranteed"
+	TierPriority   Tier = "priority"
+	TierStandard   Tier = "standard"
+)
+
+// Tolerance is the part of the expected impressions which a guarantee may lag behind and still be on track.
+const Tolerance = 0.1
+
+// Settings describe how a banner is delivered in a slot, banners without settings are standard.
+type Settings struct {
+	Tier Tier `json:"tier"`
+	// Share is the part of the slot impressions guaranteed to the banner.
+	Share float64 `json:"share,omitempty"`
+}
+
+func (s Settings) Validate() error {
+	switch s.Tier {
+	case TierGuaranteed:
+		if s.Share <= 0 || s.Share > 1 {
+			return ErrInvalidDelivery
+		}
+	case TierPriority, TierStandard:
+		if s.Share != 0 {
+			return ErrInvalidDelivery
+		}
+	default:
+		return ErrInvalidDelivery
+	}
+
+	return nil
+}
+
+// Candidate is an eligible banner of the slot with the impressions it has got in the slot.
+type Candidate struct {
+	BannerID    int
+	Settings    Settings
+	Impressions int64
+}
+
+// Select returns the guaranteed banner which is the furthest behind its share, if there is one,
+// or the banners of the highest tier left to the bandit. Guaranteed banners are left to the bandit
+// only when there are no other banners. Total is the number of impressions of the slot.
+func Select(candidates []Candidate, total int64) (int, []int) {
+	guaranteed := 0
+	maxDeficit := 0.0
+	tiers := make(map[Tier][]int)
+	for _, c := range candidates {
+		tier := c.Settings.Tier
+		if tier == "" {
+			tier = TierStandard
+		}
+		tiers[tier] = append(tiers[tier], c.BannerID)
+
+		if tier != TierGuaranteed {
+			continue
+		}
+		deficit := c.Settings.Share*float64(total) - float64(c.Impressions)
+		if deficit > maxDeficit || (deficit == maxDeficit && deficit > 0 && c.BannerID < guaranteed) {
+			guaranteed = c.BannerID
+			maxDeficit = deficit
+		}
+	}
+	if guaranteed != 0 {
+		return guaranteed, nil
+	}
+
+	for _, tier := range []Tier{TierPriority, TierStandard, TierGuaranteed} {
+		if len(tiers[tier]) > 0 {
+			pool := tiers[tier]
+			sort.Ints(pool)
+			return 0, pool
+		}
+	}
+
+	return 0, nil
+}
+
+// Progress tells how a guarantee is delivered.
+type Progress struct {
+	Share           float64 `json:"share"`
+	Impressions     int64   `json:"impressions"`
+	SlotImpressions int64   `json:"slot_impressions"`
+	Expected        float64 `json:"expected"`
+	DeliveredShare  float64 `json:"delivered_share"`
+	OnTrack         bool    `json:"on_track"`
+}
+
+// Track compares the impressions of a guaranteed banner with its share of the slot impressions.
+func Track(share float64, impressions, total int64) Progress {
+	p := Progress{
+		Share:           share,
+		Impressions:     impressions,
+		SlotImpressions: total,
+		Expected:        share * float64(total),
+	}
+	if total > 0 {
+		p.DeliveredShare = float64(impressions) / float64(total)
+	}
+	p.OnTrack = float64(impressions) >= p.Expected*(1-Tolerance)
+
+	return p
+}
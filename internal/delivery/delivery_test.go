@@ -0,0 +1,63 @@
+package delivery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Settings{Tier: TierGuaranteed, Share: 0.3}.Validate())
+	assert.NoError(t, Settings{Tier: TierPriority}.Validate())
+	assert.NoError(t, Settings{Tier: TierStandard}.Validate())
+
+	for _, s := range []Settings{
+		{},
+		{Tier: "gold"},
+		{Tier: TierGuaranteed},
+		{Tier: TierGuaranteed, Share: 1.5},
+		{Tier: TierPriority, Share: 0.2},
+	} {
+		assert.Equal(t, ErrInvalidDelivery, s.Validate(), s)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	candidates := []Candidate{
+		{BannerID: 1, Settings: Settings{Tier: TierGuaranteed, Share: 0.3}, Impressions: 30},
+		{BannerID: 2, Settings: Settings{Tier: TierGuaranteed, Share: 0.2}, Impressions: 15},
+		{BannerID: 3, Impressions: 30},
+		{BannerID: 4, Settings: Settings{Tier: TierPriority}, Impressions: 25},
+	}
+
+	// banner 2 is 5 impressions behind its share of 100
+	guaranteed, pool := Select(candidates, 100)
+	assert.Equal(t, 2, guaranteed)
+	assert.Nil(t, pool)
+
+	candidates[1].Impressions = 25
+	guaranteed, pool = Select(candidates, 100)
+	assert.Equal(t, 0, guaranteed)
+	assert.Equal(t, []int{4}, pool)
+
+	guaranteed, pool = Select(candidates[:3], 100)
+	assert.Equal(t, 0, guaranteed)
+	assert.Equal(t, []int{3}, pool)
+
+	guaranteed, pool = Select(candidates[:2], 100)
+	assert.Equal(t, 0, guaranteed)
+	assert.Equal(t, []int{1, 2}, pool)
+
+	guaranteed, _ = Select(candidates[:2], 101)
+	assert.Equal(t, 1, guaranteed)
+}
+
+func TestTrack(t *testing.T) {
+	p := Track(0.25, 20, 100)
+	assert.Equal(t, 25.0, p.Expected)
+	assert.Equal(t, 0.2, p.DeliveredShare)
+	assert.False(t, p.OnTrack)
+
+	assert.True(t, Track(0.25, 23, 100).OnTrack)
+	assert.True(t, Track(0.25, 0, 0).OnTrack)
+}
@@ -0,0 +1,173 @@
+// Package experiment splits the traffic of a slot between bandit configurations
+// and compares how well they do.
+package experiment
+
+import (
+	"errors"
+	"hash/fnv"
+	"strconv"
+
+	bandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
+)
+
+var ErrInvalidExperiment = errors.New("experiment must have at least two arms with unique names, " +
+	"positive weights and valid bandits")
+
+// Arm is a part of the experiment traffic served by its own bandit.
+type Arm struct {
+	Name string `json:"name"`
+	// Weight is the relative size of the arm traffic.
+	Weight int           `json:"weight"`
+	Bandit bandit.Config `json:"bandit"`
+}
+
+// Validate checks the arms of an experiment.
+func Validate(arms []Arm) error {
+	if len(arms) < 2 {
+		return ErrInvalidExperiment
+	}
+
+	names := make(map[string]bool, len(arms))
+	for _, a := range arms {
+		if a.Name == "" || names[a.Name] || a.Weight <= 0 || a.Bandit.Validate() != nil {
+			return ErrInvalidExperiment
+		}
+		names[a.Name] = true
+	}
+
+	return nil
+}
+
+// Assign maps the unit, which is a user or a request id, to an arm of the experiment.
+// A unit always gets the same arm of the same experiment while the arms are different
+// for different experiments.
+func Assign(experimentID int, unit string, arms []Arm) Arm {
+	total := 0
+	for _, a := range arms {
+		total += a.Weight
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.Itoa(experimentID) + ":" + unit))
+	point := int(h.Sum64() % uint64(total))
+	for _, a := range arms {
+		if point < a.Weight {
+			return a
+		}
+		point -= a.Weight
+	}
+
+	return arms[len(arms)-1]
+}
+
+// InHoldout tells whether the unit falls into the holdout, which takes the percent of all
+// the units. A unit stays in or out of the holdout independently of the experiments.
+func InHoldout(unit string, percent float64) bool {
+	if percent <= 0 {
+		return false
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("holdout:" + unit))
+
+	return float64(h.Sum64()%10000) < percent*100
+}
+
+// Performance is the click rate of the traffic served in some way.
+type Performance struct {
+	Impressions int64   `json:"impressions"`
+	Clicks      int64   `json:"clicks"`
+	CTR         float64 `json:"ctr"`
+}
+
+func NewPerformance(impressions, clicks int64) Performance {
+	p := Performance{Impressions: impressions, Clicks: clicks}
+	if impressions > 0 {
+		p.CTR = float64(clicks) / float64(impressions)
+	}
+
+	return p
+}
+
+// Lift is the relative gain of the click rate over the control one,
+// it is zero while the control has no clicks.
+func Lift(p, control Performance) float64 {
+	if control.CTR == 0 {
+		return 0
+	}
+
+	return p.CTR/control.CTR - 1
+}
+
+// Counter holds the events of a banner served by an arm.
+type Counter struct {
+	Arm         string
+	BannerID    int
+	Impressions int64
+	Clicks      int64
+}
+
+// ArmReport sums up the results of an arm. Regret is the number of clicks lost against
+// always showing the best banner, the click rates of the banners are estimated on
+// the impressions of all arms.
+type ArmReport struct {
+	Arm                 string  `json:"arm"`
+	Impressions         int64   `json:"impressions"`
+	Clicks              int64   `json:"clicks"`
+	CTR                 float64 `json:"ctr"`
+	Regret              float64 `json:"regret"`
+	RegretPerImpression float64 `json:"regret_per_impression"`
+}
+
+// Compare makes the reports of the arms in their order.
+func Compare(arms []Arm, counters []Counter) []ArmReport {
+	type pooled struct {
+		impressions int64
+		clicks      int64
+	}
+	banners := make(map[int]*pooled)
+	for _, c := range counters {
+		p, ok := banners[c.BannerID]
+		if !ok {
+			p = &pooled{}
+			banners[c.BannerID] = p
+		}
+		p.impressions += c.Impressions
+		p.clicks += c.Clicks
+	}
+
+	ctr := make(map[int]float64, len(banners))
+	best := 0.0
+	for id, p := range banners {
+		if p.impressions > 0 {
+			ctr[id] = float64(p.clicks) / float64(p.impressions)
+		}
+		if ctr[id] > best {
+			best = ctr[id]
+		}
+	}
+
+	reports := make([]ArmReport, len(arms))
+	index := make(map[string]int, len(arms))
+	for i, a := range arms {
+		reports[i].Arm = a.Name
+		index[a.Name] = i
+	}
+	for _, c := range counters {
+		i, ok := index[c.Arm]
+		if !ok {
+			continue
+		}
+		reports[i].Impressions += c.Impressions
+		reports[i].Clicks += c.Clicks
+		reports[i].Regret += float64(c.Impressions) * (best - ctr[c.BannerID])
+	}
+	for i := range reports {
+		if reports[i].Impressions > 0 {
+			reports[i].CTR = float64(reports[i].Clicks) / float64(reports[i].Impressions)
+			reports[i].RegretPerImpression = reports[i].Regret / float64(reports[i].Impressions)
+		}
+	}
+
+	return reports
+}
@@ -0,0 +1,89 @@
+package experiment
+
+import (
+	"fmt"
+	"testing"
+
+	bandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var arms = []Arm{
+	{Name: "thompson", Weight: 1, Bandit: bandit.Config{Algorithm: bandit.AlgorithmThompson, MinEvents: 50}},
+	{Name: "ucb", Weight: 3, Bandit: bandit.Config{Algorithm: bandit.AlgorithmUCB}},
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(arms))
+
+	for _, invalid := range [][]Arm{
+		nil,
+		arms[:1],
+		{arms[0], arms[0]},
+		{arms[0], {Name: "ucb", Bandit: arms[1].Bandit}},
+		{arms[0], {Name: "greedy", Weight: 1, Bandit: bandit.Config{Algorithm: "greedy"}}},
+	} {
+		assert.Equal(t, ErrInvalidExperiment, Validate(invalid))
+	}
+}
+
+func TestAssign(t *testing.T) {
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		unit := fmt.Sprintf("user-%d", i)
+		arm := Assign(1, unit, arms)
+		assert.Equal(t, arm, Assign(1, unit, arms))
+		counts[arm.Name]++
+	}
+
+	// the weights split the traffic as 1 to 3
+	assert.InDelta(t, 1000, counts["thompson"], 150)
+	assert.InDelta(t, 3000, counts["ucb"], 150)
+}
+
+func TestCompare(t *testing.T) {
+	reports := Compare(arms, []Counter{
+		{Arm: "thompson", BannerID: 1, Impressions: 100, Clicks: 10},
+		{Arm: "thompson", BannerID: 2, Impressions: 100, Clicks: 20},
+		{Arm: "ucb", BannerID: 2, Impressions: 200, Clicks: 40},
+		{Arm: "stopped", BannerID: 2, Impressions: 10, Clicks: 1},
+	})
+
+	assert.Len(t, reports, 2)
+	assert.Equal(t, "thompson", reports[0].Arm)
+	assert.Equal(t, int64(200), reports[0].Impressions)
+	assert.InDelta(t, 0.15, reports[0].CTR, 1e-9)
+	// banner 2 is the best one with the pooled rate of 61/310, banner 1 loses it on every impression
+	assert.InDelta(t, 100*(61.0/310-0.1), reports[0].Regret, 1e-9)
+
+	assert.Equal(t, "ucb", reports[1].Arm)
+	assert.InDelta(t, 0.2, reports[1].CTR, 1e-9)
+	assert.InDelta(t, 0, reports[1].Regret, 1e-9)
+}
+
+func TestInHoldout(t *testing.T) {
+	held := 0
+	for i := 0; i < 10000; i++ {
+		unit := fmt.Sprintf("user-%d", i)
+		in := InHoldout(unit, 5)
+		assert.Equal(t, in, InHoldout(unit, 5))
+		if in {
+			held++
+			assert.True(t, InHoldout(unit, 10))
+		}
+		assert.False(t, InHoldout(unit, 0))
+		assert.True(t, InHoldout(unit, 100))
+	}
+	assert.InDelta(t, 500, held, 100)
+}
+
+func TestLift(t *testing.T) {
+	bandit := NewPerformance(1000, 30)
+	holdout := NewPerformance(100, 2)
+	assert.InDelta(t, 0.03, bandit.CTR, 1e-9)
+	assert.InDelta(t, 0.5, Lift(bandit, holdout), 1e-9)
+
+	assert.Equal(t, Performance{}, NewPerformance(0, 0))
+	assert.Zero(t, Lift(bandit, NewPerformance(100, 0)))
+}
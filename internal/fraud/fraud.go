@@ -0,0 +1,202 @@
+// Package fraud screens the clicks for the signs of fraud: clicks without a show,
+// too many clicks from one source, clicks faster than a human and click rate spikes.
+package fraud
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrInvalidConfig = errors.New("fraud detection window must be positive and the thresholds must not be negative")
+
+// Reason tells why a click looks fraudulent.
+type Reason string
+
+const (
+	// ReasonNoShow is a click of a banner which hasn't been shown.
+	ReasonNoShow Reason = "no_show"
+	// ReasonClickRate is one of too many clicks from the same address or user.
+	ReasonClickRate Reason = "click_rate"
+	// ReasonTooFast is a click which follows its show faster than a human could manage.
+	ReasonTooFast Reason = "too_fast"
+	// ReasonCTRSpike is a click of a banner with an abnormal click rate.
+	ReasonCTRSpike Reason = "ctr_spike"
+)
+
+// Config sets the thresholds of the detector, a zero threshold turns its rule off.
+type Config struct {
+	// Window is how long the shows are remembered and the clicks are counted.
+	Window time.Duration
+	// MaxClicks is the number of clicks from one address or user allowed in the window.
+	MaxClicks int
+	// MinClickDelay is the shortest time between a show and its click.
+	MinClickDelay time.Duration
+	// SpikeMinClicks is the number of banner clicks in the window after which the click rate is checked.
+	SpikeMinClicks int
+	// SpikeCTR is the highest plausible click rate of a banner in the window.
+	SpikeCTR float64
+}
+
+func (c Config) Validate() error {
+	if c.Window <= 0 || c.MaxClicks < 0 || c.MinClickDelay < 0 || c.SpikeMinClicks < 0 || c.SpikeCTR < 0 {
+		return ErrInvalidConfig
+	}
+
+	return nil
+}
+
+// Event is a show or a click as the detector sees it. An event with an impression id refers
+// to a served impression, whose show is checked by the repository, the other events refer
+// to the banner shown to the user in the slot.
+type Event struct {
+	ImpressionID string
+	SlotID       int
+	BannerID     int
+	GroupID      int
+	UserID       string
+	IP           string
+	// Delayed tells that the event is reported some time after it happened, so its timing isn't checked.
+	Delayed bool
+}
+
+func (e Event) showKey() string {
+	if e.ImpressionID != "" {
+		return "impression:" + e.ImpressionID
+	}
+
+	return fmt.Sprintf("relation:%d:%d:%d:%s", e.SlotID, e.BannerID, e.GroupID, e.UserID)
+}
+
+// bannerWindow counts the events of a banner in the current window.
+type bannerWindow struct {
+	start  time.Time
+	shows  int
+	clicks int
+}
+
+// Detector keeps the recent events in memory, so the rules only see the events of its own instance.
+type Detector struct {
+	config Config
+
+	mu        sync.Mutex
+	shows     map[string]time.Time
+	clicks    map[string][]time.Time
+	banners   map[int]*bannerWindow
+	lastSweep time.Time
+}
+
+func NewDetector(c Config) (*Detector, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Detector{
+		config:  c,
+		shows:   make(map[string]time.Time),
+		clicks:  make(map[string][]time.Time),
+		banners: make(map[int]*bannerWindow),
+	}, nil
+}
+
+// Show remembers the show, so that its clicks can be checked.
+func (d *Detector) Show(e Event, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sweep(at)
+	d.shows[e.showKey()] = at
+	d.banner(e.BannerID, at).shows++
+}
+
+// Click counts the click and returns the reasons why it looks fraudulent, none for a clean click.
+func (d *Detector) Click(e Event, at time.Time) []Reason {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sweep(at)
+	reasons := make([]Reason, 0)
+
+	shownAt, shown := d.shows[e.showKey()]
+	shown = shown && at.Sub(shownAt) < d.config.Window
+	if !shown && e.ImpressionID == "" {
+		reasons = append(reasons, ReasonNoShow)
+	}
+	if shown && !e.Delayed && at.Sub(shownAt) < d.config.MinClickDelay {
+		reasons = append(reasons, ReasonTooFast)
+	}
+
+	sources := make([]string, 0, 2)
+	if e.IP != "" {
+		sources = append(sources, "ip:"+e.IP)
+	}
+	if e.UserID != "" {
+		sources = append(sources, "user:"+e.UserID)
+	}
+	tooMany := false
+	for _, source := range sources {
+		if d.countClick(source, at) > d.config.MaxClicks {
+			tooMany = true
+		}
+	}
+	if tooMany && d.config.MaxClicks > 0 {
+		reasons = append(reasons, ReasonClickRate)
+	}
+
+	b := d.banner(e.BannerID, at)
+	b.clicks++
+	if d.config.SpikeMinClicks > 0 && d.config.SpikeCTR > 0 && b.clicks >= d.config.SpikeMinClicks &&
+		float64(b.clicks) > d.config.SpikeCTR*float64(b.shows) {
+		reasons = append(reasons, ReasonCTRSpike)
+	}
+
+	return reasons
+}
+
+// countClick adds the click of the source and returns the number of its clicks in the window.
+func (d *Detector) countClick(source string, at time.Time) int {
+	recent := d.clicks[source][:0]
+	for _, t := range d.clicks[source] {
+		if at.Sub(t) < d.config.Window {
+			recent = append(recent, t)
+		}
+	}
+	d.clicks[source] = append(recent, at)
+
+	return len(d.clicks[source])
+}
+
+func (d *Detector) banner(bannerID int, at time.Time) *bannerWindow {
+	b, ok := d.banners[bannerID]
+	if !ok || at.Sub(b.start) >= d.config.Window {
+		b = &bannerWindow{start: at}
+		d.banners[bannerID] = b
+	}
+
+	return b
+}
+
+// sweep forgets the events which have left the window.
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.config.Window {
+		return
+	}
+	d.lastSweep = now
+
+	for key, t := range d.shows {
+		if now.Sub(t) >= d.config.Window {
+			delete(d.shows, key)
+		}
+	}
+	for source, times := range d.clicks {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= d.config.Window {
+			delete(d.clicks, source)
+		}
+	}
+	for bannerID, b := range d.banners {
+		if now.Sub(b.start) >= d.config.Window {
+			delete(d.banners, bannerID)
+		}
+	}
+}
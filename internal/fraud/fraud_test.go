@@ -0,0 +1,83 @@
+package fraud
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var config = Config{
+	Window:         time.Minute,
+	MaxClicks:      3,
+	MinClickDelay:  time.Second,
+	SpikeMinClicks: 5,
+	SpikeCTR:       0.5,
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, config.Validate())
+	assert.NoError(t, Config{Window: time.Minute}.Validate())
+	assert.Equal(t, ErrInvalidConfig, Config{}.Validate())
+	assert.Equal(t, ErrInvalidConfig, Config{Window: time.Minute, SpikeCTR: -1}.Validate())
+}
+
+func TestShowBeforeClick(t *testing.T) {
+	d, err := NewDetector(config)
+	assert.NoError(t, err)
+	at := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+	e := Event{SlotID: 1, BannerID: 2, GroupID: 3, UserID: "user"}
+
+	assert.Equal(t, []Reason{ReasonNoShow}, d.Click(e, at))
+	// the repository checks the shows of impressions
+	assert.Empty(t, d.Click(Event{ImpressionID: "abc", BannerID: 2}, at))
+
+	d.Show(e, at)
+	assert.Equal(t, []Reason{ReasonTooFast}, d.Click(e, at.Add(time.Millisecond*100)))
+	assert.Empty(t, d.Click(e, at.Add(time.Second*2)))
+	assert.Contains(t, d.Click(e, at.Add(time.Second*3)), ReasonClickRate)
+
+	// the show is forgotten when it leaves the window
+	assert.Contains(t, d.Click(Event{SlotID: 1, BannerID: 2, GroupID: 3}, at.Add(time.Minute*2)), ReasonNoShow)
+}
+
+func TestDelayedClick(t *testing.T) {
+	d, err := NewDetector(config)
+	assert.NoError(t, err)
+	at := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+	e := Event{ImpressionID: "abc", BannerID: 2}
+
+	d.Show(e, at)
+	assert.Equal(t, []Reason{ReasonTooFast}, d.Click(e, at))
+	e.Delayed = true
+	assert.Empty(t, d.Click(e, at))
+}
+
+func TestClickRate(t *testing.T) {
+	d, err := NewDetector(Config{Window: time.Minute, MaxClicks: 2})
+	assert.NoError(t, err)
+	at := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		assert.NotContains(t, d.Click(Event{BannerID: i, IP: "10.0.0.1"}, at), ReasonClickRate)
+	}
+	assert.Contains(t, d.Click(Event{BannerID: 3, IP: "10.0.0.1"}, at), ReasonClickRate)
+	assert.NotContains(t, d.Click(Event{BannerID: 3, IP: "10.0.0.2"}, at), ReasonClickRate)
+	assert.NotContains(t, d.Click(Event{BannerID: 3, IP: "10.0.0.1"}, at.Add(time.Minute)), ReasonClickRate)
+}
+
+func TestCTRSpike(t *testing.T) {
+	d, err := NewDetector(Config{Window: time.Minute, SpikeMinClicks: 3, SpikeCTR: 0.5})
+	assert.NoError(t, err)
+	at := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		d.Show(Event{ImpressionID: string(rune('a' + i)), BannerID: 1}, at)
+	}
+	assert.Empty(t, d.Click(Event{ImpressionID: "a", BannerID: 1}, at))
+	assert.Empty(t, d.Click(Event{ImpressionID: "b", BannerID: 1}, at))
+	assert.Equal(t, []Reason{ReasonCTRSpike}, d.Click(Event{ImpressionID: "c", BannerID: 1}, at))
+
+	// the window of the banner starts over
+	assert.Empty(t, d.Click(Event{ImpressionID: "d", BannerID: 1}, at.Add(time.Minute)))
+}
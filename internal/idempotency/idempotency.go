@@ -0,0 +1,30 @@
+// Package idempotency remembers the responses to requests sent with a client
+// supplied key, so that a repeated delivery gets the original response instead
+// of being processed again.
+package idempotency
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInProgress = errors.New("request with the same idempotency key is still in progress")
+
+// Response is the remembered response to a request.
+type Response struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+type Store interface {
+	// Reserve claims the key for a new request and returns nil. When the key has
+	// already been used within the window it returns the remembered response or
+	// ErrInProgress if the first request hasn't been finished yet.
+	Reserve(ctx context.Context, key string) (*Response, error)
+	// Save remembers the response to the request which reserved the key.
+	Save(ctx context.Context, key string, resp Response) error
+	// Release frees the key of a request which failed without any effect,
+	// so that it can be retried.
+	Release(ctx context.Context, key string) error
+}
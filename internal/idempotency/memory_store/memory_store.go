@@ -0,0 +1,98 @@
+package memorystore
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/idempotency"
+)
+
+type entry struct {
+	key       string
+	resp      *idempotency.Response
+	createdAt time.Time
+}
+
+// store keeps the keys in memory, the least recently used keys are evicted
+// once there are more of them than the size.
+type store struct {
+	mu     sync.Mutex
+	window time.Duration
+	size   int
+	order  *list.List
+	keys   map[string]*list.Element
+	now    func() time.Time
+}
+
+func NewStore(window time.Duration, size int) (idempotency.Store, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("idempotency window must be positive")
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("idempotency store size must be positive")
+	}
+
+	return &store{
+		window: window,
+		size:   size,
+		order:  list.New(),
+		keys:   make(map[string]*list.Element),
+		now:    time.Now,
+	}, nil
+}
+
+func (s *store) Reserve(_ context.Context, key string) (*idempotency.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if el, ok := s.keys[key]; ok {
+		e := el.Value.(*entry)
+		if now.Sub(e.createdAt) < s.window {
+			s.order.MoveToFront(el)
+			if e.resp == nil {
+				return nil, idempotency.ErrInProgress
+			}
+			return e.resp, nil
+		}
+		s.remove(el)
+	}
+
+	s.keys[key] = s.order.PushFront(&entry{key: key, createdAt: now})
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+
+	return nil, nil
+}
+
+func (s *store) Save(_ context.Context, key string, resp idempotency.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the key may have been evicted while the request was processed
+	if el, ok := s.keys[key]; ok {
+		el.Value.(*entry).resp = &resp
+	}
+
+	return nil
+}
+
+func (s *store) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.keys[key]; ok {
+		s.remove(el)
+	}
+
+	return nil
+}
+
+func (s *store) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.keys, el.Value.(*entry).key)
+}
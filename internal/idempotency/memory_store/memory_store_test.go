@@ -0,0 +1,85 @@
+package memorystore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/idempotency"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewStore(time.Minute, 10)
+	assert.NoError(t, err)
+
+	resp, err := s.Reserve(ctx, "key")
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	_, err = s.Reserve(ctx, "key")
+	assert.Equal(t, idempotency.ErrInProgress, err)
+
+	saved := idempotency.Response{StatusCode: 200, Body: []byte("ok")}
+	assert.NoError(t, s.Save(ctx, "key", saved))
+	resp, err = s.Reserve(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, &saved, resp)
+
+	resp, err = s.Reserve(ctx, "other")
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+	assert.NoError(t, s.Release(ctx, "other"))
+	resp, err = s.Reserve(ctx, "other")
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestWindow(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewStore(time.Minute, 10)
+	assert.NoError(t, err)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.(*store).now = func() time.Time { return now }
+
+	_, err = s.Reserve(ctx, "key")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Save(ctx, "key", idempotency.Response{StatusCode: 200}))
+
+	now = now.Add(time.Minute)
+	resp, err := s.Reserve(ctx, "key")
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewStore(time.Minute, 2)
+	assert.NoError(t, err)
+
+	for _, key := range []string{"a", "b"} {
+		_, err := s.Reserve(ctx, key)
+		assert.NoError(t, err)
+		assert.NoError(t, s.Save(ctx, key, idempotency.Response{StatusCode: 200}))
+	}
+
+	// "a" becomes the most recently used one, so "b" is evicted
+	resp, err := s.Reserve(ctx, "a")
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	_, err = s.Reserve(ctx, "c")
+	assert.NoError(t, err)
+
+	resp, err = s.Reserve(ctx, "a")
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	resp, err = s.Reserve(ctx, "b")
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	_, err = NewStore(time.Minute, 0)
+	assert.Error(t, err)
+}
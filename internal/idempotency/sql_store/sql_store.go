@@ -0,0 +1,122 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/idempotency"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// reserveAttempts bounds the retries of a key which is released or expires while it is reserved
+	reserveAttempts = 3
+	// maxSweepInterval is the longest time between the removals of the expired keys
+	maxSweepInterval = time.Minute
+)
+
+// store keeps the keys in the idempotency_keys table, so that they are
+// shared by all the instances of the service.
+type store struct {
+	db     *sql.DB
+	window time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewStore(db *sql.DB, window time.Duration) (idempotency.Store, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("idempotency window must be positive")
+	}
+
+	return &store{
+		db:     db,
+		window: window,
+		now:    time.Now,
+	}, nil
+}
+
+// Reserve takes over an expired key in place, so the keys found by the lookup are never older than the window.
+func (s *store) Reserve(ctx context.Context, key string) (*idempotency.Response, error) {
+	s.sweep(ctx)
+
+	for attempt := 0; attempt < reserveAttempts; attempt++ {
+		expiredBefore := s.now().Add(-s.window)
+		result, err := s.db.ExecContext(ctx, `INSERT INTO idempotency_keys (key) VALUES ($1)
+ON CONFLICT (key) DO UPDATE SET status_code = NULL, content_type = DEFAULT, body = NULL, created_at = DEFAULT
+WHERE idempotency_keys.created_at < $2;`, key, expiredBefore)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rows > 0 {
+			return nil, nil
+		}
+
+		statusCode := sql.NullInt32{}
+		resp := idempotency.Response{}
+		err = s.db.QueryRowContext(ctx, "SELECT status_code, content_type, body FROM idempotency_keys WHERE key = $1 AND created_at >= $2;",
+			key, expiredBefore).Scan(&statusCode, &resp.ContentType, &resp.Body)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the first request has been released meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !statusCode.Valid {
+			return nil, idempotency.ErrInProgress
+		}
+		resp.StatusCode = int(statusCode.Int32)
+
+		return &resp, nil
+	}
+
+	// the key is contended by the requests which keep failing, the client retries later
+	return nil, idempotency.ErrInProgress
+}
+
+// sweep removes the expired keys at most once in the sweep interval, so that the table
+// never grows beyond a window of requests without a delete on every request.
+func (s *store) sweep(ctx context.Context) {
+	interval := s.window
+	if interval > maxSweepInterval {
+		interval = maxSweepInterval
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	if now.Sub(s.lastSweep) < interval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < $1;", now.Add(-s.window)); err != nil {
+		log.Error("failed to remove expired idempotency keys: ", err.Error())
+	}
+}
+
+func (s *store) Save(ctx context.Context, key string, resp idempotency.Response) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE idempotency_keys SET status_code = $2, content_type = $3, body = $4 WHERE key = $1;",
+		key, resp.StatusCode, resp.ContentType, resp.Body)
+
+	return err
+}
+
+func (s *store) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL;", key)
+
+	return err
+}
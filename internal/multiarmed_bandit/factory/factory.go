@@ -0,0 +1,29 @@
+// Package factory makes the bandits of all the known algorithms from their configs.
+package factory
+
+import (
+	multiarmedbandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
+	"github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit/thompson"
+	"github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit/ucb"
+	"github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit/uniform"
+)
+
+// New is a multiarmedbandit.Factory, zero exploration of ucb stands for the default one.
+func New(c multiarmedbandit.Config) (multiarmedbandit.MultiarmedBandit, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch c.Algorithm {
+	case multiarmedbandit.AlgorithmUCB:
+		exploration := c.Exploration
+		if exploration == 0 {
+			exploration = ucb.DefaultExploration
+		}
+		return ucb.NewUCBBandit(exploration)
+	case multiarmedbandit.AlgorithmUniform:
+		return uniform.NewUniformBandit()
+	}
+
+	return thompson.NewThompsonBandit(c.MinEvents)
+}
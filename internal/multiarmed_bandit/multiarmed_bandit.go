@@ -1,5 +1,9 @@
 package multiarmedbandit
 
+import "errors"
+
+var ErrInvalidConfig = errors.New("bandit must have a known algorithm and non-negative parameters")
+
 type BannerStatistic struct {
 	BannerID    int
 	Impressions int
@@ -11,3 +15,32 @@ type BannersStatistic = []BannerStatistic
 type MultiarmedBandit interface {
 	GetBanner(s BannersStatistic) (BannerStatistic, error)
 }
+
+type Algorithm string
+
+const (
+	AlgorithmThompson Algorithm = "thompson"
+	AlgorithmUCB      Algorithm = "ucb"
+	AlgorithmUniform  Algorithm = "uniform"
+)
+
+// Config describes a bandit, MinEvents is used by thompson and Exploration by ucb.
+type Config struct {
+	Algorithm   Algorithm `json:"algorithm"`
+	MinEvents   int       `json:"min_events,omitempty"`
+	Exploration float64   `json:"exploration,omitempty"`
+}
+
+func (c Config) Validate() error {
+	if c.Algorithm != AlgorithmThompson && c.Algorithm != AlgorithmUCB && c.Algorithm != AlgorithmUniform {
+		return ErrInvalidConfig
+	}
+	if c.MinEvents < 0 || c.Exploration < 0 {
+		return ErrInvalidConfig
+	}
+
+	return nil
+}
+
+// Factory makes a bandit from its config.
+type Factory func(c Config) (MultiarmedBandit, error)
@@ -0,0 +1,55 @@
+package ucb
+
+import (
+	"errors"
+	"math"
+
+	multiarmedbandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
+	"github.com/bubblesupreme/banner_rotation/utils"
+)
+
+// DefaultExploration is the exploration factor of the classic UCB1.
+const DefaultExploration = math.Sqrt2
+
+type ucbBandit struct {
+	exploration float64
+}
+
+// NewUCBBandit makes a UCB1 bandit, which chooses the banner with the highest upper confidence
+// bound of its click rate. The exploration factor scales the confidence interval.
+func NewUCBBandit(exploration float64) (multiarmedbandit.MultiarmedBandit, error) {
+	if exploration < 0 {
+		return nil, errors.New("ucb exploration factor must not be negative")
+	}
+
+	return &ucbBandit{
+		exploration: exploration,
+	}, nil
+}
+
+func (u *ucbBandit) GetBanner(s multiarmedbandit.BannersStatistic) (multiarmedbandit.BannerStatistic, error) {
+	if len(s) == 0 {
+		return multiarmedbandit.BannerStatistic{}, utils.ErrNoStatistic
+	}
+
+	total := 0
+	for _, b := range s {
+		// banners which have never been shown go first
+		if b.Impressions == 0 {
+			return b, nil
+		}
+		total += b.Impressions
+	}
+
+	best := 0
+	bestBound := math.Inf(-1)
+	for i, b := range s {
+		bound := float64(b.Clicks)/float64(b.Impressions) + u.exploration*math.Sqrt(math.Log(float64(total))/float64(b.Impressions))
+		if bound > bestBound {
+			best = i
+			bestBound = bound
+		}
+	}
+
+	return s[best], nil
+}
@@ -0,0 +1,53 @@
+package ucb
+
+import (
+	"testing"
+
+	multiarmedbandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
+	"github.com/bubblesupreme/banner_rotation/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUCBColdFirst(t *testing.T) {
+	bandit, err := NewUCBBandit(DefaultExploration)
+	assert.NoError(t, err)
+
+	b, err := bandit.GetBanner(multiarmedbandit.BannersStatistic{
+		{BannerID: 1, Impressions: 100, Clicks: 50},
+		{BannerID: 2, Impressions: 0},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, b.BannerID)
+}
+
+func TestUCBChoosesBound(t *testing.T) {
+	s := multiarmedbandit.BannersStatistic{
+		{BannerID: 1, Impressions: 1000, Clicks: 100},
+		{BannerID: 2, Impressions: 1000, Clicks: 200},
+		{BannerID: 3, Impressions: 10, Clicks: 1},
+	}
+
+	greedy, err := NewUCBBandit(0)
+	assert.NoError(t, err)
+	b, err := greedy.GetBanner(s)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, b.BannerID)
+
+	// the rarely shown banner has the widest confidence interval
+	explorer, err := NewUCBBandit(DefaultExploration)
+	assert.NoError(t, err)
+	b, err = explorer.GetBanner(s)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, b.BannerID)
+}
+
+func TestUCBErrors(t *testing.T) {
+	_, err := NewUCBBandit(-1)
+	assert.Error(t, err)
+
+	bandit, err := NewUCBBandit(DefaultExploration)
+	assert.NoError(t, err)
+	_, err = bandit.GetBanner(nil)
+	assert.Equal(t, utils.ErrNoStatistic, err)
+}
@@ -0,0 +1,24 @@
+package uniform
+
+import (
+	"math/rand"
+
+	multiarmedbandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
+	"github.com/bubblesupreme/banner_rotation/utils"
+)
+
+type uniformBandit struct{}
+
+// NewUniformBandit makes a bandit which ignores the statistic and chooses every banner
+// with the same probability, it serves the holdout which the other bandits are compared with.
+func NewUniformBandit() (multiarmedbandit.MultiarmedBandit, error) {
+	return &uniformBandit{}, nil
+}
+
+func (u *uniformBandit) GetBanner(s multiarmedbandit.BannersStatistic) (multiarmedbandit.BannerStatistic, error) {
+	if len(s) == 0 {
+		return multiarmedbandit.BannerStatistic{}, utils.ErrNoStatistic
+	}
+
+	return s[rand.Intn(len(s))], nil //nolint:gosec
+}
@@ -0,0 +1,31 @@
+package uniform
+
+import (
+	"testing"
+
+	multiarmedbandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
+	"github.com/bubblesupreme/banner_rotation/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniformIgnoresStatistic(t *testing.T) {
+	bandit, err := NewUniformBandit()
+	assert.NoError(t, err)
+
+	s := multiarmedbandit.BannersStatistic{
+		{BannerID: 1, Impressions: 1000, Clicks: 900},
+		{BannerID: 2, Impressions: 1000, Clicks: 0},
+	}
+	counts := make(map[int]int)
+	for i := 0; i < 2000; i++ {
+		b, err := bandit.GetBanner(s)
+		assert.NoError(t, err)
+		counts[b.BannerID]++
+	}
+	assert.InDelta(t, 1000, counts[1], 150)
+	assert.InDelta(t, 1000, counts[2], 150)
+
+	_, err = bandit.GetBanner(nil)
+	assert.Equal(t, utils.ErrNoStatistic, err)
+}
@@ -0,0 +1,119 @@
+// Package pacing describes impression and spend budgets and decides whether
+// a budgeted item may be served, spreading its daily budget across the day.
+package pacing
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidBudget = errors.New("budget must have a known metric, a positive daily or lifetime limit, " +
+	"a positive cpm for the spend metric and a known pacing mode")
+
+// Metric is what a budget limits.
+type Metric string
+
+const (
+	MetricImpressions Metric = "impressions"
+	// MetricSpend limits the cost of the shows, every show costs a thousandth of the budget cpm.
+	MetricSpend Metric = "spend"
+)
+
+// Mode tells how the daily budget is delivered.
+type Mode string
+
+const (
+	// ModeEven holds the delivery back whenever it gets ahead of the share of the day passed.
+	ModeEven Mode = "even"
+	// ModeASAP serves the item until the daily budget is exhausted.
+	ModeASAP Mode = "asap"
+)
+
+// Lead is how far ahead of the even schedule the delivery may go,
+// it lets an item be served right after the day starts.
+const Lead = time.Hour
+
+// Budget limits the shows of an item per UTC day and over its lifetime, a zero limit isn't checked.
+type Budget struct {
+	Metric   Metric  `json:"metric"`
+	Daily    float64 `json:"daily,omitempty"`
+	Lifetime float64 `json:"lifetime,omitempty"`
+	// CPM is the cost of a thousand shows, it is required by the spend metric.
+	CPM    float64 `json:"cpm,omitempty"`
+	Pacing Mode    `json:"pacing,omitempty"`
+}
+
+// Counter holds the shows of an item and their cost.
+type Counter struct {
+	Impressions int64   `json:"impressions"`
+	Spend       float64 `json:"spend"`
+}
+
+// Usage holds the shows of an item during the current day and over its lifetime.
+type Usage struct {
+	Day      Counter `json:"day"`
+	Lifetime Counter `json:"lifetime"`
+}
+
+func (b Budget) Validate() error {
+	if b.Metric != MetricImpressions && b.Metric != MetricSpend {
+		return ErrInvalidBudget
+	}
+	if b.Daily < 0 || b.Lifetime < 0 || b.Empty() {
+		return ErrInvalidBudget
+	}
+	if b.CPM < 0 || (b.Metric == MetricSpend && b.CPM == 0) {
+		return ErrInvalidBudget
+	}
+	if b.Pacing != "" && b.Pacing != ModeEven && b.Pacing != ModeASAP {
+		return ErrInvalidBudget
+	}
+
+	return nil
+}
+
+// Empty reports whether the budget limits nothing.
+func (b Budget) Empty() bool {
+	return b.Daily == 0 && b.Lifetime == 0
+}
+
+// Cost returns the spend of the given number of shows.
+func (b Budget) Cost(shows int64) float64 {
+	return float64(shows) * b.CPM / 1000
+}
+
+// Allow reports whether the item with the given usage may be served at the given time.
+// Budgets without a pacing mode are paced evenly.
+func (b Budget) Allow(u Usage, at time.Time) bool {
+	if b.Lifetime > 0 && b.used(u.Lifetime) >= b.Lifetime {
+		return false
+	}
+	if b.Daily == 0 {
+		return true
+	}
+
+	used := b.used(u.Day)
+	if used >= b.Daily {
+		return false
+	}
+	if b.Pacing == ModeASAP {
+		return true
+	}
+
+	share := float64(at.Sub(Day(at))+Lead) / float64(24*time.Hour)
+
+	return used < b.Daily*share
+}
+
+func (b Budget) used(c Counter) float64 {
+	if b.Metric == MetricSpend {
+		return c.Spend
+	}
+
+	return float64(c.Impressions)
+}
+
+// Day returns the start of the UTC day which the budget counters of the given time belong to.
+func Day(at time.Time) time.Time {
+	return at.UTC().Truncate(24 * time.Hour)
+}
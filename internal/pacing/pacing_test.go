@@ -0,0 +1,47 @@
+package pacing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Budget{Metric: MetricImpressions, Daily: 100}.Validate())
+	assert.NoError(t, Budget{Metric: MetricSpend, Lifetime: 50, CPM: 2.5, Pacing: ModeASAP}.Validate())
+
+	for _, b := range []Budget{
+		{Daily: 100},
+		{Metric: MetricImpressions},
+		{Metric: MetricImpressions, Daily: -1, Lifetime: 10},
+		{Metric: MetricSpend, Daily: 100},
+		{Metric: MetricImpressions, Daily: 100, Pacing: "fast"},
+	} {
+		assert.Equal(t, ErrInvalidBudget, b.Validate(), b)
+	}
+}
+
+func TestAllowEven(t *testing.T) {
+	b := Budget{Metric: MetricImpressions, Daily: 2400}
+	morning := time.Date(2026, 10, 19, 5, 0, 0, 0, time.UTC)
+
+	// 6 hours of 24 may be delivered by 5 am, counting the lead
+	assert.True(t, b.Allow(Usage{Day: Counter{Impressions: 599}}, morning))
+	assert.False(t, b.Allow(Usage{Day: Counter{Impressions: 600}}, morning))
+	assert.True(t, b.Allow(Usage{Day: Counter{Impressions: 600}}, morning.Add(time.Hour)))
+	assert.True(t, b.Allow(Usage{}, Day(morning)))
+
+	asap := Budget{Metric: MetricImpressions, Daily: 2400, Pacing: ModeASAP}
+	assert.True(t, asap.Allow(Usage{Day: Counter{Impressions: 2399}}, morning))
+	assert.False(t, asap.Allow(Usage{Day: Counter{Impressions: 2400}}, morning))
+}
+
+func TestAllowLifetime(t *testing.T) {
+	b := Budget{Metric: MetricSpend, Lifetime: 10, CPM: 1000, Pacing: ModeASAP}
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, float64(3), b.Cost(3))
+	assert.True(t, b.Allow(Usage{Lifetime: Counter{Impressions: 9, Spend: 9}}, at))
+	assert.False(t, b.Allow(Usage{Lifetime: Counter{Impressions: 10, Spend: 10}}, at))
+}
@@ -9,5 +9,8 @@ type Action struct {
 type Producer interface {
 	Show(a Action) error
 	Click(a Action) error
+	// Publish sends a batch of shows and then clicks, it stops at the first failure
+	// and returns the number of the actions sent before it.
+	Publish(shows, clicks []Action) (int, error)
 	Shutdown() error
 }
@@ -68,6 +68,24 @@ func (p *publisher) Click(a producer.Action) error {
 	return p.publish(a, p.clickRoutingKey)
 }
 
+func (p *publisher) Publish(shows, clicks []producer.Action) (int, error) {
+	sent := 0
+	for _, a := range shows {
+		if err := p.publish(a, p.showRoutingKey); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	for _, a := range clicks {
+		if err := p.publish(a, p.clickRoutingKey); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
 func (p *publisher) publish(a producer.Action, routingKey string) error {
 	b, err := actionToByteArray(a)
 	if err != nil {
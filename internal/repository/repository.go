@@ -1,11 +1,381 @@
 package repository
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/alert"
+	"github.com/bubblesupreme/banner_rotation/internal/analytics"
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+	"github.com/bubblesupreme/banner_rotation/internal/delivery"
+	"github.com/bubblesupreme/banner_rotation/internal/experiment"
+	"github.com/bubblesupreme/banner_rotation/internal/fraud"
+	"github.com/bubblesupreme/banner_rotation/internal/pacing"
+	"github.com/bubblesupreme/banner_rotation/internal/schedule"
+	"github.com/bubblesupreme/banner_rotation/internal/segment"
+)
+
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrNoEligibleBanner  = errors.New("no eligible banners")
+	ErrStatusTransition  = errors.New("status transition is not allowed")
+	ErrInvalidFlightTime = errors.New("banner end time must be after its start time")
+	ErrVersionConflict   = errors.New("the item was changed since the given version")
+	ErrAlreadyExists     = errors.New("already exists")
+	ErrNotShown          = errors.New("impression wasn't shown")
+	ErrInvalidCap        = errors.New("frequency cap must have a positive limit and an hour or a day period")
+	ErrInUse             = errors.New("is in use")
+)
+
+// BannerStatus is a stage of the banner lifecycle. Only active banners within
+// their flight dates take part in the rotation.
+type BannerStatus string
+
+const (
+	BannerDraft    BannerStatus = "draft"
+	BannerActive   BannerStatus = "active"
+	BannerPaused   BannerStatus = "paused"
+	BannerArchived BannerStatus = "archived"
+)
+
+// Valid reports whether s is one of the known banner statuses.
+func (s BannerStatus) Valid() bool {
+	switch s {
+	case BannerDraft, BannerActive, BannerPaused, BannerArchived:
+		return true
+	}
+
+	return false
+}
+
+// CanChangeStatus reports whether a banner may be moved from one status to another.
+// Archived banners keep their history but can't be brought back into the rotation.
+func CanChangeStatus(from, to BannerStatus) bool {
+	switch to {
+	case BannerActive:
+		return from == BannerDraft || from == BannerPaused
+	case BannerPaused:
+		return from == BannerActive
+	case BannerArchived:
+		return from == BannerDraft || from == BannerActive || from == BannerPaused
+	}
+
+	return false
+}
 
 type Banner struct {
-	ID          int    `json:"id"`
-	URL         string `json:"url"`
-	Description string `json:"description"`
+	ID          int          `json:"id"`
+	URL         string       `json:"url"`
+	Description string       `json:"description"`
+	Status      BannerStatus `json:"status"`
+	StartAt     *time.Time   `json:"start_at,omitempty"`
+	EndAt       *time.Time   `json:"end_at,omitempty"`
+	Version     int          `json:"version"`
+	// Labels mark competing banners, banners sharing a label are never shown on the same page.
+	Labels       []string      `json:"labels,omitempty"`
+	FrequencyCap *FrequencyCap `json:"frequency_cap,omitempty"`
+	// CampaignID is zero for banners which don't belong to any campaign.
+	CampaignID int            `json:"campaign_id,omitempty"`
+	Budget     *pacing.Budget `json:"budget,omitempty"`
+}
+
+// FrequencyCap limits how many times a banner is shown to the same user within a period.
+type FrequencyCap struct {
+	Limit  int            `json:"limit"`
+	Period capping.Period `json:"period"`
+}
+
+func (c FrequencyCap) Validate() error {
+	if c.Limit <= 0 || !c.Period.Valid() {
+		return ErrInvalidCap
+	}
+
+	return nil
+}
+
+// BannerFilter reports whether the banner may be served.
+type BannerFilter func(b Banner) bool
+
+// SharesLabel reports whether the banner has any of the labels.
+func (b Banner) SharesLabel(labels map[string]bool) bool {
+	for _, l := range b.Labels {
+		if labels[l] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BannerUpdate lists the banner fields to change, nil fields are left as they are.
+type BannerUpdate struct {
+	URL         *string
+	Description *string
+	// StartAt and EndAt replace the flight of the banner, the zero time removes the bound.
+	StartAt *time.Time
+	EndAt   *time.Time
+	Labels  *[]string
+	// FrequencyCap replaces the cap of the banner, a cap with zero limit removes it.
+	FrequencyCap *FrequencyCap
+	// CampaignID moves the banner to another campaign, zero takes it out of its campaign.
+	CampaignID *int
+	// Budget replaces the budget of the banner, a budget without limits removes it.
+	Budget *pacing.Budget
+}
+
+// Apply returns a copy of the banner with the update fields set.
+func (u BannerUpdate) Apply(b Banner) Banner {
+	if u.URL != nil {
+		b.URL = *u.URL
+	}
+	if u.Description != nil {
+		b.Description = *u.Description
+	}
+	if u.StartAt != nil {
+		b.StartAt = timeBound(*u.StartAt)
+	}
+	if u.EndAt != nil {
+		b.EndAt = timeBound(*u.EndAt)
+	}
+	if u.Labels != nil {
+		b.Labels = *u.Labels
+	}
+	if u.FrequencyCap != nil {
+		b.FrequencyCap = u.FrequencyCap
+		if u.FrequencyCap.Limit == 0 {
+			b.FrequencyCap = nil
+		}
+	}
+	if u.CampaignID != nil {
+		b.CampaignID = *u.CampaignID
+	}
+	b.Budget = applyBudget(b.Budget, u.Budget)
+
+	return b
+}
+
+// timeBound returns the bound of the flight set by an update, the zero time removes it.
+func timeBound(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// applyBudget returns the budget replaced by the update, a budget without limits removes it.
+func applyBudget(current, update *pacing.Budget) *pacing.Budget {
+	if update == nil {
+		return current
+	}
+	if update.Empty() {
+		return nil
+	}
+
+	return update
+}
+
+// ValidateBudget checks the budget of the banner if it has one.
+func (b Banner) ValidateBudget() error {
+	if b.Budget == nil {
+		return nil
+	}
+
+	return b.Budget.Validate()
+}
+
+// Eligible reports whether the banner can be shown at the given time.
+func (b Banner) Eligible(at time.Time) bool {
+	if b.Status != BannerActive {
+		return false
+	}
+	if b.StartAt != nil && at.Before(*b.StartAt) {
+		return false
+	}
+
+	return b.EndAt == nil || at.Before(*b.EndAt)
+}
+
+// ValidateCap checks the frequency cap of the banner if it has one.
+func (b Banner) ValidateCap() error {
+	if b.FrequencyCap == nil {
+		return nil
+	}
+
+	return b.FrequencyCap.Validate()
+}
+
+// ValidateFlight checks that the flight dates, if both are set, form a non-empty interval.
+func (b Banner) ValidateFlight() error {
+	if b.StartAt != nil && b.EndAt != nil && !b.EndAt.After(*b.StartAt) {
+		return ErrInvalidFlightTime
+	}
+
+	return nil
+}
+
+type Advertiser struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+// CampaignStatus tells whether the banners of a campaign take part in the rotation.
+type CampaignStatus string
+
+const (
+	CampaignActive CampaignStatus = "active"
+	CampaignPaused CampaignStatus = "paused"
+)
+
+// Valid reports whether s is one of the known campaign statuses.
+func (s CampaignStatus) Valid() bool {
+	return s == CampaignActive || s == CampaignPaused
+}
+
+// Campaign unites banners of an advertiser, so they can be paused and reported on as a unit.
+type Campaign struct {
+	ID           int            `json:"id"`
+	AdvertiserID int            `json:"advertiser_id"`
+	Name         string         `json:"name"`
+	Status       CampaignStatus `json:"status"`
+	Version      int            `json:"version"`
+	// Budget limits the shows of all banners of the campaign together.
+	Budget *pacing.Budget `json:"budget,omitempty"`
+}
+
+// CampaignUpdate lists the campaign fields to change, nil fields are left as they are.
+type CampaignUpdate struct {
+	Name *string
+	// Budget replaces the budget of the campaign, a budget without limits removes it.
+	Budget *pacing.Budget
+}
+
+// Apply returns a copy of the campaign with the update fields set.
+func (u CampaignUpdate) Apply(c Campaign) Campaign {
+	if u.Name != nil {
+		c.Name = *u.Name
+	}
+	c.Budget = applyBudget(c.Budget, u.Budget)
+
+	return c
+}
+
+// ValidateBudget checks the budget of the campaign if it has one.
+func (c Campaign) ValidateBudget() error {
+	if c.Budget == nil {
+		return nil
+	}
+
+	return c.Budget.Validate()
+}
+
+// BudgetOwner is the kind of item which a budget belongs to.
+type BudgetOwner string
+
+const (
+	BudgetBanner   BudgetOwner = "banner"
+	BudgetCampaign BudgetOwner = "campaign"
+)
+
+// BudgetState is a budget of a banner or of its campaign together with its usage.
+type BudgetState struct {
+	Owner   BudgetOwner   `json:"owner"`
+	OwnerID int           `json:"owner_id"`
+	Budget  pacing.Budget `json:"budget"`
+	Usage   pacing.Usage  `json:"usage"`
+}
+
+// RollupLevel is the level which relation counters are summed up to.
+type RollupLevel string
+
+const (
+	RollupCampaign   RollupLevel = "campaign"
+	RollupAdvertiser RollupLevel = "advertiser"
+)
+
+// Valid reports whether l is one of the known rollup levels.
+func (l RollupLevel) Valid() bool {
+	return l == RollupCampaign || l == RollupAdvertiser
+}
+
+// Rollup holds the counters of all banners of a campaign or an advertiser.
+type Rollup struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// AdvertiserID is set for campaigns only.
+	AdvertiserID int   `json:"advertiser_id,omitempty"`
+	Impressions  int64 `json:"impressions"`
+	Clicks       int64 `json:"clicks"`
+}
+
+// Granularity is the length of the time buckets of a report.
+type Granularity string
+
+const (
+	GranularityHour Granularity = "hour"
+	GranularityDay  Granularity = "day"
+	GranularityWeek Granularity = "week"
+)
+
+// Valid reports whether g is one of the known granularities.
+func (g Granularity) Valid() bool {
+	return g == GranularityHour || g == GranularityDay || g == GranularityWeek
+}
+
+// Dimension is what the events of a report are grouped by.
+type Dimension string
+
+const (
+	DimensionSlot     Dimension = "slot"
+	DimensionBanner   Dimension = "banner"
+	DimensionGroup    Dimension = "group"
+	DimensionCampaign Dimension = "campaign"
+)
+
+// Valid reports whether d is one of the known dimensions.
+func (d Dimension) Valid() bool {
+	return d == DimensionSlot || d == DimensionBanner || d == DimensionGroup || d == DimensionCampaign
+}
+
+// ReportQuery selects the events created from From up to To, they are summed up
+// into the buckets of the granularity for every combination of the dimensions.
+type ReportQuery struct {
+	From        time.Time
+	To          time.Time
+	Granularity Granularity
+	GroupBy     []Dimension
+}
+
+// ReportRow holds the events of a time bucket, the dimensions which the report isn't grouped by are zero.
+// The campaign is zero for the banners outside of campaigns.
+type ReportRow struct {
+	Time        time.Time `json:"time"`
+	SlotID      int       `json:"slot,omitempty"`
+	BannerID    int       `json:"banner,omitempty"`
+	GroupID     int       `json:"group,omitempty"`
+	CampaignID  int       `json:"campaign,omitempty"`
+	Impressions int64     `json:"impressions"`
+	Clicks      int64     `json:"clicks"`
+	CTR         float64   `json:"ctr"`
+}
+
+// Dimension returns the id of the row in the dimension.
+func (r ReportRow) Dimension(d Dimension) int {
+	switch d {
+	case DimensionSlot:
+		return r.SlotID
+	case DimensionBanner:
+		return r.BannerID
+	case DimensionGroup:
+		return r.GroupID
+	case DimensionCampaign:
+		return r.CampaignID
+	}
+
+	return 0
 }
 
 type Slot struct {
@@ -15,20 +385,403 @@ type Slot struct {
 type Group struct {
 	ID          int    `json:"id"`
 	Description string `json:"description"`
+	Version     int    `json:"version"`
+	// Segment describes the users of the group, see the segment package.
+	Segment *segment.Segment `json:"segment,omitempty"`
+	// Fallback marks the only group of the users who don't match any segment.
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// GroupUpdate lists the group fields to change, nil fields are left as they are.
+type GroupUpdate struct {
+	Description *string
+	// Segment replaces the segment of the group, a segment without conditions removes it.
+	Segment *segment.Segment
+	// Fallback set to true takes the fallback mark from the group which has it.
+	Fallback *bool
+}
+
+// Apply returns a copy of the group with the update fields set.
+func (u GroupUpdate) Apply(g Group) Group {
+	if u.Description != nil {
+		g.Description = *u.Description
+	}
+	if u.Segment != nil {
+		g.Segment = u.Segment
+		if len(u.Segment.Conditions) == 0 {
+			g.Segment = nil
+		}
+	}
+	if u.Fallback != nil {
+		g.Fallback = *u.Fallback
+	}
+
+	return g
+}
+
+// ValidateSegment checks the segment of the group if it has one.
+func (g Group) ValidateSegment() error {
+	if g.Segment == nil {
+		return nil
+	}
+
+	return g.Segment.Validate()
+}
+
+// SlotBanner is a banner of a slot together with its schedule in the slot.
+type SlotBanner struct {
+	Banner
+	Schedule *schedule.Schedule `json:"schedule,omitempty"`
+	// CampaignPaused tells that the campaign of the banner is paused.
+	CampaignPaused bool `json:"campaign_paused,omitempty"`
+	// Budgets hold the budgets of the banner and of its campaign.
+	Budgets []BudgetState `json:"budgets,omitempty"`
+	// Delivery is nil for the standard banners.
+	Delivery *delivery.Settings `json:"delivery,omitempty"`
+}
+
+// GuaranteeReport tells whether the banner gets the share of the slot impressions guaranteed to it.
+type GuaranteeReport struct {
+	SlotID   int `json:"slot"`
+	BannerID int `json:"banner"`
+	delivery.Progress
+}
+
+// Eligible reports whether the banner can be shown in the slot at the given time.
+func (sb SlotBanner) Eligible(at time.Time) bool {
+	if !sb.Banner.Eligible(at) || sb.CampaignPaused || (sb.Schedule != nil && !sb.Schedule.Active(at)) {
+		return false
+	}
+	for _, b := range sb.Budgets {
+		if !b.Budget.Allow(b.Usage, at) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Relation links a banner to a slot for every social group.
+type Relation struct {
+	SlotID   int `json:"slot"`
+	BannerID int `json:"banner"`
+}
+
+// Configuration is the whole rotation setup moved by import and export.
+// Identifiers in an imported configuration only link its items together:
+// banners are matched by url and description, groups by description and
+// slots by id, everything else is created.
+type Configuration struct {
+	Banners   []Banner   `json:"banners"`
+	Slots     []Slot     `json:"slots"`
+	Groups    []Group    `json:"groups"`
+	Relations []Relation `json:"relations"`
+}
+
+// ImportCounts holds how many items of a kind an import creates and how many already exist.
+type ImportCounts struct {
+	Created  int `json:"created"`
+	Existing int `json:"existing"`
+}
+
+// ImportReport describes the changes made by an import, or the changes it would make in the dry run mode.
+type ImportReport struct {
+	DryRun    bool         `json:"dry_run"`
+	Banners   ImportCounts `json:"banners"`
+	Slots     ImportCounts `json:"slots"`
+	Groups    ImportCounts `json:"groups"`
+	Relations ImportCounts `json:"relations"`
+}
+
+// StatisticScope selects the relations of a slot, of a group or of a slot within a group,
+// a zero id matches any slot or group.
+type StatisticScope struct {
+	SlotID  int `json:"slot,omitempty"`
+	GroupID int `json:"group,omitempty"`
+}
+
+// Empty reports whether the scope matches every relation.
+func (s StatisticScope) Empty() bool {
+	return s.SlotID == 0 && s.GroupID == 0
+}
+
+// Snapshot is a named copy of the relation counters taken for a scope.
+type Snapshot struct {
+	ID        int            `json:"id"`
+	Name      string         `json:"name"`
+	Comment   string         `json:"comment"`
+	Scope     StatisticScope `json:"scope"`
+	Relations int            `json:"relations"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// EventType tells what happened to a served banner.
+type EventType string
+
+const (
+	EventShow  EventType = "show"
+	EventClick EventType = "click"
+)
+
+// Event is a show or a click of a banner, events of signed impressions
+// carry the impression id and are counted once per impression.
+type Event struct {
+	Type         EventType
+	ImpressionID string
+	SlotID       int
+	BannerID     int
+	GroupID      int
+}
+
+// ExperimentStatus tells whether the experiment splits the traffic of its slot.
+type ExperimentStatus string
+
+const (
+	ExperimentRunning ExperimentStatus = "running"
+	ExperimentStopped ExperimentStatus = "stopped"
+)
+
+// Experiment splits the traffic of a slot between the arms, each arm chooses banners
+// with its own bandit on its own statistics. A slot runs one experiment at a time.
+type Experiment struct {
+	ID        int              `json:"id"`
+	SlotID    int              `json:"slot"`
+	Name      string           `json:"name"`
+	Arms      []experiment.Arm `json:"arms"`
+	Status    ExperimentStatus `json:"status"`
+	CreatedAt time.Time        `json:"created_at"`
+	StoppedAt *time.Time       `json:"stopped_at,omitempty"`
+}
+
+// ArmRef refers to the way a request is served apart from the slot bandit: by an arm of an experiment
+// or in the holdout, which chooses banners at random. The zero value stands for the slot bandit and statistics.
+type ArmRef struct {
+	ExperimentID int    `json:"experiment,omitempty"`
+	Arm          string `json:"arm,omitempty"`
+	Holdout      bool   `json:"holdout,omitempty"`
+}
+
+// Empty reports whether the reference is outside of any experiment and of the holdout.
+func (a ArmRef) Empty() bool {
+	return a.ExperimentID == 0 && !a.Holdout
+}
+
+// HoldoutReport compares the traffic of the slot served by the bandits with the holdout.
+type HoldoutReport struct {
+	SlotID  int                    `json:"slot"`
+	Bandit  experiment.Performance `json:"bandit"`
+	Holdout experiment.Performance `json:"holdout"`
+	// Lift is the relative gain of the bandit click rate over the holdout one.
+	Lift float64 `json:"lift"`
+}
+
+// ArmEvent is an event of a banner served by an experiment arm.
+type ArmEvent struct {
+	ArmRef
+	Event
+}
+
+// Actions written to the audit log.
+const (
+	AuditAddSlot          = "add_slot"
+	AuditRemoveSlot       = "remove_slot"
+	AuditRestoreSlot      = "restore_slot"
+	AuditAddBanner        = "add_banner"
+	AuditUpdateBanner     = "update_banner"
+	AuditPauseBanner      = "pause_banner"
+	AuditResumeBanner     = "resume_banner"
+	AuditArchiveBanner    = "archive_banner"
+	AuditRemoveBanner     = "remove_banner"
+	AuditRestoreBanner    = "restore_banner"
+	AuditAddRelation      = "add_relation"
+	AuditRemoveRelation   = "remove_relation"
+	AuditAddGroup         = "add_group"
+	AuditUpdateGroup      = "update_group"
+	AuditRemoveGroup      = "remove_group"
+	AuditRestoreGroup     = "restore_group"
+	AuditImport           = "import"
+	AuditAddSnapshot      = "add_snapshot"
+	AuditResetStatistic   = "reset_statistic"
+	AuditRestoreStatistic = "restore_statistic"
+	AuditSetSchedule      = "set_schedule"
+	AuditRemoveSchedule   = "remove_schedule"
+	AuditSetDelivery      = "set_delivery"
+	AuditRemoveDelivery   = "remove_delivery"
+	AuditAddExperiment    = "add_experiment"
+	AuditStopExperiment   = "stop_experiment"
+	AuditAddAdvertiser    = "add_advertiser"
+	AuditUpdateAdvertiser = "update_advertiser"
+	AuditRemoveAdvertiser = "remove_advertiser"
+	AuditAddCampaign      = "add_campaign"
+	AuditUpdateCampaign   = "update_campaign"
+	AuditPauseCampaign    = "pause_campaign"
+	AuditResumeCampaign   = "resume_campaign"
+	AuditRemoveCampaign   = "remove_campaign"
+)
+
+// AuditRecord describes a single administrative change, Before and After hold
+// the JSON state of the changed item and are empty for created and removed items respectively.
+type AuditRecord struct {
+	ID        int             `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects audit records, zero fields match any record.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+}
+
+// FlaggedEvent is an event which looks fraudulent, it is kept for review instead of being counted.
+type FlaggedEvent struct {
+	ID           int            `json:"id"`
+	Type         EventType      `json:"type"`
+	ImpressionID string         `json:"impression_id,omitempty"`
+	SlotID       int            `json:"slot"`
+	BannerID     int            `json:"banner"`
+	GroupID      int            `json:"group"`
+	UserID       string         `json:"user_id,omitempty"`
+	IP           string         `json:"ip,omitempty"`
+	Reasons      []fraud.Reason `json:"reasons"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// FraudFilter selects flagged events, zero fields match any event.
+type FraudFilter struct {
+	SlotID int
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+}
+
+// FraudReport counts the flagged events by their reasons and holds the latest of them.
+type FraudReport struct {
+	Total   int64                  `json:"total"`
+	Reasons map[fraud.Reason]int64 `json:"reasons"`
+	Events  []FlaggedEvent         `json:"events"`
+}
+
+// PurgeResult holds the number of soft deleted rows removed permanently.
+type PurgeResult struct {
+	Banners int64 `json:"banners"`
+	Slots   int64 `json:"slots"`
+	Groups  int64 `json:"groups"`
 }
 
 type BannersRepository interface {
-	GetBanner(ctx context.Context, slotID, groupID int) (Banner, error)
+	// GetBanner chooses one of the eligible banners of the slot for the group with the bandit
+	// and on the statistics of the arm, banners rejected by any of the filters aren't considered.
+	GetBanner(ctx context.Context, slotID, groupID int, arm ArmRef, filters ...BannerFilter) (Banner, error)
+	GetBannerByID(ctx context.Context, bannerID int) (Banner, error)
 	AddSlot(ctx context.Context) (Slot, error)
-	AddBanner(ctx context.Context, bannerURL, description string) (Banner, error)
+	// AddBanner returns the existing banner with ErrAlreadyExists if one has the same url and description.
+	AddBanner(ctx context.Context, banner Banner) (Banner, error)
+	SetBannerStatus(ctx context.Context, bannerID int, status BannerStatus) (Banner, error)
+	// UpdateBanner changes the banner if its current version equals the given one, zero version skips the check.
+	UpdateBanner(ctx context.Context, bannerID int, update BannerUpdate, version int) (Banner, error)
+	// AddRelation returns ErrAlreadyExists if the banner is already in the slot.
 	AddRelation(ctx context.Context, slotID, bannerID int) error
 	RemoveBanner(ctx context.Context, bannerID int) error
 	RemoveSlot(ctx context.Context, slotID int) error
 	RemoveRelation(ctx context.Context, slotID, bannerID int) error
+	// SetRelationSchedule limits the time when the banner is shown in the slot, nil schedule removes the limit.
+	SetRelationSchedule(ctx context.Context, slotID, bannerID int, s *schedule.Schedule) error
+	// GetSlotBanners returns the banners of the slot with their settings in it.
+	GetSlotBanners(ctx context.Context, slotID int) ([]SlotBanner, error)
+	// SetRelationDelivery puts the banner into a delivery tier of the slot, nil settings make it standard.
+	SetRelationDelivery(ctx context.Context, slotID, bannerID int, settings *delivery.Settings) error
+	// GetDeliveryReport tracks the guaranteed banners of the slot, zero id tracks them in all slots.
+	GetDeliveryReport(ctx context.Context, slotID int) ([]GuaranteeReport, error)
 	Click(ctx context.Context, slotID, bannerID, groupID int) error
+	AddAdvertiser(ctx context.Context, name string) (Advertiser, error)
+	GetAdvertiserByID(ctx context.Context, advertiserID int) (Advertiser, error)
+	GetAllAdvertisers(ctx context.Context) ([]Advertiser, error)
+	// UpdateAdvertiser renames the advertiser if its current version equals the given one, zero version skips the check.
+	UpdateAdvertiser(ctx context.Context, advertiserID int, name string, version int) (Advertiser, error)
+	// RemoveAdvertiser marks the advertiser as removed, it must have no campaigns.
+	RemoveAdvertiser(ctx context.Context, advertiserID int) error
+	AddCampaign(ctx context.Context, campaign Campaign) (Campaign, error)
+	GetCampaignByID(ctx context.Context, campaignID int) (Campaign, error)
+	// GetCampaigns returns the campaigns of the advertiser, zero id returns all of them.
+	GetCampaigns(ctx context.Context, advertiserID int) ([]Campaign, error)
+	// UpdateCampaign changes the campaign if its current version equals the given one, zero version skips the check.
+	UpdateCampaign(ctx context.Context, campaignID int, update CampaignUpdate, version int) (Campaign, error)
+	SetCampaignStatus(ctx context.Context, campaignID int, status CampaignStatus) (Campaign, error)
+	// RemoveCampaign marks the campaign as removed, it must have no banners. The removed banners
+	// keep referring to it, so that their statistics stay in the campaign reports.
+	RemoveCampaign(ctx context.Context, campaignID int) error
+	// AddExperiment starts the experiment in its slot, which must not run another one.
+	AddExperiment(ctx context.Context, e Experiment) (Experiment, error)
+	GetExperimentByID(ctx context.Context, experimentID int) (Experiment, error)
+	// GetExperiments returns the experiments of the slot, zero id returns all of them.
+	GetExperiments(ctx context.Context, slotID int) ([]Experiment, error)
+	// GetRunningExperiment returns the experiment which splits the traffic of the slot.
+	GetRunningExperiment(ctx context.Context, slotID int) (Experiment, error)
+	StopExperiment(ctx context.Context, experimentID int) (Experiment, error)
+	// AddArmEvents counts the events in the statistics of their arms or of the holdout,
+	// events of unknown experiments are skipped.
+	AddArmEvents(ctx context.Context, events []ArmEvent) error
+	// GetExperimentCounters returns the statistics of the experiment arms per banner.
+	GetExperimentCounters(ctx context.Context, experimentID int) ([]experiment.Counter, error)
+	// GetHoldoutReport compares the bandits with the holdout in the slot, zero id returns all the slots.
+	GetHoldoutReport(ctx context.Context, slotID int) ([]HoldoutReport, error)
+	// GetRollup sums the relation counters of the banners up to campaigns or advertisers.
+	GetRollup(ctx context.Context, level RollupLevel) ([]Rollup, error)
+	// GetSlotBannerCounters sums the relation counters of every banner in every slot over the groups.
+	GetSlotBannerCounters(ctx context.Context) ([]alert.Counter, error)
+	// GetReport calls fn for every row of the report in the order of the dimensions and then of the time,
+	// the buckets without events are skipped.
+	GetReport(ctx context.Context, query ReportQuery, fn func(ReportRow) error) error
+	// GetSlotRelations returns the counters of the banners of the slot in every group.
+	GetSlotRelations(ctx context.Context, slotID int) ([]analytics.Relation, error)
+	// GetSlotRollouts returns the banners added to the slot since the relations are timestamped
+	// together with the time of their minEvents-th show.
+	GetSlotRollouts(ctx context.Context, slotID, minEvents int) ([]analytics.Rollout, error)
 	GetAllBanners(ctx context.Context) ([]Banner, error)
+	// AddGroup returns the existing group with ErrAlreadyExists if one has the same description.
 	AddGroup(ctx context.Context, description string) (Group, error)
 	RemoveGroup(ctx context.Context, groupID int) error
+	// UpdateGroup changes the group if its current version equals the given one, zero version skips the check.
+	UpdateGroup(ctx context.Context, groupID int, update GroupUpdate, version int) (Group, error)
+	GetGroupByID(ctx context.Context, groupID int) (Group, error)
 	GetAllGroups(ctx context.Context) ([]Group, error)
+	RestoreBanner(ctx context.Context, bannerID int) error
+	RestoreSlot(ctx context.Context, slotID int) error
+	RestoreGroup(ctx context.Context, groupID int) error
+	Purge(ctx context.Context, deletedBefore time.Time) (PurgeResult, error)
 	Show(ctx context.Context, slotID int, bannerID int, groupID int) error
+	// ShowImpression counts the show of a signed impression, every impression is counted once.
+	ShowImpression(ctx context.Context, impressionID string, slotID, bannerID, groupID int) error
+	// ClickImpression counts the click on a shown impression, every impression is clicked once.
+	ClickImpression(ctx context.Context, impressionID string, slotID, bannerID, groupID int) error
+	// PurgeImpressions removes the impressions shown before the time, their tokens must have expired by then.
+	PurgeImpressions(ctx context.Context, shownBefore time.Time) (int64, error)
+	// PurgeEvents removes the events created before the time, the reports lose them.
+	PurgeEvents(ctx context.Context, createdBefore time.Time) (int64, error)
+	// ApplyEvents counts the events in a single transaction and returns an error for every
+	// event, which is nil for the counted ones. The error result tells that nothing was counted.
+	ApplyEvents(ctx context.Context, events []Event) ([]error, error)
+	CreateSnapshot(ctx context.Context, name, comment string, scope StatisticScope) (Snapshot, error)
+	GetSnapshots(ctx context.Context) ([]Snapshot, error)
+	// ResetStatistic snapshots the counters of the scope, including the holdout and experiment ones,
+	// and sets them to zero in a single transaction.
+	ResetStatistic(ctx context.Context, name, comment string, scope StatisticScope) (Snapshot, error)
+	// RestoreSnapshot writes the snapshot counters back to the relations and the experiments which still exist.
+	RestoreSnapshot(ctx context.Context, snapshotID int) (Snapshot, error)
+	Export(ctx context.Context) (Configuration, error)
+	// Import applies the configuration in a single transaction, which is rolled back in the dry run mode.
+	Import(ctx context.Context, config Configuration, dryRun bool) (ImportReport, error)
+	AddAuditRecord(ctx context.Context, record AuditRecord) error
+	GetAuditRecords(ctx context.Context, filter AuditFilter) ([]AuditRecord, error)
+
+	AddFlaggedEvents(ctx context.Context, events []FlaggedEvent) error
+	// GetFraudReport counts all the flagged events matching the filter, the limit applies to the listed events only.
+	GetFraudReport(ctx context.Context, filter FraudFilter) (FraudReport, error)
 }
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+	"github.com/bubblesupreme/banner_rotation/internal/pacing"
+	"github.com/bubblesupreme/banner_rotation/internal/segment"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanChangeStatus(t *testing.T) {
+	assert.True(t, CanChangeStatus(BannerDraft, BannerActive))
+	assert.True(t, CanChangeStatus(BannerActive, BannerPaused))
+	assert.True(t, CanChangeStatus(BannerPaused, BannerActive))
+	assert.True(t, CanChangeStatus(BannerPaused, BannerArchived))
+
+	assert.False(t, CanChangeStatus(BannerActive, BannerActive))
+	assert.False(t, CanChangeStatus(BannerDraft, BannerPaused))
+	assert.False(t, CanChangeStatus(BannerArchived, BannerActive))
+	assert.False(t, CanChangeStatus(BannerArchived, BannerArchived))
+	assert.False(t, CanChangeStatus(BannerActive, BannerDraft))
+}
+
+func TestBannerEligible(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	assert.True(t, Banner{Status: BannerActive}.Eligible(now))
+	assert.True(t, Banner{Status: BannerActive, StartAt: &before, EndAt: &after}.Eligible(now))
+
+	assert.False(t, Banner{Status: BannerPaused}.Eligible(now))
+	assert.False(t, Banner{Status: BannerDraft}.Eligible(now))
+	assert.False(t, Banner{Status: BannerActive, StartAt: &after}.Eligible(now))
+	assert.False(t, Banner{Status: BannerActive, EndAt: &before}.Eligible(now))
+	assert.False(t, Banner{Status: BannerActive, EndAt: &now}.Eligible(now))
+}
+
+func TestBannerValidateFlight(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	assert.NoError(t, Banner{}.ValidateFlight())
+	assert.NoError(t, Banner{StartAt: &now}.ValidateFlight())
+	assert.NoError(t, Banner{StartAt: &now, EndAt: &later}.ValidateFlight())
+	assert.True(t, errors.Is(Banner{StartAt: &later, EndAt: &now}.ValidateFlight(), ErrInvalidFlightTime))
+	assert.True(t, errors.Is(Banner{StartAt: &now, EndAt: &now}.ValidateFlight(), ErrInvalidFlightTime))
+}
+
+func TestBannerSharesLabel(t *testing.T) {
+	labels := map[string]bool{"bank": true, "telecom": true}
+
+	assert.True(t, Banner{Labels: []string{"bank"}}.SharesLabel(labels))
+	assert.True(t, Banner{Labels: []string{"auto", "telecom"}}.SharesLabel(labels))
+
+	assert.False(t, Banner{}.SharesLabel(labels))
+	assert.False(t, Banner{Labels: []string{"auto"}}.SharesLabel(labels))
+	assert.False(t, Banner{Labels: []string{"bank"}}.SharesLabel(nil))
+}
+
+func TestFrequencyCap(t *testing.T) {
+	assert.NoError(t, Banner{}.ValidateCap())
+	assert.NoError(t, Banner{FrequencyCap: &FrequencyCap{Limit: 3, Period: capping.PeriodDay}}.ValidateCap())
+	assert.True(t, errors.Is(Banner{FrequencyCap: &FrequencyCap{Limit: 0, Period: capping.PeriodDay}}.ValidateCap(), ErrInvalidCap))
+	assert.True(t, errors.Is(Banner{FrequencyCap: &FrequencyCap{Limit: 3, Period: "week"}}.ValidateCap(), ErrInvalidCap))
+
+	capped := BannerUpdate{FrequencyCap: &FrequencyCap{Limit: 3, Period: capping.PeriodHour}}.Apply(Banner{})
+	assert.Equal(t, &FrequencyCap{Limit: 3, Period: capping.PeriodHour}, capped.FrequencyCap)
+	assert.Nil(t, BannerUpdate{FrequencyCap: &FrequencyCap{}}.Apply(capped).FrequencyCap)
+	assert.NotNil(t, BannerUpdate{}.Apply(capped).FrequencyCap)
+}
+
+func TestBannerUpdateFlight(t *testing.T) {
+	start := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	b := BannerUpdate{StartAt: &start, EndAt: &end}.Apply(Banner{})
+	assert.Equal(t, &start, b.StartAt)
+	assert.Equal(t, &end, b.EndAt)
+	assert.Equal(t, b, BannerUpdate{}.Apply(b))
+
+	b = BannerUpdate{StartAt: &time.Time{}}.Apply(b)
+	assert.Nil(t, b.StartAt)
+	assert.Equal(t, &end, b.EndAt)
+}
+
+func TestGroupUpdateApply(t *testing.T) {
+	adults := &segment.Segment{Conditions: []segment.Condition{{Attribute: "age", Op: segment.OpGte, Value: "18"}}}
+	fallback := true
+
+	g := GroupUpdate{Segment: adults, Fallback: &fallback}.Apply(Group{ID: 1, Description: "adults"})
+	assert.Equal(t, Group{ID: 1, Description: "adults", Segment: adults, Fallback: true}, g)
+	assert.NoError(t, g.ValidateSegment())
+
+	assert.Equal(t, g, GroupUpdate{}.Apply(g))
+	assert.Nil(t, GroupUpdate{Segment: &segment.Segment{}}.Apply(g).Segment)
+	assert.True(t, errors.Is(Group{Segment: &segment.Segment{}}.ValidateSegment(), segment.ErrInvalidSegment))
+}
+
+func TestSlotBannerEligible(t *testing.T) {
+	now := time.Now()
+	active := Banner{Status: BannerActive, CampaignID: 1}
+
+	assert.True(t, SlotBanner{Banner: active}.Eligible(now))
+	assert.False(t, SlotBanner{Banner: active, CampaignPaused: true}.Eligible(now))
+	assert.False(t, SlotBanner{Banner: Banner{Status: BannerPaused}}.Eligible(now))
+
+	detached := 0
+	assert.Equal(t, 0, BannerUpdate{CampaignID: &detached}.Apply(active).CampaignID)
+	assert.Equal(t, 1, BannerUpdate{}.Apply(active).CampaignID)
+
+	budget := pacing.Budget{Metric: pacing.MetricImpressions, Lifetime: 10}
+	assert.True(t, SlotBanner{Banner: active, Budgets: []BudgetState{
+		{Owner: BudgetCampaign, OwnerID: 1, Budget: budget, Usage: pacing.Usage{Lifetime: pacing.Counter{Impressions: 9}}},
+	}}.Eligible(now))
+	assert.False(t, SlotBanner{Banner: active, Budgets: []BudgetState{
+		{Owner: BudgetBanner, OwnerID: 2, Budget: budget},
+		{Owner: BudgetCampaign, OwnerID: 1, Budget: budget, Usage: pacing.Usage{Lifetime: pacing.Counter{Impressions: 10}}},
+	}}.Eligible(now))
+}
+
+func TestBudgetUpdate(t *testing.T) {
+	budget := &pacing.Budget{Metric: pacing.MetricImpressions, Daily: 100}
+
+	c := CampaignUpdate{Budget: budget}.Apply(Campaign{ID: 1, Name: "sale"})
+	assert.Equal(t, budget, c.Budget)
+	assert.NoError(t, c.ValidateBudget())
+	assert.Equal(t, c, CampaignUpdate{}.Apply(c))
+	assert.Nil(t, CampaignUpdate{Budget: &pacing.Budget{}}.Apply(c).Budget)
+
+	b := BannerUpdate{Budget: &pacing.Budget{Metric: pacing.MetricSpend, Daily: 100}}.Apply(Banner{})
+	assert.True(t, errors.Is(b.ValidateBudget(), pacing.ErrInvalidBudget))
+	assert.Nil(t, BannerUpdate{Budget: &pacing.Budget{}}.Apply(b).Budget)
+}
@@ -0,0 +1,26 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/bubblesupreme/banner_rotation/internal/alert"
+)
+
+func (r *sqlRepository) GetSlotBannerCounters(ctx context.Context) ([]alert.Counter, error) {
+	counters := make([]alert.Counter, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		c := alert.Counter{}
+		if err := rows.Scan(&c.SlotID, &c.BannerID, &c.Impressions, &c.Clicks); err != nil {
+			return err
+		}
+		counters = append(counters, c)
+		return nil
+	}, `SELECT r.slot_id, r.banner_id, SUM(r.impressions), SUM(r.clicks)
+FROM relations r
+    JOIN slots s ON s.id = r.slot_id AND s.deleted_at IS NULL
+    JOIN banners b ON b.id = r.banner_id AND b.deleted_at IS NULL
+GROUP BY r.slot_id, r.banner_id;`)
+
+	return counters, err
+}
@@ -0,0 +1,67 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/bubblesupreme/banner_rotation/internal/analytics"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+)
+
+func (r *sqlRepository) GetSlotRelations(ctx context.Context, slotID int) ([]analytics.Relation, error) {
+	slotExist, err := r.checkSlotExistence(ctx, slotID)
+	if err != nil {
+		return nil, err
+	}
+	if !slotExist {
+		return nil, fmt.Errorf("slot with id = %d: %w", slotID, repository.ErrNotFound)
+	}
+
+	relations := make([]analytics.Relation, 0)
+	err = queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		rel := analytics.Relation{}
+		if err := rows.Scan(&rel.BannerID, &rel.GroupID, &rel.Impressions, &rel.Clicks); err != nil {
+			return err
+		}
+		relations = append(relations, rel)
+		return nil
+	}, `SELECT r.banner_id, r.group_id, r.impressions, r.clicks
+FROM relations r
+    JOIN banners b ON b.id = r.banner_id AND b.deleted_at IS NULL
+    JOIN groups g ON g.id = r.group_id AND g.deleted_at IS NULL
+WHERE r.slot_id = $1
+ORDER BY r.banner_id, r.group_id;`, slotID)
+
+	return relations, err
+}
+
+func (r *sqlRepository) GetSlotRollouts(ctx context.Context, slotID, minEvents int) ([]analytics.Rollout, error) {
+	rollouts := make([]analytics.Rollout, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		rollout := analytics.Rollout{}
+		warmAt := sql.NullTime{}
+		if err := rows.Scan(&rollout.BannerID, &rollout.GroupID, &rollout.AddedAt, &warmAt); err != nil {
+			return err
+		}
+		if warmAt.Valid {
+			rollout.WarmAt = &warmAt.Time
+		}
+		rollouts = append(rollouts, rollout)
+		return nil
+	}, `SELECT r.banner_id, r.group_id, r.created_at, w.created_at
+FROM relations r
+    JOIN banners b ON b.id = r.banner_id AND b.deleted_at IS NULL
+    JOIN groups g ON g.id = r.group_id AND g.deleted_at IS NULL
+    LEFT JOIN LATERAL (
+        SELECT e.created_at FROM events e
+        WHERE e.slot_id = r.slot_id AND e.banner_id = r.banner_id AND e.group_id = r.group_id
+            AND e.type = 'show' AND e.created_at >= r.created_at
+        ORDER BY e.created_at, e.id
+        OFFSET GREATEST($2::INTEGER - 1, 0) LIMIT 1
+    ) w ON true
+WHERE r.slot_id = $1 AND r.created_at IS NOT NULL
+ORDER BY r.created_at, r.banner_id, r.group_id;`, slotID, minEvents)
+
+	return rollouts, err
+}
@@ -0,0 +1,75 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+)
+
+func (r *sqlRepository) AddAuditRecord(ctx context.Context, record repository.AuditRecord) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO audit_log (actor, action, before, after) VALUES ($1, $2, $3, $4);",
+		record.Actor, record.Action, nullJSON(record.Before), nullJSON(record.After))
+
+	return err
+}
+
+func (r *sqlRepository) GetAuditRecords(ctx context.Context, filter repository.AuditFilter) ([]repository.AuditRecord, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if filter.Actor != "" {
+		addCondition("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		addCondition("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < ?", *filter.To)
+	}
+
+	query := "SELECT id, actor, action, before, after, created_at FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query+";", args...) //nolint:rowserrcheck,sqlclosecheck
+	if err != nil {
+		return nil, err
+	}
+	defer checkRows(rows)
+
+	records := make([]repository.AuditRecord, 0)
+	for rows.Next() {
+		record := repository.AuditRecord{}
+		before := []byte(nil)
+		after := []byte(nil)
+		if err := rows.Scan(&record.ID, &record.Actor, &record.Action, &before, &after, &record.CreatedAt); err != nil {
+			return nil, err
+		}
+		record.Before = before
+		record.After = after
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// nullJSON stores empty JSON documents as NULL.
+func nullJSON(b []byte) sql.NullString {
+	return sql.NullString{String: string(b), Valid: len(b) > 0}
+}
@@ -0,0 +1,131 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/pacing"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type budgetKey struct {
+	owner   repository.BudgetOwner
+	ownerID int
+}
+
+// loadBudgetUsage sets the usage of the budgets of the banners for the day which the time falls in.
+func (r *sqlRepository) loadBudgetUsage(ctx context.Context, banners []repository.SlotBanner, at time.Time) error {
+	bannerIDs := make([]int64, 0)
+	campaignIDs := make([]int64, 0)
+	for _, sb := range banners {
+		for _, b := range sb.Budgets {
+			if b.Owner == repository.BudgetBanner {
+				bannerIDs = append(bannerIDs, int64(b.OwnerID))
+			} else {
+				campaignIDs = append(campaignIDs, int64(b.OwnerID))
+			}
+		}
+	}
+	if len(bannerIDs) == 0 && len(campaignIDs) == 0 {
+		return nil
+	}
+
+	usage := make(map[budgetKey]pacing.Usage)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		key := budgetKey{}
+		u := pacing.Usage{}
+		if err := rows.Scan(&key.owner, &key.ownerID, &u.Day.Impressions, &u.Day.Spend, &u.Lifetime.Impressions, &u.Lifetime.Spend); err != nil {
+			return err
+		}
+		usage[key] = u
+		return nil
+	}, `SELECT owner, owner_id, COALESCE(SUM(impressions) FILTER (WHERE day = $3::DATE), 0), COALESCE(SUM(spend) FILTER (WHERE day = $3::DATE), 0),
+    SUM(impressions), SUM(spend)
+FROM budget_spend
+WHERE (owner = 'banner' AND owner_id = ANY($1)) OR (owner = 'campaign' AND owner_id = ANY($2))
+GROUP BY owner, owner_id;`, pq.Array(bannerIDs), pq.Array(campaignIDs), budgetDay(at))
+	if err != nil {
+		return err
+	}
+
+	for i := range banners {
+		for j := range banners[i].Budgets {
+			b := &banners[i].Budgets[j]
+			b.Usage = usage[budgetKey{owner: b.Owner, ownerID: b.OwnerID}]
+		}
+	}
+
+	return nil
+}
+
+// addBudgetSpend adds the shows of the banners to the daily counters of their budgets and of the budgets
+// of their campaigns. Items get their counters when they get a budget, the cost of a show is
+// a thousandth of the budget cpm as in pacing.Budget.Cost.
+func addBudgetSpend(ctx context.Context, q execer, shows map[int]int64, at time.Time) error {
+	if len(shows) == 0 {
+		return nil
+	}
+
+	bannerIDs := make([]int64, 0, len(shows))
+	counts := make([]int64, 0, len(shows))
+	for bannerID, n := range shows {
+		bannerIDs = append(bannerIDs, int64(bannerID))
+		counts = append(counts, n)
+	}
+
+	_, err := q.ExecContext(ctx, `INSERT INTO budget_spend (owner, owner_id, day, impressions, spend)
+SELECT 'banner', b.id, $3::DATE, s.shows, s.shows * COALESCE((b.budget->>'cpm')::DOUBLE PRECISION, 0) / 1000
+FROM unnest($1::INTEGER[], $2::BIGINT[]) AS s (banner_id, shows)
+    JOIN banners b ON b.id = s.banner_id
+WHERE b.budget IS NOT NULL
+UNION ALL
+SELECT 'campaign', c.id, $3::DATE, SUM(s.shows), SUM(s.shows) * COALESCE((c.budget->>'cpm')::DOUBLE PRECISION, 0) / 1000
+FROM unnest($1::INTEGER[], $2::BIGINT[]) AS s (banner_id, shows)
+    JOIN banners b ON b.id = s.banner_id
+    JOIN campaigns c ON c.id = b.campaign_id
+WHERE c.budget IS NOT NULL
+GROUP BY c.id
+ON CONFLICT (owner, owner_id, day) DO UPDATE
+SET impressions = budget_spend.impressions + excluded.impressions, spend = budget_spend.spend + excluded.spend;`,
+		pq.Array(bannerIDs), pq.Array(counts), budgetDay(at))
+
+	return err
+}
+
+// budgetDay returns the day column value of the counters of the given time.
+func budgetDay(at time.Time) string {
+	return pacing.Day(at).Format("2006-01-02")
+}
+
+// budgetValue turns the budget into the budget column, which is NULL without a budget.
+func budgetValue(b *pacing.Budget) (sql.NullString, error) {
+	if b == nil {
+		return sql.NullString{}, nil
+	}
+
+	raw, err := json.Marshal(b)
+
+	return nullJSON(raw), err
+}
+
+// parseBudget reads the budget column, which is NULL for items without a budget.
+func parseBudget(raw []byte) (*pacing.Budget, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	b := &pacing.Budget{}
+	if err := json.Unmarshal(raw, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
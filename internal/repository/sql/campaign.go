@@ -0,0 +1,312 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const campaignColumns = "id, advertiser_id, name, status, version, budget"
+
+func (r *sqlRepository) AddAdvertiser(ctx context.Context, name string) (repository.Advertiser, error) {
+	advertiser := repository.Advertiser{Name: name}
+	err := r.db.QueryRowContext(ctx, "INSERT INTO advertisers (name) VALUES ($1) RETURNING id, version;", name).Scan(&advertiser.ID, &advertiser.Version)
+	if err != nil {
+		return advertiser, err
+	}
+
+	log.WithFields(log.Fields{
+		"id":   advertiser.ID,
+		"name": name,
+	}).Info("new advertiser was added")
+
+	return advertiser, nil
+}
+
+func (r *sqlRepository) GetAdvertiserByID(ctx context.Context, advertiserID int) (repository.Advertiser, error) {
+	a := repository.Advertiser{}
+	err := r.db.QueryRowContext(ctx, "SELECT id, name, version FROM advertisers WHERE id = $1 AND deleted_at IS NULL;", advertiserID).Scan(&a.ID, &a.Name, &a.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, fmt.Errorf("advertiser with id = %d: %w", advertiserID, repository.ErrNotFound)
+	}
+
+	return a, err
+}
+
+func (r *sqlRepository) GetAllAdvertisers(ctx context.Context) ([]repository.Advertiser, error) {
+	advertisers := make([]repository.Advertiser, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		a := repository.Advertiser{}
+		if err := rows.Scan(&a.ID, &a.Name, &a.Version); err != nil {
+			return err
+		}
+		advertisers = append(advertisers, a)
+		return nil
+	}, "SELECT id, name, version FROM advertisers WHERE deleted_at IS NULL ORDER BY id;")
+
+	return advertisers, err
+}
+
+func (r *sqlRepository) UpdateAdvertiser(ctx context.Context, advertiserID int, name string, version int) (repository.Advertiser, error) {
+	current, err := r.GetAdvertiserByID(ctx, advertiserID)
+	if err != nil {
+		return current, err
+	}
+	if version != 0 && version != current.Version {
+		return current, fmt.Errorf("advertiser with id = %d has version %d: %w", advertiserID, current.Version, repository.ErrVersionConflict)
+	}
+
+	advertiser := current
+	advertiser.Name = name
+	err = r.db.QueryRowContext(ctx, "UPDATE advertisers SET name = $1, version = version + 1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL RETURNING version;",
+		name, advertiserID, current.Version).Scan(&advertiser.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return current, fmt.Errorf("advertiser with id = %d was changed concurrently: %w", advertiserID, repository.ErrVersionConflict)
+	}
+	if err != nil {
+		return current, err
+	}
+
+	log.WithFields(log.Fields{
+		"advertiser id": advertiserID,
+		"name":          name,
+		"version":       advertiser.Version,
+	}).Info("advertiser was updated")
+
+	return advertiser, nil
+}
+
+func (r *sqlRepository) RemoveAdvertiser(ctx context.Context, advertiserID int) error {
+	campaigns := 0
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(id) FROM campaigns WHERE advertiser_id = $1 AND deleted_at IS NULL;", advertiserID).Scan(&campaigns); err != nil {
+		return err
+	}
+	if campaigns > 0 {
+		return fmt.Errorf("advertiser with id = %d has %d campaigns: %w", advertiserID, campaigns, repository.ErrInUse)
+	}
+
+	return r.remove(ctx, "advertisers", "advertiser", advertiserID)
+}
+
+func (r *sqlRepository) AddCampaign(ctx context.Context, campaign repository.Campaign) (repository.Campaign, error) {
+	if campaign.Status == "" {
+		campaign.Status = repository.CampaignActive
+	}
+	if _, err := r.GetAdvertiserByID(ctx, campaign.AdvertiserID); err != nil {
+		return campaign, err
+	}
+	budget, err := budgetValue(campaign.Budget)
+	if err != nil {
+		return campaign, err
+	}
+
+	err = r.db.QueryRowContext(ctx, "INSERT INTO campaigns (advertiser_id, name, status, budget) VALUES ($1, $2, $3, $4) RETURNING id, version;",
+		campaign.AdvertiserID, campaign.Name, campaign.Status, budget).Scan(&campaign.ID, &campaign.Version)
+	if err != nil {
+		return campaign, err
+	}
+
+	log.WithFields(log.Fields{
+		"id":            campaign.ID,
+		"advertiser id": campaign.AdvertiserID,
+		"name":          campaign.Name,
+	}).Info("new campaign was added")
+
+	return campaign, nil
+}
+
+func (r *sqlRepository) GetCampaignByID(ctx context.Context, campaignID int) (repository.Campaign, error) {
+	c, err := scanCampaign(r.db.QueryRowContext(ctx, "SELECT "+campaignColumns+" FROM campaigns WHERE id = $1 AND deleted_at IS NULL;", campaignID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, fmt.Errorf("campaign with id = %d: %w", campaignID, repository.ErrNotFound)
+	}
+
+	return c, err
+}
+
+func (r *sqlRepository) GetCampaigns(ctx context.Context, advertiserID int) ([]repository.Campaign, error) {
+	campaigns := make([]repository.Campaign, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return err
+		}
+		campaigns = append(campaigns, c)
+		return nil
+	}, "SELECT "+campaignColumns+" FROM campaigns WHERE ($1 = 0 OR advertiser_id = $1) AND deleted_at IS NULL ORDER BY id;", advertiserID)
+
+	return campaigns, err
+}
+
+func (r *sqlRepository) UpdateCampaign(ctx context.Context, campaignID int, update repository.CampaignUpdate, version int) (repository.Campaign, error) {
+	current, err := r.GetCampaignByID(ctx, campaignID)
+	if err != nil {
+		return current, err
+	}
+	if version != 0 && version != current.Version {
+		return current, fmt.Errorf("campaign with id = %d has version %d: %w", campaignID, current.Version, repository.ErrVersionConflict)
+	}
+
+	campaign := update.Apply(current)
+	if err := campaign.ValidateBudget(); err != nil {
+		return current, err
+	}
+	budget, err := budgetValue(campaign.Budget)
+	if err != nil {
+		return current, err
+	}
+
+	err = r.db.QueryRowContext(ctx, "UPDATE campaigns SET name = $1, budget = $2, version = version + 1 WHERE id = $3 AND version = $4 AND deleted_at IS NULL RETURNING version;",
+		campaign.Name, budget, campaignID, current.Version).Scan(&campaign.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return current, fmt.Errorf("campaign with id = %d was changed concurrently: %w", campaignID, repository.ErrVersionConflict)
+	}
+	if err != nil {
+		return current, err
+	}
+
+	log.WithFields(log.Fields{
+		"campaign id": campaignID,
+		"name":        campaign.Name,
+		"version":     campaign.Version,
+	}).Info("campaign was updated")
+
+	return campaign, nil
+}
+
+func (r *sqlRepository) SetCampaignStatus(ctx context.Context, campaignID int, status repository.CampaignStatus) (repository.Campaign, error) {
+	campaign, err := r.GetCampaignByID(ctx, campaignID)
+	if err != nil {
+		return campaign, err
+	}
+
+	logEntry := log.WithFields(log.Fields{
+		"campaign id": campaignID,
+		"from":        campaign.Status,
+		"to":          status,
+	})
+	if campaign.Status == status {
+		logEntry.Warning("campaign status can't be changed")
+		return campaign, fmt.Errorf("campaign with id = %d is %s: %w", campaignID, campaign.Status, repository.ErrStatusTransition)
+	}
+
+	// the status is compared again so that a concurrent change isn't overwritten
+	err = r.db.QueryRowContext(ctx, "UPDATE campaigns SET status = $1, version = version + 1 WHERE id = $2 AND status = $3 AND deleted_at IS NULL RETURNING version;",
+		status, campaignID, campaign.Status).Scan(&campaign.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		logEntry.Warning("campaign status was changed concurrently")
+		return campaign, fmt.Errorf("campaign with id = %d was changed concurrently: %w", campaignID, repository.ErrStatusTransition)
+	}
+	if err != nil {
+		return campaign, err
+	}
+
+	logEntry.Info("campaign status was changed")
+	campaign.Status = status
+
+	return campaign, nil
+}
+
+func (r *sqlRepository) RemoveCampaign(ctx context.Context, campaignID int) error {
+	banners := 0
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(id) FROM banners WHERE campaign_id = $1 AND deleted_at IS NULL;", campaignID).Scan(&banners); err != nil {
+		return err
+	}
+	if banners > 0 {
+		return fmt.Errorf("campaign with id = %d has %d banners: %w", campaignID, banners, repository.ErrInUse)
+	}
+
+	// the campaign is only marked as removed, so the removed banners keep it in the reports
+	return r.remove(ctx, "campaigns", "campaign", campaignID)
+}
+
+func (r *sqlRepository) GetRollup(ctx context.Context, level repository.RollupLevel) ([]repository.Rollup, error) {
+	query := `SELECT c.id, c.name, c.advertiser_id, COALESCE(SUM(r.impressions), 0), COALESCE(SUM(r.clicks), 0)
+FROM campaigns c
+    LEFT JOIN banners b ON b.campaign_id = c.id AND b.deleted_at IS NULL
+    LEFT JOIN relations r ON r.banner_id = b.id
+WHERE c.deleted_at IS NULL
+GROUP BY c.id
+ORDER BY c.id;`
+	if level == repository.RollupAdvertiser {
+		query = `SELECT a.id, a.name, 0, COALESCE(SUM(r.impressions), 0), COALESCE(SUM(r.clicks), 0)
+FROM advertisers a
+    LEFT JOIN campaigns c ON c.advertiser_id = a.id AND c.deleted_at IS NULL
+    LEFT JOIN banners b ON b.campaign_id = c.id AND b.deleted_at IS NULL
+    LEFT JOIN relations r ON r.banner_id = b.id
+WHERE a.deleted_at IS NULL
+GROUP BY a.id
+ORDER BY a.id;`
+	}
+
+	rollups := make([]repository.Rollup, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		rollup := repository.Rollup{}
+		if err := rows.Scan(&rollup.ID, &rollup.Name, &rollup.AdvertiserID, &rollup.Impressions, &rollup.Clicks); err != nil {
+			return err
+		}
+		rollups = append(rollups, rollup)
+		return nil
+	}, query)
+
+	return rollups, err
+}
+
+// remove marks the row as deleted the same as the banners, slots and groups are,
+// the table name is never taken from the user input.
+func (r *sqlRepository) remove(ctx context.Context, table, entity string, id int) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE "+table+" SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL;", id) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("%s with id = %d: %w", entity, id, repository.ErrNotFound)
+	}
+
+	log.WithFields(log.Fields{
+		entity + " id": id,
+	}).Info(entity + " was removed")
+
+	return nil
+}
+
+// checkCampaign makes sure that a banner can be put into the campaign, zero id means no campaign.
+func (r *sqlRepository) checkCampaign(ctx context.Context, campaignID int) error {
+	if campaignID == 0 {
+		return nil
+	}
+
+	_, err := r.GetCampaignByID(ctx, campaignID)
+
+	return err
+}
+
+// campaignValue turns the campaign id into the campaign_id column, which is NULL for banners without a campaign.
+func campaignValue(campaignID int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(campaignID), Valid: campaignID != 0}
+}
+
+func scanCampaign(row scanner) (repository.Campaign, error) {
+	c := repository.Campaign{}
+	budget := []byte(nil)
+	if err := row.Scan(&c.ID, &c.AdvertiserID, &c.Name, &c.Status, &c.Version, &budget); err != nil {
+		return c, err
+	}
+
+	var err error
+	if c.Budget, err = parseBudget(budget); err != nil {
+		return c, fmt.Errorf("invalid budget of campaign with id = %d: %w", c.ID, err)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,144 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/bubblesupreme/banner_rotation/internal/delivery"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (r *sqlRepository) SetRelationDelivery(ctx context.Context, slotID, bannerID int, settings *delivery.Settings) error {
+	relationExist, err := r.checkRelationExistence(ctx, slotID, bannerID)
+	if err != nil {
+		return err
+	}
+	if !relationExist {
+		return fmt.Errorf("relation with slot id = %d and banner id = %d: %w", slotID, bannerID, repository.ErrNotFound)
+	}
+
+	logEntry := log.WithFields(log.Fields{
+		"slot id":   slotID,
+		"banner id": bannerID,
+	})
+
+	if settings == nil {
+		if _, err := r.db.ExecContext(ctx, "DELETE FROM relation_delivery WHERE slot_id = $1 AND banner_id = $2;", slotID, bannerID); err != nil {
+			return err
+		}
+		logEntry.Info("relation delivery was reset")
+
+		return nil
+	}
+
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	// the guarantees of the slot are locked, so that concurrent changes can't sell more than the whole slot
+	if _, err := tx.ExecContext(ctx, "SELECT 1 FROM slots WHERE id = $1 FOR UPDATE;", slotID); err != nil {
+		return err
+	}
+	if settings.Tier == delivery.TierGuaranteed {
+		sold := 0.0
+		err := tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(share), 0) FROM relation_delivery WHERE slot_id = $1 AND banner_id <> $2 AND tier = $3;",
+			slotID, bannerID, delivery.TierGuaranteed).Scan(&sold)
+		if err != nil {
+			return err
+		}
+		if sold+settings.Share > 1 {
+			return fmt.Errorf("%.2f of slot with id = %d is already guaranteed: %w", sold, slotID, delivery.ErrInvalidDelivery)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO relation_delivery (slot_id, banner_id, tier, share) VALUES ($1, $2, $3, $4)
+ON CONFLICT (slot_id, banner_id) DO UPDATE SET tier = excluded.tier, share = excluded.share;`, slotID, bannerID, settings.Tier, settings.Share); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logEntry.WithFields(log.Fields{
+		"tier":  settings.Tier,
+		"share": settings.Share,
+	}).Info("relation delivery was set")
+
+	return nil
+}
+
+func (r *sqlRepository) GetDeliveryReport(ctx context.Context, slotID int) ([]repository.GuaranteeReport, error) {
+	reports := make([]repository.GuaranteeReport, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		report := repository.GuaranteeReport{}
+		share := 0.0
+		impressions, total := int64(0), int64(0)
+		if err := rows.Scan(&report.SlotID, &report.BannerID, &share, &impressions, &total); err != nil {
+			return err
+		}
+		report.Progress = delivery.Track(share, impressions, total)
+		reports = append(reports, report)
+		return nil
+	}, `WITH counts AS (
+    SELECT slot_id, banner_id, SUM(impressions) AS impressions, SUM(SUM(impressions)) OVER (PARTITION BY slot_id) AS total
+    FROM relations
+    WHERE $1 = 0 OR slot_id = $1
+    GROUP BY slot_id, banner_id
+)
+SELECT rd.slot_id, rd.banner_id, rd.share, COALESCE(c.impressions, 0), COALESCE(c.total, 0)
+FROM relation_delivery rd
+    JOIN counts c ON c.slot_id = rd.slot_id AND c.banner_id = rd.banner_id
+WHERE rd.tier = $2
+ORDER BY rd.slot_id, rd.banner_id;`, slotID, delivery.TierGuaranteed)
+
+	return reports, err
+}
+
+// selectTier returns the guaranteed banner to serve or the banners left to the bandit,
+// the slot impressions are counted only when there are guaranteed banners.
+func (r *sqlRepository) selectTier(ctx context.Context, slotID int, banners []repository.SlotBanner) (int, []int, error) {
+	candidates := make([]delivery.Candidate, 0, len(banners))
+	hasGuarantees := false
+	for _, sb := range banners {
+		c := delivery.Candidate{BannerID: sb.ID}
+		if sb.Delivery != nil {
+			c.Settings = *sb.Delivery
+			hasGuarantees = hasGuarantees || sb.Delivery.Tier == delivery.TierGuaranteed
+		}
+		candidates = append(candidates, c)
+	}
+
+	total := int64(0)
+	if hasGuarantees {
+		impressions := make(map[int]int64)
+		err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+			bannerID, n := 0, int64(0)
+			if err := rows.Scan(&bannerID, &n); err != nil {
+				return err
+			}
+			impressions[bannerID] = n
+			total += n
+			return nil
+		}, "SELECT banner_id, SUM(impressions) FROM relations WHERE slot_id = $1 GROUP BY banner_id;", slotID)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		for i := range candidates {
+			candidates[i].Impressions = impressions[candidates[i].BannerID]
+		}
+	}
+
+	guaranteed, pool := delivery.Select(candidates, total)
+
+	return guaranteed, pool, nil
+}
@@ -0,0 +1,292 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+type relationKey struct {
+	slotID   int
+	bannerID int
+	groupID  int
+}
+
+func eventRelation(e repository.Event) relationKey {
+	return relationKey{slotID: e.SlotID, bannerID: e.BannerID, groupID: e.GroupID}
+}
+
+func (r *sqlRepository) ApplyEvents(ctx context.Context, events []repository.Event) ([]error, error) {
+	errs := make([]error, len(events))
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	live, err := liveRelations(ctx, tx, events)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range events {
+		switch {
+		case e.Type != repository.EventShow && e.Type != repository.EventClick:
+			errs[i] = fmt.Errorf("unknown event type %q", e.Type)
+		case !live[eventRelation(e)]:
+			errs[i] = fmt.Errorf("relation with slot id = %d, banner id = %d and group id = %d: %w",
+				e.SlotID, e.BannerID, e.GroupID, repository.ErrNotFound)
+		}
+	}
+
+	// the shows go first, so that an impression can be shown and clicked in the same batch
+	if err := showImpressions(ctx, tx, events, errs); err != nil {
+		return nil, err
+	}
+	if err := clickImpressions(ctx, tx, events, errs); err != nil {
+		return nil, err
+	}
+	if err := addEventCounters(ctx, tx, events, errs); err != nil {
+		return nil, err
+	}
+	counted := make([]repository.Event, 0, len(events))
+	for i, e := range events {
+		if errs[i] == nil {
+			counted = append(counted, e)
+		}
+	}
+	if err := storeEvents(ctx, tx, counted); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"events":  len(events),
+		"counted": len(counted),
+	}).Info("events were applied")
+
+	return errs, nil
+}
+
+// liveRelations returns the relations of the events which aren't deleted.
+func liveRelations(ctx context.Context, tx *sql.Tx, events []repository.Event) (map[relationKey]bool, error) {
+	slotIDs := make([]int64, 0, len(events))
+	bannerIDs := make([]int64, 0, len(events))
+	groupIDs := make([]int64, 0, len(events))
+	for _, e := range events {
+		slotIDs = append(slotIDs, int64(e.SlotID))
+		bannerIDs = append(bannerIDs, int64(e.BannerID))
+		groupIDs = append(groupIDs, int64(e.GroupID))
+	}
+
+	live := make(map[relationKey]bool)
+	err := queryEach(ctx, tx, func(rows *sql.Rows) error {
+		key := relationKey{}
+		err := rows.Scan(&key.slotID, &key.bannerID, &key.groupID)
+		live[key] = true
+		return err
+	}, `SELECT DISTINCT r.slot_id, r.banner_id, r.group_id FROM relations r
+    JOIN unnest($1::INTEGER[], $2::INTEGER[], $3::INTEGER[]) AS e (slot_id, banner_id, group_id)
+        ON r.slot_id = e.slot_id AND r.banner_id = e.banner_id AND r.group_id = e.group_id
+    JOIN banners b ON b.id = r.banner_id AND b.deleted_at IS NULL
+    JOIN slots s ON s.id = r.slot_id AND s.deleted_at IS NULL
+    JOIN groups g ON g.id = r.group_id AND g.deleted_at IS NULL;`, pq.Array(slotIDs), pq.Array(bannerIDs), pq.Array(groupIDs))
+
+	return live, err
+}
+
+// showImpressions stores the shown impressions, an impression shown before fails with ErrAlreadyExists.
+func showImpressions(ctx context.Context, tx *sql.Tx, events []repository.Event, errs []error) error {
+	ids := make([]string, 0)
+	slotIDs := make([]int64, 0)
+	bannerIDs := make([]int64, 0)
+	groupIDs := make([]int64, 0)
+	pending := make(map[string]int)
+	for i, e := range events {
+		if errs[i] != nil || e.Type != repository.EventShow || e.ImpressionID == "" {
+			continue
+		}
+		if _, ok := pending[e.ImpressionID]; ok {
+			errs[i] = fmt.Errorf("impression %s was already shown: %w", e.ImpressionID, repository.ErrAlreadyExists)
+			continue
+		}
+		pending[e.ImpressionID] = i
+		ids = append(ids, e.ImpressionID)
+		slotIDs = append(slotIDs, int64(e.SlotID))
+		bannerIDs = append(bannerIDs, int64(e.BannerID))
+		groupIDs = append(groupIDs, int64(e.GroupID))
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := queryEach(ctx, tx, func(rows *sql.Rows) error {
+		id := ""
+		err := rows.Scan(&id)
+		delete(pending, id)
+		return err
+	}, `INSERT INTO impressions (id, slot_id, banner_id, group_id)
+SELECT * FROM unnest($1::TEXT[], $2::INTEGER[], $3::INTEGER[], $4::INTEGER[])
+ON CONFLICT (id) DO NOTHING RETURNING id;`, pq.Array(ids), pq.Array(slotIDs), pq.Array(bannerIDs), pq.Array(groupIDs)); err != nil {
+		return err
+	}
+
+	// the impressions which weren't inserted have been shown before
+	for id, i := range pending {
+		errs[i] = fmt.Errorf("impression %s was already shown: %w", id, repository.ErrAlreadyExists)
+	}
+
+	return nil
+}
+
+// clickImpressions marks the impressions as clicked, an impression which wasn't shown
+// fails with ErrNotShown and an impression clicked before fails with ErrAlreadyExists.
+func clickImpressions(ctx context.Context, tx *sql.Tx, events []repository.Event, errs []error) error {
+	ids := make([]string, 0)
+	for i, e := range events {
+		if errs[i] == nil && e.Type == repository.EventClick && e.ImpressionID != "" {
+			ids = append(ids, e.ImpressionID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	clicked := make(map[string]bool)
+	if err := queryEach(ctx, tx, func(rows *sql.Rows) error {
+		id, isClicked := "", false
+		err := rows.Scan(&id, &isClicked)
+		clicked[id] = isClicked
+		return err
+	}, "SELECT id, clicked_at IS NOT NULL FROM impressions WHERE id = ANY($1) FOR UPDATE;", pq.Array(ids)); err != nil {
+		return err
+	}
+
+	ids = ids[:0]
+	for i, e := range events {
+		if errs[i] != nil || e.Type != repository.EventClick || e.ImpressionID == "" {
+			continue
+		}
+
+		isClicked, shown := clicked[e.ImpressionID]
+		switch {
+		case !shown:
+			errs[i] = fmt.Errorf("impression %s: %w", e.ImpressionID, repository.ErrNotShown)
+		case isClicked:
+			errs[i] = fmt.Errorf("impression %s was already clicked: %w", e.ImpressionID, repository.ErrAlreadyExists)
+		default:
+			clicked[e.ImpressionID] = true
+			ids = append(ids, e.ImpressionID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, "UPDATE impressions SET clicked_at = now() WHERE id = ANY($1);", pq.Array(ids))
+
+	return err
+}
+
+// addEventCounters adds the events which haven't failed to the relation counters with a single update.
+func addEventCounters(ctx context.Context, tx *sql.Tx, events []repository.Event, errs []error) error {
+	type counters struct {
+		shows  int64
+		clicks int64
+	}
+	byRelation := make(map[relationKey]*counters)
+	bannerShows := make(map[int]int64)
+	for i, e := range events {
+		if errs[i] != nil {
+			continue
+		}
+		c, ok := byRelation[eventRelation(e)]
+		if !ok {
+			c = &counters{}
+			byRelation[eventRelation(e)] = c
+		}
+		if e.Type == repository.EventShow {
+			c.shows++
+			bannerShows[e.BannerID]++
+		} else {
+			c.clicks++
+		}
+	}
+	if len(byRelation) == 0 {
+		return nil
+	}
+
+	slotIDs := make([]int64, 0, len(byRelation))
+	bannerIDs := make([]int64, 0, len(byRelation))
+	groupIDs := make([]int64, 0, len(byRelation))
+	shows := make([]int64, 0, len(byRelation))
+	clicks := make([]int64, 0, len(byRelation))
+	for key, c := range byRelation {
+		slotIDs = append(slotIDs, int64(key.slotID))
+		bannerIDs = append(bannerIDs, int64(key.bannerID))
+		groupIDs = append(groupIDs, int64(key.groupID))
+		shows = append(shows, c.shows)
+		clicks = append(clicks, c.clicks)
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE relations r SET impressions = r.impressions + e.shows, clicks = r.clicks + e.clicks
+FROM unnest($1::INTEGER[], $2::INTEGER[], $3::INTEGER[], $4::INTEGER[], $5::INTEGER[]) AS e (slot_id, banner_id, group_id, shows, clicks)
+WHERE r.slot_id = e.slot_id AND r.banner_id = e.banner_id AND r.group_id = e.group_id;`,
+		pq.Array(slotIDs), pq.Array(bannerIDs), pq.Array(groupIDs), pq.Array(shows), pq.Array(clicks))
+	if err != nil {
+		return err
+	}
+
+	return addBudgetSpend(ctx, tx, bannerShows, time.Now())
+}
+
+// storeEvents keeps the counted events with their time for the reports.
+func storeEvents(ctx context.Context, q execer, events []repository.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	types := make([]string, 0, len(events))
+	slotIDs := make([]int64, 0, len(events))
+	bannerIDs := make([]int64, 0, len(events))
+	groupIDs := make([]int64, 0, len(events))
+	for _, e := range events {
+		types = append(types, string(e.Type))
+		slotIDs = append(slotIDs, int64(e.SlotID))
+		bannerIDs = append(bannerIDs, int64(e.BannerID))
+		groupIDs = append(groupIDs, int64(e.GroupID))
+	}
+
+	_, err := q.ExecContext(ctx, `INSERT INTO events (type, slot_id, banner_id, group_id)
+SELECT * FROM unnest($1::TEXT[], $2::INTEGER[], $3::INTEGER[], $4::INTEGER[]);`,
+		pq.Array(types), pq.Array(slotIDs), pq.Array(bannerIDs), pq.Array(groupIDs))
+
+	return err
+}
+
+func (r *sqlRepository) PurgeEvents(ctx context.Context, createdBefore time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM events WHERE created_at < $1;", createdBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	log.WithFields(log.Fields{
+		"created before": createdBefore,
+		"events":         n,
+	}).Info("old events were purged")
+
+	return n, nil
+}
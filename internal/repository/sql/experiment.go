@@ -0,0 +1,286 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bubblesupreme/banner_rotation/internal/experiment"
+	bandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+const experimentColumns = "id, slot_id, name, arms, status, created_at, stopped_at"
+
+func (r *sqlRepository) AddExperiment(ctx context.Context, e repository.Experiment) (repository.Experiment, error) {
+	if err := experiment.Validate(e.Arms); err != nil {
+		return e, err
+	}
+	slotExist, err := r.checkSlotExistence(ctx, e.SlotID)
+	if err != nil {
+		return e, err
+	}
+	if !slotExist {
+		return e, fmt.Errorf("slot with id = %d: %w", e.SlotID, repository.ErrNotFound)
+	}
+
+	arms, err := json.Marshal(e.Arms)
+	if err != nil {
+		return e, err
+	}
+	res, err := scanExperiment(r.db.QueryRowContext(ctx, "INSERT INTO experiments (slot_id, name, arms) VALUES ($1, $2, $3) RETURNING "+experimentColumns+";",
+		e.SlotID, e.Name, string(arms)))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return e, fmt.Errorf("experiment in slot with id = %d: %w", e.SlotID, repository.ErrAlreadyExists)
+	}
+	if err != nil {
+		return e, err
+	}
+
+	log.WithFields(log.Fields{
+		"experiment id": res.ID,
+		"slot id":       res.SlotID,
+		"name":          res.Name,
+	}).Info("experiment was started")
+
+	return res, nil
+}
+
+func (r *sqlRepository) GetExperimentByID(ctx context.Context, experimentID int) (repository.Experiment, error) {
+	e, err := scanExperiment(r.db.QueryRowContext(ctx, "SELECT "+experimentColumns+" FROM experiments WHERE id = $1;", experimentID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, fmt.Errorf("experiment with id = %d: %w", experimentID, repository.ErrNotFound)
+	}
+
+	return e, err
+}
+
+func (r *sqlRepository) GetExperiments(ctx context.Context, slotID int) ([]repository.Experiment, error) {
+	experiments := make([]repository.Experiment, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return err
+		}
+		experiments = append(experiments, e)
+		return nil
+	}, "SELECT "+experimentColumns+" FROM experiments WHERE $1 = 0 OR slot_id = $1 ORDER BY id;", slotID)
+
+	return experiments, err
+}
+
+func (r *sqlRepository) GetRunningExperiment(ctx context.Context, slotID int) (repository.Experiment, error) {
+	e, err := scanExperiment(r.db.QueryRowContext(ctx, "SELECT "+experimentColumns+" FROM experiments WHERE slot_id = $1 AND status = $2;",
+		slotID, repository.ExperimentRunning))
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, fmt.Errorf("running experiment in slot with id = %d: %w", slotID, repository.ErrNotFound)
+	}
+
+	return e, err
+}
+
+func (r *sqlRepository) StopExperiment(ctx context.Context, experimentID int) (repository.Experiment, error) {
+	current, err := r.GetExperimentByID(ctx, experimentID)
+	if err != nil {
+		return current, err
+	}
+
+	e, err := scanExperiment(r.db.QueryRowContext(ctx, "UPDATE experiments SET status = $1, stopped_at = now() WHERE id = $2 AND status = $3 RETURNING "+experimentColumns+";",
+		repository.ExperimentStopped, experimentID, repository.ExperimentRunning))
+	if errors.Is(err, sql.ErrNoRows) {
+		return current, fmt.Errorf("experiment with id = %d is already stopped: %w", experimentID, repository.ErrStatusTransition)
+	}
+	if err != nil {
+		return current, err
+	}
+
+	log.WithFields(log.Fields{
+		"experiment id": experimentID,
+		"slot id":       e.SlotID,
+	}).Info("experiment was stopped")
+
+	return e, nil
+}
+
+type armKey struct {
+	repository.ArmRef
+	slotID   int
+	bannerID int
+	groupID  int
+}
+
+type armCounter struct {
+	shows  int64
+	clicks int64
+}
+
+func (r *sqlRepository) AddArmEvents(ctx context.Context, events []repository.ArmEvent) error {
+	byArm := make(map[armKey]*armCounter)
+	byHoldout := make(map[armKey]*armCounter)
+	for _, e := range events {
+		if e.Empty() {
+			continue
+		}
+		key := armKey{ArmRef: e.ArmRef, bannerID: e.BannerID, groupID: e.GroupID}
+		counters := byArm
+		if e.Holdout {
+			key = armKey{slotID: e.SlotID, bannerID: e.BannerID, groupID: e.GroupID}
+			counters = byHoldout
+		}
+		c, ok := counters[key]
+		if !ok {
+			c = &armCounter{}
+			counters[key] = c
+		}
+		if e.Type == repository.EventClick {
+			c.clicks++
+		} else {
+			c.shows++
+		}
+	}
+
+	if err := r.addHoldoutCounters(ctx, byHoldout); err != nil {
+		return err
+	}
+	if len(byArm) == 0 {
+		return nil
+	}
+
+	experimentIDs := make([]int64, 0, len(byArm))
+	arms := make([]string, 0, len(byArm))
+	bannerIDs := make([]int64, 0, len(byArm))
+	groupIDs := make([]int64, 0, len(byArm))
+	shows := make([]int64, 0, len(byArm))
+	clicks := make([]int64, 0, len(byArm))
+	for key, c := range byArm {
+		experimentIDs = append(experimentIDs, int64(key.ExperimentID))
+		arms = append(arms, key.Arm)
+		bannerIDs = append(bannerIDs, int64(key.bannerID))
+		groupIDs = append(groupIDs, int64(key.groupID))
+		shows = append(shows, c.shows)
+		clicks = append(clicks, c.clicks)
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO experiment_counters (experiment_id, arm, banner_id, group_id, impressions, clicks)
+SELECT e.experiment_id, e.arm, e.banner_id, e.group_id, e.shows, e.clicks
+FROM unnest($1::INTEGER[], $2::TEXT[], $3::INTEGER[], $4::INTEGER[], $5::BIGINT[], $6::BIGINT[])
+        AS e (experiment_id, arm, banner_id, group_id, shows, clicks)
+    JOIN experiments x ON x.id = e.experiment_id
+ON CONFLICT (experiment_id, arm, banner_id, group_id) DO UPDATE
+SET impressions = experiment_counters.impressions + excluded.impressions, clicks = experiment_counters.clicks + excluded.clicks;`,
+		pq.Array(experimentIDs), pq.Array(arms), pq.Array(bannerIDs), pq.Array(groupIDs), pq.Array(shows), pq.Array(clicks))
+
+	return err
+}
+
+func (r *sqlRepository) GetExperimentCounters(ctx context.Context, experimentID int) ([]experiment.Counter, error) {
+	counters := make([]experiment.Counter, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		c := experiment.Counter{}
+		if err := rows.Scan(&c.Arm, &c.BannerID, &c.Impressions, &c.Clicks); err != nil {
+			return err
+		}
+		counters = append(counters, c)
+		return nil
+	}, `SELECT arm, banner_id, SUM(impressions), SUM(clicks) FROM experiment_counters
+WHERE experiment_id = $1 GROUP BY arm, banner_id ORDER BY arm, banner_id;`, experimentID)
+
+	return counters, err
+}
+
+func (r *sqlRepository) addHoldoutCounters(ctx context.Context, byHoldout map[armKey]*armCounter) error {
+	if len(byHoldout) == 0 {
+		return nil
+	}
+
+	slotIDs := make([]int64, 0, len(byHoldout))
+	bannerIDs := make([]int64, 0, len(byHoldout))
+	groupIDs := make([]int64, 0, len(byHoldout))
+	shows := make([]int64, 0, len(byHoldout))
+	clicks := make([]int64, 0, len(byHoldout))
+	for key, c := range byHoldout {
+		slotIDs = append(slotIDs, int64(key.slotID))
+		bannerIDs = append(bannerIDs, int64(key.bannerID))
+		groupIDs = append(groupIDs, int64(key.groupID))
+		shows = append(shows, c.shows)
+		clicks = append(clicks, c.clicks)
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO holdout_counters (slot_id, banner_id, group_id, impressions, clicks)
+SELECT * FROM unnest($1::INTEGER[], $2::INTEGER[], $3::INTEGER[], $4::BIGINT[], $5::BIGINT[])
+ON CONFLICT (slot_id, banner_id, group_id) DO UPDATE
+SET impressions = holdout_counters.impressions + excluded.impressions, clicks = holdout_counters.clicks + excluded.clicks;`,
+		pq.Array(slotIDs), pq.Array(bannerIDs), pq.Array(groupIDs), pq.Array(shows), pq.Array(clicks))
+
+	return err
+}
+
+// GetHoldoutReport takes the bandit part of the slot statistics as the whole statistics of its relations
+// without the holdout, so the holdout statistics are reset together with the statistics of the relations.
+func (r *sqlRepository) GetHoldoutReport(ctx context.Context, slotID int) ([]repository.HoldoutReport, error) {
+	reports := make([]repository.HoldoutReport, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		report := repository.HoldoutReport{}
+		impressions, clicks, holdoutImpressions, holdoutClicks := int64(0), int64(0), int64(0), int64(0)
+		if err := rows.Scan(&report.SlotID, &impressions, &clicks, &holdoutImpressions, &holdoutClicks); err != nil {
+			return err
+		}
+		report.Bandit = experiment.NewPerformance(impressions, clicks)
+		report.Holdout = experiment.NewPerformance(holdoutImpressions, holdoutClicks)
+		report.Lift = experiment.Lift(report.Bandit, report.Holdout)
+		reports = append(reports, report)
+		return nil
+	}, `SELECT s.id,
+    GREATEST(COALESCE(r.impressions, 0) - COALESCE(h.impressions, 0), 0),
+    GREATEST(COALESCE(r.clicks, 0) - COALESCE(h.clicks, 0), 0),
+    COALESCE(h.impressions, 0), COALESCE(h.clicks, 0)
+FROM slots s
+    LEFT JOIN (SELECT slot_id, SUM(impressions) AS impressions, SUM(clicks) AS clicks FROM relations GROUP BY slot_id) r ON r.slot_id = s.id
+    LEFT JOIN (SELECT slot_id, SUM(impressions) AS impressions, SUM(clicks) AS clicks FROM holdout_counters GROUP BY slot_id) h ON h.slot_id = s.id
+WHERE s.deleted_at IS NULL AND ($1 = 0 OR s.id = $1)
+ORDER BY s.id;`, slotID)
+
+	return reports, err
+}
+
+// armBandit makes the bandit of the arm from its config in the experiment, the holdout is served by the uniform bandit.
+func (r *sqlRepository) armBandit(ctx context.Context, ref repository.ArmRef) (bandit.MultiarmedBandit, error) {
+	if ref.Holdout {
+		return r.newBandit(bandit.Config{Algorithm: bandit.AlgorithmUniform})
+	}
+
+	e, err := r.GetExperimentByID(ctx, ref.ExperimentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range e.Arms {
+		if a.Name == ref.Arm {
+			return r.newBandit(a.Bandit)
+		}
+	}
+
+	return nil, fmt.Errorf("arm %q of experiment with id = %d: %w", ref.Arm, ref.ExperimentID, repository.ErrNotFound)
+}
+
+func scanExperiment(row scanner) (repository.Experiment, error) {
+	e := repository.Experiment{}
+	rawArms := []byte(nil)
+	stoppedAt := sql.NullTime{}
+	if err := row.Scan(&e.ID, &e.SlotID, &e.Name, &rawArms, &e.Status, &e.CreatedAt, &stoppedAt); err != nil {
+		return e, err
+	}
+	if stoppedAt.Valid {
+		e.StoppedAt = &stoppedAt.Time
+	}
+	if err := json.Unmarshal(rawArms, &e.Arms); err != nil {
+		return e, fmt.Errorf("invalid arms of experiment with id = %d: %w", e.ID, err)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,104 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/bubblesupreme/banner_rotation/internal/fraud"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+func (r *sqlRepository) AddFlaggedEvents(ctx context.Context, events []repository.FlaggedEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	for _, e := range events {
+		reasons := make([]string, 0, len(e.Reasons))
+		for _, reason := range e.Reasons {
+			reasons = append(reasons, string(reason))
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO flagged_events (type, impression_id, slot_id, banner_id, group_id, user_id, ip, reasons)
+VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8);`,
+			e.Type, e.ImpressionID, e.SlotID, e.BannerID, e.GroupID, e.UserID, e.IP, pq.Array(reasons)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqlRepository) GetFraudReport(ctx context.Context, filter repository.FraudFilter) (repository.FraudReport, error) {
+	report := repository.FraudReport{
+		Reasons: make(map[fraud.Reason]int64),
+		Events:  make([]repository.FlaggedEvent, 0),
+	}
+
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+	if filter.SlotID != 0 {
+		addCondition("slot_id = ?", filter.SlotID)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < ?", *filter.To)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM flagged_events"+where+";", args...).Scan(&report.Total); err != nil {
+		return report, err
+	}
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		reason := ""
+		n := int64(0)
+		if err := rows.Scan(&reason, &n); err != nil {
+			return err
+		}
+		report.Reasons[fraud.Reason(reason)] = n
+		return nil
+	}, "SELECT reason, COUNT(*) FROM flagged_events, unnest(reasons) AS reason"+where+" GROUP BY reason;", args...)
+	if err != nil {
+		return report, err
+	}
+
+	query := "SELECT id, type, COALESCE(impression_id, ''), slot_id, banner_id, group_id, COALESCE(user_id, ''), COALESCE(ip, ''), reasons, created_at\nFROM flagged_events" +
+		where + " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	err = queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		e := repository.FlaggedEvent{}
+		reasons := make([]string, 0)
+		if err := rows.Scan(&e.ID, &e.Type, &e.ImpressionID, &e.SlotID, &e.BannerID, &e.GroupID, &e.UserID, &e.IP,
+			pq.Array(&reasons), &e.CreatedAt); err != nil {
+			return err
+		}
+		for _, reason := range reasons {
+			e.Reasons = append(e.Reasons, fraud.Reason(reason))
+		}
+		report.Events = append(report.Events, e)
+		return nil
+	}, query+";", args...)
+
+	return report, err
+}
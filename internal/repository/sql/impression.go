@@ -0,0 +1,143 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (r *sqlRepository) ShowImpression(ctx context.Context, impressionID string, slotID, bannerID, groupID int) error {
+	if err := r.checkFullRelationExistence(ctx, slotID, bannerID, groupID); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO impressions (id, slot_id, banner_id, group_id) VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO NOTHING;`, impressionID, slotID, bannerID, groupID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("impression %s was already shown: %w", impressionID, repository.ErrAlreadyExists)
+	}
+
+	if err := incrementCounter(ctx, tx, "impressions", slotID, bannerID, groupID); err != nil {
+		return err
+	}
+	if err := storeEvents(ctx, tx, []repository.Event{{Type: repository.EventShow, SlotID: slotID, BannerID: bannerID, GroupID: groupID}}); err != nil {
+		return err
+	}
+	if err := addBudgetSpend(ctx, tx, map[int]int64{bannerID: 1}, time.Now()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	impressionLogEntry(impressionID, slotID, bannerID, groupID).Info("impression was shown")
+
+	return nil
+}
+
+func (r *sqlRepository) ClickImpression(ctx context.Context, impressionID string, slotID, bannerID, groupID int) error {
+	if err := r.checkFullRelationExistence(ctx, slotID, bannerID, groupID); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	clicked := false
+	err = tx.QueryRowContext(ctx, "SELECT clicked_at IS NOT NULL FROM impressions WHERE id = $1 FOR UPDATE;", impressionID).Scan(&clicked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("impression %s: %w", impressionID, repository.ErrNotShown)
+	}
+	if err != nil {
+		return err
+	}
+	if clicked {
+		return fmt.Errorf("impression %s was already clicked: %w", impressionID, repository.ErrAlreadyExists)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE impressions SET clicked_at = now() WHERE id = $1;", impressionID); err != nil {
+		return err
+	}
+	if err := incrementCounter(ctx, tx, "clicks", slotID, bannerID, groupID); err != nil {
+		return err
+	}
+	if err := storeEvents(ctx, tx, []repository.Event{{Type: repository.EventClick, SlotID: slotID, BannerID: bannerID, GroupID: groupID}}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	impressionLogEntry(impressionID, slotID, bannerID, groupID).Info("impression was clicked")
+
+	return nil
+}
+
+func (r *sqlRepository) PurgeImpressions(ctx context.Context, shownBefore time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM impressions WHERE shown_at < $1;", shownBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	log.WithFields(log.Fields{
+		"shown before": shownBefore,
+		"impressions":  n,
+	}).Info("expired impressions were purged")
+
+	return n, nil
+}
+
+// incrementCounter adds one to the impressions or clicks counter of the relation,
+// the column name is never taken from the user input.
+func incrementCounter(ctx context.Context, tx *sql.Tx, column string, slotID, bannerID, groupID int) error {
+	result, err := tx.ExecContext(ctx, "UPDATE relations SET "+column+" = "+column+" + 1 WHERE slot_id = $1 AND banner_id = $2 AND group_id = $3;", //nolint:gosec
+		slotID, bannerID, groupID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("relation with slot id = %d, banner id = %d and group id = %d: %w", slotID, bannerID, groupID, repository.ErrNotFound)
+	}
+
+	return nil
+}
+
+func impressionLogEntry(impressionID string, slotID, bannerID, groupID int) *log.Entry {
+	return log.WithFields(log.Fields{
+		"impression id": impressionID,
+		"slot id":       slotID,
+		"banner id":     bannerID,
+		"group id":      groupID,
+	})
+}
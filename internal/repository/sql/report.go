@@ -0,0 +1,67 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+)
+
+// reportColumns are the columns of the dimensions, they are never taken from the user input.
+var reportColumns = map[repository.Dimension]string{
+	repository.DimensionSlot:     "e.slot_id",
+	repository.DimensionBanner:   "e.banner_id",
+	repository.DimensionGroup:    "e.group_id",
+	repository.DimensionCampaign: "COALESCE(b.campaign_id, 0)",
+}
+
+func (r *sqlRepository) GetReport(ctx context.Context, query repository.ReportQuery, fn func(repository.ReportRow) error) error {
+	columns := make([]string, 0, len(query.GroupBy))
+	for _, d := range query.GroupBy {
+		columns = append(columns, reportColumns[d])
+	}
+	dimensions := ""
+	if len(columns) > 0 {
+		dimensions = strings.Join(columns, ", ") + ", "
+	}
+
+	// the buckets are truncated in UTC so that the days and the weeks don't depend on the database time zone
+	q := "SELECT " + dimensions + `date_trunc($3, e.created_at AT TIME ZONE 'UTC') AS bucket,
+    COUNT(*) FILTER (WHERE e.type = 'show'), COUNT(*) FILTER (WHERE e.type = 'click')
+FROM events e
+    LEFT JOIN banners b ON b.id = e.banner_id
+WHERE e.created_at >= $1 AND e.created_at < $2
+GROUP BY ` + dimensions + `bucket
+ORDER BY ` + dimensions + "bucket;"
+
+	return queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		row := repository.ReportRow{}
+		dest := make([]interface{}, 0, len(query.GroupBy)+3)
+		for _, d := range query.GroupBy {
+			dest = append(dest, reportDestination(&row, d))
+		}
+		if err := rows.Scan(append(dest, &row.Time, &row.Impressions, &row.Clicks)...); err != nil {
+			return err
+		}
+		row.Time = row.Time.UTC()
+		if row.Impressions > 0 {
+			row.CTR = float64(row.Clicks) / float64(row.Impressions)
+		}
+
+		return fn(row)
+	}, q, query.From, query.To, string(query.Granularity))
+}
+
+func reportDestination(row *repository.ReportRow, d repository.Dimension) *int {
+	switch d {
+	case repository.DimensionSlot:
+		return &row.SlotID
+	case repository.DimensionBanner:
+		return &row.BannerID
+	case repository.DimensionGroup:
+		return &row.GroupID
+	default:
+		return &row.CampaignID
+	}
+}
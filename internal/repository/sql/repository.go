@@ -3,18 +3,39 @@ package sqlrepository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
 	bandit "github.com/bubblesupreme/banner_rotation/internal/multiarmed_bandit"
 	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/segment"
 
+	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	bannerColumns = "id, url, description, status, start_at, end_at, version, labels, cap_limit, cap_period, campaign_id, budget"
+	groupColumns  = "id, description, version, segment, fallback"
+
+	// slotBannerColumns select a banner of a slot with its settings in the slot and its campaign,
+	// which are joined to the relations r with slotBannerJoins.
+	slotBannerColumns = `b.id, b.url, b.description, b.status, b.start_at, b.end_at, b.version, b.labels, b.cap_limit, b.cap_period, b.campaign_id, b.budget,
+    rs.schedule, c.status, c.budget, rd.tier, rd.share`
+	slotBannerJoins = `
+    JOIN banners b ON b.id = r.banner_id AND b.deleted_at IS NULL
+    LEFT JOIN campaigns c ON c.id = b.campaign_id
+    LEFT JOIN relation_schedules rs ON rs.slot_id = r.slot_id AND rs.banner_id = r.banner_id
+    LEFT JOIN relation_delivery rd ON rd.slot_id = r.slot_id AND rd.banner_id = r.banner_id`
+)
+
 type sqlRepository struct {
-	db     *sql.DB
-	bandit bandit.MultiarmedBandit
+	db        *sql.DB
+	bandit    bandit.MultiarmedBandit
+	newBandit bandit.Factory
 }
 
 type relation struct {
@@ -22,50 +43,113 @@ type relation struct {
 	bannerID int
 }
 
-func NewSQLRepository(db *sql.DB, bandit bandit.MultiarmedBandit) repository.BannersRepository {
+// NewSQLRepository creates the repository which chooses banners with the bandit,
+// the bandits of experiment arms are made from their configs with newBandit.
+func NewSQLRepository(db *sql.DB, bandit bandit.MultiarmedBandit, newBandit bandit.Factory) repository.BannersRepository {
 	return &sqlRepository{
-		db:     db,
-		bandit: bandit,
+		db:        db,
+		bandit:    bandit,
+		newBandit: newBandit,
 	}
 }
 
-func (r *sqlRepository) GetBanner(ctx context.Context, slotID, groupID int) (repository.Banner, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT banner_id, impressions, clicks FROM relations WHERE slot_id = $1 AND group_id = $2;", slotID, groupID) //nolint:rowserrcheck,sqlclosecheck
+func (r *sqlRepository) GetBanner(ctx context.Context, slotID, groupID int, arm repository.ArmRef, filters ...repository.BannerFilter) (repository.Banner, error) {
+	logEntry := log.WithFields(log.Fields{
+		"slot id":  slotID,
+		"group id": groupID,
+	})
+	chooser := r.bandit
+	if !arm.Empty() {
+		logEntry = logEntry.WithFields(log.Fields{
+			"experiment id": arm.ExperimentID,
+			"arm":           arm.Arm,
+			"holdout":       arm.Holdout,
+		})
+		var err error
+		if chooser, err = r.armBandit(ctx, arm); err != nil {
+			return repository.Banner{}, err
+		}
+	}
+
+	// arms of experiments choose on their own statistics
+	rows, err := r.db.QueryContext(ctx, "SELECT "+slotBannerColumns+`,
+    CASE WHEN $3 = 0 THEN r.impressions ELSE COALESCE(ec.impressions, 0) END,
+    CASE WHEN $3 = 0 THEN r.clicks ELSE COALESCE(ec.clicks, 0) END
+FROM relations r`+slotBannerJoins+`
+    JOIN slots s ON s.id = r.slot_id AND s.deleted_at IS NULL
+    JOIN groups g ON g.id = r.group_id AND g.deleted_at IS NULL
+    LEFT JOIN experiment_counters ec ON ec.experiment_id = $3 AND ec.arm = $4 AND ec.banner_id = r.banner_id AND ec.group_id = r.group_id
+WHERE r.slot_id = $1 AND r.group_id = $2;`, slotID, groupID, arm.ExperimentID, arm.Arm) //nolint:rowserrcheck,sqlclosecheck
 	if err != nil {
 		return repository.Banner{}, err
 	}
 	defer checkRows(rows)
 
-	s := bandit.BannersStatistic{}
-	b := bandit.BannerStatistic{}
+	now := time.Now()
+	candidates := make([]repository.SlotBanner, 0)
+	statistic := make([]bandit.BannerStatistic, 0)
 	for rows.Next() {
-		if err := rows.Scan(&b.BannerID, &b.Impressions, &b.Clicks); err != nil {
+		b := bandit.BannerStatistic{}
+		sb, err := scanSlotBanner(rows, &b.Impressions, &b.Clicks)
+		if err != nil {
 			return repository.Banner{}, err
 		}
-		s = append(s, b)
+		b.BannerID = sb.ID
+		candidates = append(candidates, sb)
+		statistic = append(statistic, b)
+	}
+	if err := r.loadBudgetUsage(ctx, candidates, now); err != nil {
+		return repository.Banner{}, err
 	}
 
-	if len(s) == 0 {
-		log.WithFields(log.Fields{
-			"slot id":  slotID,
-			"group id": groupID,
-		}).Error("row with given parameters not found")
-		return repository.Banner{}, fmt.Errorf("banner relations with given parameters not found")
+	nRelations := len(candidates)
+	banners := make(map[int]repository.Banner)
+	statistics := make(map[int]bandit.BannerStatistic)
+	eligible := make([]repository.SlotBanner, 0, len(candidates))
+	for i, sb := range candidates {
+		if !sb.Eligible(now) || !acceptBanner(sb.Banner, filters) {
+			continue
+		}
+		banners[sb.ID] = sb.Banner
+		statistics[sb.ID] = statistic[i]
+		eligible = append(eligible, sb)
 	}
 
-	banner, err := r.bandit.GetBanner(s)
-	if err != nil {
+	if nRelations == 0 {
+		logEntry.Error("row with given parameters not found")
+		return repository.Banner{}, fmt.Errorf("banner relations with given parameters: %w", repository.ErrNotFound)
+	}
+	if len(eligible) == 0 {
+		logEntry.Warning("all banners with given parameters are inactive, out of their flight dates or schedules, in paused campaigns, out of their budgets or filtered out")
+		return repository.Banner{}, fmt.Errorf("%w with given parameters", repository.ErrNoEligibleBanner)
+	}
+
+	// the holdout measures the bandits against random serving, so the guarantees don't apply to it
+	guaranteed, pool := 0, make([]int, 0, len(eligible))
+	if arm.Holdout {
+		for _, sb := range eligible {
+			pool = append(pool, sb.ID)
+		}
+	} else if guaranteed, pool, err = r.selectTier(ctx, slotID, eligible); err != nil {
 		return repository.Banner{}, err
 	}
+	if guaranteed != 0 {
+		res := banners[guaranteed]
+		logEntry.WithField("banner id", res.ID).Info("guaranteed banner is behind its share")
+		return res, nil
+	}
 
-	res, err := r.getBannerByID(ctx, banner.BannerID)
+	s := make(bandit.BannersStatistic, 0, len(pool))
+	for _, bannerID := range pool {
+		s = append(s, statistics[bannerID])
+	}
+	banner, err := chooser.GetBanner(s)
 	if err != nil {
-		return res, err
+		return repository.Banner{}, err
 	}
 
-	log.WithFields(log.Fields{
-		"slot id":            slotID,
-		"group id":           groupID,
+	res := banners[banner.BannerID]
+	logEntry.WithFields(log.Fields{
 		"banner id":          res.ID,
 		"banner url":         res.URL,
 		"banner description": res.Description,
@@ -73,6 +157,16 @@ func (r *sqlRepository) GetBanner(ctx context.Context, slotID, groupID int) (rep
 	return res, nil
 }
 
+func acceptBanner(banner repository.Banner, filters []repository.BannerFilter) bool {
+	for _, accept := range filters {
+		if !accept(banner) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (r *sqlRepository) AddSlot(ctx context.Context) (repository.Slot, error) {
 	slot := repository.Slot{}
 	err := r.db.QueryRowContext(ctx, "INSERT INTO slots DEFAULT VALUES RETURNING id;").Scan(&slot.ID)
@@ -87,40 +181,141 @@ func (r *sqlRepository) AddSlot(ctx context.Context) (repository.Slot, error) {
 	return slot, nil
 }
 
-func (r *sqlRepository) AddBanner(ctx context.Context, url string, description string) (repository.Banner, error) {
-	banner := repository.Banner{}
-	err := r.db.QueryRowContext(ctx, "SELECT id, url, description FROM banners WHERE url = $1 AND description = $2;", url, description).Scan(&banner.ID, &banner.URL, &banner.Description)
+func (r *sqlRepository) AddBanner(ctx context.Context, banner repository.Banner) (repository.Banner, error) {
+	if banner.Status == "" {
+		banner.Status = repository.BannerActive
+	}
+
+	existing, err := scanBanner(r.db.QueryRowContext(ctx, "SELECT "+bannerColumns+" FROM banners WHERE url = $1 AND description = $2 AND deleted_at IS NULL;", banner.URL, banner.Description))
 	if errors.Is(err, sql.ErrNoRows) {
-		banner.URL = url
-		banner.Description = description
+		if err := r.checkCampaign(ctx, banner.CampaignID); err != nil {
+			return banner, err
+		}
+		budget, err := budgetValue(banner.Budget)
+		if err != nil {
+			return banner, err
+		}
 
-		err := r.db.QueryRowContext(ctx, "INSERT INTO banners (url, description) VALUES ($1, $2) RETURNING id;", url, description).Scan(&banner.ID)
+		capLimit, capPeriod := capValues(banner.FrequencyCap)
+		err = r.db.QueryRowContext(ctx, `INSERT INTO banners (url, description, status, start_at, end_at, labels, cap_limit, cap_period, campaign_id, budget)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, version;`,
+			banner.URL, banner.Description, banner.Status, banner.StartAt, banner.EndAt, pq.Array(labels(banner.Labels)), capLimit, capPeriod,
+			campaignValue(banner.CampaignID), budget).Scan(&banner.ID, &banner.Version)
 		if err != nil {
 			return banner, err
 		}
 
 		log.WithFields(log.Fields{
-			"url":         url,
-			"description": description,
+			"url":         banner.URL,
+			"description": banner.Description,
+			"status":      banner.Status,
 		}).Info("new banner was added")
 
 		return banner, nil
 	}
+	if err != nil {
+		return existing, err
+	}
+
+	log.WithFields(log.Fields{
+		"id":          existing.ID,
+		"url":         existing.URL,
+		"description": existing.Description,
+	}).Warning("banner exists")
+
+	return existing, fmt.Errorf("banner with id = %d: %w", existing.ID, repository.ErrAlreadyExists)
+}
+
+func (r *sqlRepository) SetBannerStatus(ctx context.Context, bannerID int, status repository.BannerStatus) (repository.Banner, error) {
+	banner, err := r.GetBannerByID(ctx, bannerID)
+	if err != nil {
+		return banner, err
+	}
+
+	logEntry := log.WithFields(log.Fields{
+		"banner id": bannerID,
+		"from":      banner.Status,
+		"to":        status,
+	})
+	if !repository.CanChangeStatus(banner.Status, status) {
+		logEntry.Warning("banner status can't be changed")
+		return banner, fmt.Errorf("banner with id = %d is %s: %w", bannerID, banner.Status, repository.ErrStatusTransition)
+	}
+
+	// the status is compared again so that a concurrent change isn't overwritten
+	err = r.db.QueryRowContext(ctx, "UPDATE banners SET status = $1, version = version + 1 WHERE id = $2 AND status = $3 RETURNING version;",
+		status, bannerID, banner.Status).Scan(&banner.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		logEntry.Warning("banner status was changed concurrently")
+		return banner, fmt.Errorf("banner with id = %d was changed concurrently: %w", bannerID, repository.ErrStatusTransition)
+	}
 	if err != nil {
 		return banner, err
 	}
 
+	logEntry.Info("banner status was changed")
+	banner.Status = status
+
+	return banner, nil
+}
+
+func (r *sqlRepository) UpdateBanner(ctx context.Context, bannerID int, update repository.BannerUpdate, version int) (repository.Banner, error) {
+	current, err := r.GetBannerByID(ctx, bannerID)
+	if err != nil {
+		return current, err
+	}
+	if version != 0 && version != current.Version {
+		return current, fmt.Errorf("banner with id = %d has version %d: %w", bannerID, current.Version, repository.ErrVersionConflict)
+	}
+
+	banner := update.Apply(current)
+	if err := banner.ValidateFlight(); err != nil {
+		return current, err
+	}
+
+	if err := banner.ValidateCap(); err != nil {
+		return current, err
+	}
+
+	if err := banner.ValidateBudget(); err != nil {
+		return current, err
+	}
+
+	if banner.CampaignID != current.CampaignID {
+		if err := r.checkCampaign(ctx, banner.CampaignID); err != nil {
+			return current, err
+		}
+	}
+	budget, err := budgetValue(banner.Budget)
+	if err != nil {
+		return current, err
+	}
+
+	capLimit, capPeriod := capValues(banner.FrequencyCap)
+	err = r.db.QueryRowContext(ctx, `UPDATE banners SET url = $1, description = $2, start_at = $3, end_at = $4, labels = $5,
+    cap_limit = $6, cap_period = $7, campaign_id = $8, budget = $9, version = version + 1
+WHERE id = $10 AND version = $11 AND deleted_at IS NULL RETURNING version;`,
+		banner.URL, banner.Description, banner.StartAt, banner.EndAt, pq.Array(labels(banner.Labels)), capLimit, capPeriod,
+		campaignValue(banner.CampaignID), budget, bannerID, current.Version).Scan(&banner.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return current, fmt.Errorf("banner with id = %d was changed concurrently: %w", bannerID, repository.ErrVersionConflict)
+	}
+	if err != nil {
+		return current, err
+	}
+
 	log.WithFields(log.Fields{
-		"id":          banner.ID,
+		"banner id":   bannerID,
 		"url":         banner.URL,
 		"description": banner.Description,
-	}).Warning("banner exists")
+		"version":     banner.Version,
+	}).Info("banner was updated")
 
 	return banner, nil
 }
 
 func (r *sqlRepository) RemoveBanner(ctx context.Context, bannerID int) error {
-	result, resErr := r.db.ExecContext(ctx, "DELETE FROM banners WHERE id = $1;", bannerID)
+	result, resErr := r.db.ExecContext(ctx, "UPDATE banners SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL;", bannerID)
 	if resErr == nil {
 		logEntry := log.WithFields(log.Fields{
 			"banner id": bannerID,
@@ -131,7 +326,7 @@ func (r *sqlRepository) RemoveBanner(ctx context.Context, bannerID int) error {
 		case err != nil:
 			log.Error("failed to check affected row while removing banner: ", err.Error())
 		case rows == 0:
-			logEntry.Warning("no banner to delete with the same id")
+			logEntry.Warning("no banner to delete with the same id or it is already deleted")
 		case rows != 1:
 			logEntry.Errorf("expected to affect 1 row, but affected %d while removing banner", rows)
 		default:
@@ -143,7 +338,7 @@ func (r *sqlRepository) RemoveBanner(ctx context.Context, bannerID int) error {
 }
 
 func (r *sqlRepository) RemoveSlot(ctx context.Context, slotID int) error {
-	result, resErr := r.db.ExecContext(ctx, "DELETE FROM slots WHERE id = $1;", slotID)
+	result, resErr := r.db.ExecContext(ctx, "UPDATE slots SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL;", slotID)
 	if resErr == nil {
 		logEntry := log.WithFields(log.Fields{
 			"slot id": slotID,
@@ -154,7 +349,7 @@ func (r *sqlRepository) RemoveSlot(ctx context.Context, slotID int) error {
 		case err != nil:
 			log.Error("failed to check affected row while removing slot: ", err.Error())
 		case rows == 0:
-			logEntry.Warning("no slot to delete with the same id")
+			logEntry.Warning("no slot to delete with the same id or it is already deleted")
 		case rows != 1:
 			logEntry.Errorf("expected to affect 1 row, but affected %d while removing slot", rows)
 		default:
@@ -181,7 +376,7 @@ func (r *sqlRepository) AddRelation(ctx context.Context, slotID int, bannerID in
 	})
 	if relationExist {
 		logEntry.Warning("relation exists")
-		return nil
+		return fmt.Errorf("relation with slot id = %d and banner id = %d: %w", slotID, bannerID, repository.ErrAlreadyExists)
 	}
 
 	groups, err := r.GetAllGroups(ctx)
@@ -210,33 +405,49 @@ func (r *sqlRepository) AddRelation(ctx context.Context, slotID int, bannerID in
 }
 
 func (r *sqlRepository) RemoveRelation(ctx context.Context, slotID int, bannerID int) error {
-	result, resErr := r.db.ExecContext(ctx, "DELETE FROM relations WHERE slot_id = $1 AND banner_id = $2;", slotID, bannerID)
-	if resErr == nil {
-		logEntry := log.WithFields(log.Fields{
-			"slot id":   slotID,
-			"banner id": bannerID,
-		})
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
 
-		rows, err := result.RowsAffected()
-		switch {
-		case err != nil:
-			log.Error("failed to check affected row while removing relation: ", err.Error())
-		case rows == 0:
-			logEntry.Warning("relation not found")
-		default:
-			logEntry.Info("relation was removed")
-		}
+	result, err := tx.ExecContext(ctx, "DELETE FROM relations WHERE slot_id = $1 AND banner_id = $2;", slotID, bannerID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM relation_schedules WHERE slot_id = $1 AND banner_id = $2;", slotID, bannerID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM relation_delivery WHERE slot_id = $1 AND banner_id = $2;", slotID, bannerID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 
-	return resErr
+	logEntry := log.WithFields(log.Fields{
+		"slot id":   slotID,
+		"banner id": bannerID,
+	})
+
+	rows, err := result.RowsAffected()
+	switch {
+	case err != nil:
+		log.Error("failed to check affected row while removing relation: ", err.Error())
+	case rows == 0:
+		logEntry.Warning("relation not found")
+	default:
+		logEntry.Info("relation was removed")
+	}
+
+	return nil
 }
 
-func (r *sqlRepository) getBannerByID(ctx context.Context, bannerID int) (repository.Banner, error) {
-	res := repository.Banner{}
-	res.ID = bannerID
-	err := r.db.QueryRowContext(ctx, "SELECT url, description FROM banners WHERE id = $1;", bannerID).Scan(&res.URL, &res.Description)
+func (r *sqlRepository) GetBannerByID(ctx context.Context, bannerID int) (repository.Banner, error) {
+	res, err := scanBanner(r.db.QueryRowContext(ctx, "SELECT "+bannerColumns+" FROM banners WHERE id = $1 AND deleted_at IS NULL;", bannerID))
 	if errors.Is(err, sql.ErrNoRows) {
 		log.Errorf("no banner with id %d", bannerID)
+		return res, fmt.Errorf("banner with id = %d: %w", bannerID, repository.ErrNotFound)
 	}
 
 	return res, err
@@ -244,7 +455,7 @@ func (r *sqlRepository) getBannerByID(ctx context.Context, bannerID int) (reposi
 
 func (r *sqlRepository) checkSlotExistence(ctx context.Context, slotID int) (bool, error) {
 	count := 0
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(id) FROM slots WHERE id = $1;", slotID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(id) FROM slots WHERE id = $1 AND deleted_at IS NULL;", slotID).Scan(&count)
 	if errors.Is(err, sql.ErrNoRows) {
 		return false, nil
 	}
@@ -259,7 +470,7 @@ func (r *sqlRepository) checkSlotExistence(ctx context.Context, slotID int) (boo
 
 func (r *sqlRepository) checkBannerExistenceByID(ctx context.Context, bannerID int) (bool, error) {
 	count := 0
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(id) FROM banners WHERE id = $1;", bannerID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(id) FROM banners WHERE id = $1 AND deleted_at IS NULL;", bannerID).Scan(&count)
 	if errors.Is(err, sql.ErrNoRows) {
 		return false, nil
 	}
@@ -274,7 +485,7 @@ func (r *sqlRepository) checkBannerExistenceByID(ctx context.Context, bannerID i
 
 func (r *sqlRepository) checkGroupExistence(ctx context.Context, groupID int) (bool, error) {
 	count := 0
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(id) FROM groups WHERE id = $1;", groupID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(id) FROM groups WHERE id = $1 AND deleted_at IS NULL;", groupID).Scan(&count)
 	if errors.Is(err, sql.ErrNoRows) {
 		return false, nil
 	}
@@ -288,52 +499,45 @@ func (r *sqlRepository) checkGroupExistence(ctx context.Context, groupID int) (b
 }
 
 func (r *sqlRepository) Click(ctx context.Context, slotID, bannerID, groupID int) error {
-	if err := r.checkFullRelationExistence(ctx, slotID, bannerID, groupID); err != nil {
-		return err
-	}
-
-	result, resErr := r.db.ExecContext(ctx, "UPDATE relations SET clicks = clicks + 1 WHERE slot_id = $1 AND banner_id = $2 AND group_id = $3;", slotID, bannerID, groupID)
-	if resErr == nil {
-		rows, err := result.RowsAffected()
-
-		if err != nil {
-			log.Error("failed to check affected row while updating clicks: ", err.Error())
-		} else if rows != 1 {
-			log.WithFields(log.Fields{
-				"slot id":   slotID,
-				"banner id": bannerID,
-			}).Errorf("expected to affect 1 row, but affected %d while updating clicks", rows)
-		}
-	}
-
-	return resErr
+	return r.countEvent(ctx, repository.Event{Type: repository.EventClick, SlotID: slotID, BannerID: bannerID, GroupID: groupID})
 }
 
 func (r *sqlRepository) Show(ctx context.Context, slotID, bannerID, groupID int) error {
-	if err := r.checkFullRelationExistence(ctx, slotID, bannerID, groupID); err != nil {
+	return r.countEvent(ctx, repository.Event{Type: repository.EventShow, SlotID: slotID, BannerID: bannerID, GroupID: groupID})
+}
+
+// countEvent adds the event without an impression to the relation counter, the budget spend
+// and the stored events in a single transaction, so that they never disagree.
+func (r *sqlRepository) countEvent(ctx context.Context, e repository.Event) error {
+	if err := r.checkFullRelationExistence(ctx, e.SlotID, e.BannerID, e.GroupID); err != nil {
 		return err
 	}
 
-	result, resErr := r.db.ExecContext(ctx, "UPDATE relations SET impressions = impressions + 1 WHERE slot_id = $1 AND banner_id = $2 AND group_id = $3;", slotID, bannerID, groupID)
-
-	if resErr == nil {
-		rows, err := result.RowsAffected()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
 
-		if err != nil {
-			log.Error("failed to check affected row while updating impressions: ", err.Error())
-		} else if rows != 1 {
-			log.WithFields(log.Fields{
-				"slot id":   slotID,
-				"banner id": bannerID,
-			}).Errorf("expected to affect 1 row, but affected %d while updating impressions", rows)
+	column := "clicks"
+	if e.Type == repository.EventShow {
+		column = "impressions"
+		if err := addBudgetSpend(ctx, tx, map[int]int64{e.BannerID: 1}, time.Now()); err != nil {
+			return err
 		}
 	}
+	if err := incrementCounter(ctx, tx, column, e.SlotID, e.BannerID, e.GroupID); err != nil {
+		return err
+	}
+	if err := storeEvents(ctx, tx, []repository.Event{e}); err != nil {
+		return err
+	}
 
-	return resErr
+	return tx.Commit()
 }
 
 func (r *sqlRepository) GetAllBanners(ctx context.Context) ([]repository.Banner, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id, url, description FROM banners;") //nolint:rowserrcheck,sqlclosecheck
+	rows, err := r.db.QueryContext(ctx, "SELECT "+bannerColumns+" FROM banners WHERE deleted_at IS NULL;") //nolint:rowserrcheck,sqlclosecheck
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -341,8 +545,8 @@ func (r *sqlRepository) GetAllBanners(ctx context.Context) ([]repository.Banner,
 
 	banners := make([]repository.Banner, 0)
 	for rows.Next() {
-		banner := repository.Banner{}
-		if err := rows.Scan(&banner.ID, &banner.URL, &banner.Description); err != nil {
+		banner, err := scanBanner(rows)
+		if err != nil {
 			log.Error(err)
 		}
 		banners = append(banners, banner)
@@ -415,12 +619,11 @@ func (r *sqlRepository) checkSlotBannerGroupExistence(ctx context.Context, slotI
 }
 
 func (r *sqlRepository) AddGroup(ctx context.Context, description string) (repository.Group, error) {
-	group := repository.Group{}
-	err := r.db.QueryRowContext(ctx, "SELECT id, description FROM groups WHERE description = $1;", description).Scan(&group.ID, &group.Description)
+	group, err := scanGroup(r.db.QueryRowContext(ctx, "SELECT "+groupColumns+" FROM groups WHERE description = $1 AND deleted_at IS NULL;", description))
 	if errors.Is(err, sql.ErrNoRows) {
 		group.Description = description
 
-		err := r.db.QueryRowContext(ctx, "INSERT INTO groups (description) VALUES ($1) RETURNING id;", description).Scan(&group.ID)
+		err := r.db.QueryRowContext(ctx, "INSERT INTO groups (description) VALUES ($1) RETURNING id, version;", description).Scan(&group.ID, &group.Version)
 		if err != nil {
 			return group, err
 		}
@@ -440,7 +643,7 @@ func (r *sqlRepository) AddGroup(ctx context.Context, description string) (repos
 		"description": group.Description,
 	}).Warning("social group exists")
 
-	return group, nil
+	return group, fmt.Errorf("social group with id = %d: %w", group.ID, repository.ErrAlreadyExists)
 }
 
 func (r *sqlRepository) addGroupToRelation(ctx context.Context, groupID int) error {
@@ -476,7 +679,7 @@ func (r *sqlRepository) addGroupToRelation(ctx context.Context, groupID int) err
 }
 
 func (r *sqlRepository) RemoveGroup(ctx context.Context, groupID int) error {
-	result, resErr := r.db.ExecContext(ctx, "DELETE FROM groups WHERE id = $1;", groupID)
+	result, resErr := r.db.ExecContext(ctx, "UPDATE groups SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL;", groupID)
 	if resErr == nil {
 		logEntry := log.WithFields(log.Fields{
 			"group id": groupID,
@@ -487,7 +690,7 @@ func (r *sqlRepository) RemoveGroup(ctx context.Context, groupID int) error {
 		case err != nil:
 			log.Error("failed to check affected row while removing group: ", err.Error())
 		case rows == 0:
-			logEntry.Warning("no group to delete with the same id")
+			logEntry.Warning("no group to delete with the same id or it is already deleted")
 		case rows != 1:
 			logEntry.Errorf("expected to affect 1 row, but affected %d while removing group", rows)
 		default:
@@ -498,25 +701,178 @@ func (r *sqlRepository) RemoveGroup(ctx context.Context, groupID int) error {
 	return resErr
 }
 
-func (r *sqlRepository) GetAllGroups(ctx context.Context) ([]repository.Group, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id, description FROM groups;") //nolint:rowserrcheck,sqlclosecheck
+func (r *sqlRepository) UpdateGroup(ctx context.Context, groupID int, update repository.GroupUpdate, version int) (repository.Group, error) {
+	current, err := r.GetGroupByID(ctx, groupID)
 	if err != nil {
-		log.Fatal(err)
+		return current, err
+	}
+	if version != 0 && version != current.Version {
+		return current, fmt.Errorf("group with id = %d has version %d: %w", groupID, current.Version, repository.ErrVersionConflict)
+	}
+
+	group := update.Apply(current)
+	if err := group.ValidateSegment(); err != nil {
+		return current, err
+	}
+	groupSegment, err := segmentValue(group.Segment)
+	if err != nil {
+		return current, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return current, err
+	}
+	defer rollback(tx)
+
+	if group.Fallback && !current.Fallback {
+		if _, err := tx.ExecContext(ctx, "UPDATE groups SET fallback = false, version = version + 1 WHERE fallback AND deleted_at IS NULL;"); err != nil {
+			return current, err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `UPDATE groups SET description = $1, segment = $2, fallback = $3, version = version + 1
+WHERE id = $4 AND version = $5 AND deleted_at IS NULL RETURNING version;`,
+		group.Description, groupSegment, group.Fallback, groupID, current.Version).Scan(&group.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return current, fmt.Errorf("group with id = %d was changed concurrently: %w", groupID, repository.ErrVersionConflict)
+	}
+	if err != nil {
+		return current, err
 	}
-	defer checkRows(rows)
 
+	if err := tx.Commit(); err != nil {
+		return current, err
+	}
+
+	log.WithFields(log.Fields{
+		"group id":    groupID,
+		"description": group.Description,
+		"fallback":    group.Fallback,
+		"version":     group.Version,
+	}).Info("social group was updated")
+
+	return group, nil
+}
+
+func (r *sqlRepository) GetGroupByID(ctx context.Context, groupID int) (repository.Group, error) {
+	res, err := scanGroup(r.db.QueryRowContext(ctx, "SELECT "+groupColumns+" FROM groups WHERE id = $1 AND deleted_at IS NULL;", groupID))
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Errorf("no group with id %d", groupID)
+		return res, fmt.Errorf("group with id = %d: %w", groupID, repository.ErrNotFound)
+	}
+
+	return res, err
+}
+
+func (r *sqlRepository) GetAllGroups(ctx context.Context) ([]repository.Group, error) {
 	groups := make([]repository.Group, 0)
-	for rows.Next() {
-		group := repository.Group{}
-		if err := rows.Scan(&group.ID, &group.Description); err != nil {
-			log.Error("failed to scan row with group while getting all groups")
+	if err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return err
 		}
 		groups = append(groups, group)
+		return nil
+	}, "SELECT "+groupColumns+" FROM groups WHERE deleted_at IS NULL;"); err != nil {
+		return nil, fmt.Errorf("failed to get all groups: %w", err)
 	}
 
 	return groups, nil
 }
 
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBanner reads a banner selected with bannerColumns, extra destinations
+// receive the columns following them.
+func scanBanner(row scanner, extra ...interface{}) (repository.Banner, error) {
+	b := repository.Banner{}
+	startAt := sql.NullTime{}
+	endAt := sql.NullTime{}
+	capLimit := sql.NullInt32{}
+	capPeriod := sql.NullString{}
+	campaignID := sql.NullInt32{}
+	budget := []byte(nil)
+	dest := append([]interface{}{&b.ID, &b.URL, &b.Description, &b.Status, &startAt, &endAt, &b.Version, pq.Array(&b.Labels), &capLimit, &capPeriod, &campaignID, &budget}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return b, err
+	}
+
+	if startAt.Valid {
+		b.StartAt = &startAt.Time
+	}
+	if endAt.Valid {
+		b.EndAt = &endAt.Time
+	}
+	if capLimit.Valid && capPeriod.Valid {
+		b.FrequencyCap = &repository.FrequencyCap{Limit: int(capLimit.Int32), Period: capping.Period(capPeriod.String)}
+	}
+	b.CampaignID = int(campaignID.Int32)
+
+	var err error
+	if b.Budget, err = parseBudget(budget); err != nil {
+		return b, fmt.Errorf("invalid budget of banner with id = %d: %w", b.ID, err)
+	}
+
+	return b, nil
+}
+
+// labels turns nil labels into an empty array, the column can't be NULL.
+func labels(l []string) []string {
+	if l == nil {
+		return []string{}
+	}
+
+	return l
+}
+
+// scanGroup reads a group selected with groupColumns.
+func scanGroup(row scanner) (repository.Group, error) {
+	g := repository.Group{}
+	groupSegment := []byte(nil)
+	if err := row.Scan(&g.ID, &g.Description, &g.Version, &groupSegment, &g.Fallback); err != nil {
+		return g, err
+	}
+
+	if groupSegment != nil {
+		g.Segment = &segment.Segment{}
+		if err := json.Unmarshal(groupSegment, g.Segment); err != nil {
+			return g, fmt.Errorf("invalid segment of group with id = %d: %w", g.ID, err)
+		}
+	}
+
+	return g, nil
+}
+
+// segmentValue turns the segment into the segment column, which is NULL without a segment.
+func segmentValue(s *segment.Segment) (sql.NullString, error) {
+	if s == nil {
+		return sql.NullString{}, nil
+	}
+
+	b, err := json.Marshal(s)
+
+	return nullJSON(b), err
+}
+
+// capValues turns the frequency cap into the cap_limit and cap_period columns, which are NULL without a cap.
+func capValues(c *repository.FrequencyCap) (sql.NullInt32, sql.NullString) {
+	if c == nil {
+		return sql.NullInt32{}, sql.NullString{}
+	}
+
+	return sql.NullInt32{Int32: int32(c.Limit), Valid: true}, sql.NullString{String: string(c.Period), Valid: true}
+}
+
+// rollback is deferred right after a transaction begins, it does nothing once the transaction is committed.
+func rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		log.Error("failed to rollback transaction: ", err.Error())
+	}
+}
+
 func checkRows(rows *sql.Rows) {
 	if err := rows.Err(); err != nil {
 		log.Fatal("failed to check rows: ", err.Error())
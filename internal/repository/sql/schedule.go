@@ -0,0 +1,124 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/delivery"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/schedule"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (r *sqlRepository) SetRelationSchedule(ctx context.Context, slotID, bannerID int, s *schedule.Schedule) error {
+	relationExist, err := r.checkRelationExistence(ctx, slotID, bannerID)
+	if err != nil {
+		return err
+	}
+	if !relationExist {
+		return fmt.Errorf("relation with slot id = %d and banner id = %d: %w", slotID, bannerID, repository.ErrNotFound)
+	}
+
+	logEntry := log.WithFields(log.Fields{
+		"slot id":   slotID,
+		"banner id": bannerID,
+	})
+
+	if s == nil {
+		if _, err := r.db.ExecContext(ctx, "DELETE FROM relation_schedules WHERE slot_id = $1 AND banner_id = $2;", slotID, bannerID); err != nil {
+			return err
+		}
+		logEntry.Info("relation schedule was removed")
+
+		return nil
+	}
+
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `INSERT INTO relation_schedules (slot_id, banner_id, schedule) VALUES ($1, $2, $3)
+ON CONFLICT (slot_id, banner_id) DO UPDATE SET schedule = excluded.schedule;`, slotID, bannerID, string(b)); err != nil {
+		return err
+	}
+	logEntry.Info("relation schedule was set")
+
+	return nil
+}
+
+func (r *sqlRepository) GetSlotBanners(ctx context.Context, slotID int) ([]repository.SlotBanner, error) {
+	banners := make([]repository.SlotBanner, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		sb, err := scanSlotBanner(rows)
+		if err != nil {
+			return err
+		}
+		banners = append(banners, sb)
+		return nil
+	}, "SELECT "+slotBannerColumns+"\nFROM (SELECT DISTINCT slot_id, banner_id FROM relations WHERE slot_id = $1) r"+slotBannerJoins+"\nORDER BY b.id;", slotID)
+	if err != nil {
+		return nil, err
+	}
+
+	return banners, r.loadBudgetUsage(ctx, banners, time.Now())
+}
+
+// scanSlotBanner reads a banner selected with slotBannerColumns, the status and the budget of
+// its campaign are NULL for banners without a campaign and the delivery tier is NULL for standard
+// banners. Extra destinations receive the columns following them. The budgets come without their usage.
+func scanSlotBanner(row scanner, extra ...interface{}) (repository.SlotBanner, error) {
+	rawSchedule := []byte(nil)
+	campaignStatus := sql.NullString{}
+	rawCampaignBudget := []byte(nil)
+	tier := sql.NullString{}
+	share := sql.NullFloat64{}
+	banner, err := scanBanner(row, append([]interface{}{&rawSchedule, &campaignStatus, &rawCampaignBudget, &tier, &share}, extra...)...)
+	if err != nil {
+		return repository.SlotBanner{}, err
+	}
+
+	sb := repository.SlotBanner{
+		Banner:         banner,
+		CampaignPaused: campaignStatus.Valid && repository.CampaignStatus(campaignStatus.String) != repository.CampaignActive,
+	}
+	if sb.Schedule, err = parseSchedule(rawSchedule); err != nil {
+		return sb, err
+	}
+
+	if banner.Budget != nil {
+		sb.Budgets = append(sb.Budgets, repository.BudgetState{Owner: repository.BudgetBanner, OwnerID: banner.ID, Budget: *banner.Budget})
+	}
+	campaignBudget, err := parseBudget(rawCampaignBudget)
+	if err != nil {
+		return sb, fmt.Errorf("invalid budget of campaign with id = %d: %w", banner.CampaignID, err)
+	}
+	if campaignBudget != nil {
+		sb.Budgets = append(sb.Budgets, repository.BudgetState{Owner: repository.BudgetCampaign, OwnerID: banner.CampaignID, Budget: *campaignBudget})
+	}
+	if tier.Valid {
+		sb.Delivery = &delivery.Settings{Tier: delivery.Tier(tier.String), Share: share.Float64}
+	}
+
+	return sb, nil
+}
+
+// parseSchedule reads the schedule column, which is NULL for relations without a schedule.
+func parseSchedule(raw []byte) (*schedule.Schedule, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	s := &schedule.Schedule{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, fmt.Errorf("invalid relation schedule: %w", err)
+	}
+
+	return s, nil
+}
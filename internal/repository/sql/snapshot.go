@@ -0,0 +1,219 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+const uniqueViolation = "23505"
+
+// scopeCondition matches the relations of a scope given as the $1 slot id and the $2 group id.
+const scopeCondition = "($1 = 0 OR slot_id = $1) AND ($2 = 0 OR group_id = $2)"
+
+// experimentScopeCondition matches the experiment counters of the scope, their slot is the one of the experiment.
+const experimentScopeCondition = `experiment_id IN (SELECT id FROM experiments WHERE $1 = 0 OR slot_id = $1)
+    AND ($2 = 0 OR group_id = $2)`
+
+func (r *sqlRepository) CreateSnapshot(ctx context.Context, name, comment string, scope repository.StatisticScope) (repository.Snapshot, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return repository.Snapshot{}, err
+	}
+	defer rollback(tx)
+
+	snapshot, err := createSnapshot(ctx, tx, name, comment, scope)
+	if err != nil {
+		return snapshot, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return snapshot, err
+	}
+	snapshotLogEntry(snapshot).Info("statistic snapshot was created")
+
+	return snapshot, nil
+}
+
+func (r *sqlRepository) ResetStatistic(ctx context.Context, name, comment string, scope repository.StatisticScope) (repository.Snapshot, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return repository.Snapshot{}, err
+	}
+	defer rollback(tx)
+
+	snapshot, err := createSnapshot(ctx, tx, name, comment, scope)
+	if err != nil {
+		return snapshot, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE relations SET impressions = 0, clicks = 0 WHERE "+scopeCondition+";",
+		scope.SlotID, scope.GroupID); err != nil {
+		return snapshot, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM holdout_counters WHERE "+scopeCondition+";",
+		scope.SlotID, scope.GroupID); err != nil {
+		return snapshot, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM experiment_counters WHERE "+experimentScopeCondition+";",
+		scope.SlotID, scope.GroupID); err != nil {
+		return snapshot, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return snapshot, err
+	}
+	snapshotLogEntry(snapshot).Info("statistic was reset")
+
+	return snapshot, nil
+}
+
+func (r *sqlRepository) RestoreSnapshot(ctx context.Context, snapshotID int) (repository.Snapshot, error) {
+	snapshot := repository.Snapshot{}
+	err := r.db.QueryRowContext(ctx, snapshotSelect+" WHERE s.id = $1 GROUP BY s.id;", snapshotID).Scan(
+		&snapshot.ID, &snapshot.Name, &snapshot.Comment, &snapshot.Scope.SlotID, &snapshot.Scope.GroupID, &snapshot.CreatedAt, &snapshot.Relations)
+	if errors.Is(err, sql.ErrNoRows) {
+		return snapshot, fmt.Errorf("snapshot with id = %d: %w", snapshotID, repository.ErrNotFound)
+	}
+	if err != nil {
+		return snapshot, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return snapshot, err
+	}
+	defer rollback(tx)
+
+	result, err := tx.ExecContext(ctx, `UPDATE relations r SET impressions = sr.impressions, clicks = sr.clicks
+FROM snapshot_relations sr
+WHERE sr.snapshot_id = $1 AND r.slot_id = sr.slot_id AND r.banner_id = sr.banner_id AND r.group_id = sr.group_id;`, snapshotID)
+	if err != nil {
+		return snapshot, err
+	}
+	if err := restoreHoldoutCounters(ctx, tx, snapshot); err != nil {
+		return snapshot, err
+	}
+	if err := restoreExperimentCounters(ctx, tx, snapshot); err != nil {
+		return snapshot, err
+	}
+	if err := tx.Commit(); err != nil {
+		return snapshot, err
+	}
+
+	logEntry := snapshotLogEntry(snapshot)
+	rows, err := result.RowsAffected()
+	switch {
+	case err != nil:
+		logEntry.Error("failed to check affected row while restoring snapshot: ", err.Error())
+	case rows != int64(snapshot.Relations):
+		logEntry.Warningf("%d relations of the snapshot don't exist anymore", int64(snapshot.Relations)-rows)
+	}
+	logEntry.Info("statistic snapshot was restored")
+
+	return snapshot, nil
+}
+
+const snapshotSelect = `SELECT s.id, s.name, s.comment, s.slot_id, s.group_id, s.created_at, COUNT(sr.snapshot_id)
+FROM snapshots s LEFT JOIN snapshot_relations sr ON sr.snapshot_id = s.id`
+
+func (r *sqlRepository) GetSnapshots(ctx context.Context) ([]repository.Snapshot, error) {
+	snapshots := make([]repository.Snapshot, 0)
+	err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		s := repository.Snapshot{}
+		err := rows.Scan(&s.ID, &s.Name, &s.Comment, &s.Scope.SlotID, &s.Scope.GroupID, &s.CreatedAt, &s.Relations)
+		snapshots = append(snapshots, s)
+		return err
+	}, snapshotSelect+" GROUP BY s.id ORDER BY s.created_at DESC;")
+
+	return snapshots, err
+}
+
+func createSnapshot(ctx context.Context, tx *sql.Tx, name, comment string, scope repository.StatisticScope) (repository.Snapshot, error) {
+	snapshot := repository.Snapshot{
+		Name:    name,
+		Comment: comment,
+		Scope:   scope,
+	}
+
+	err := tx.QueryRowContext(ctx, "INSERT INTO snapshots (name, comment, slot_id, group_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at;",
+		name, comment, scope.SlotID, scope.GroupID).Scan(&snapshot.ID, &snapshot.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return snapshot, fmt.Errorf("snapshot %q: %w", name, repository.ErrAlreadyExists)
+	}
+	if err != nil {
+		return snapshot, err
+	}
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO snapshot_relations
+    (snapshot_id, slot_id, banner_id, group_id, impressions, clicks, holdout_impressions, holdout_clicks)
+SELECT $3::INTEGER, r.slot_id, r.banner_id, r.group_id, r.impressions, r.clicks, COALESCE(h.impressions, 0), COALESCE(h.clicks, 0)
+FROM relations r LEFT JOIN holdout_counters h
+    ON h.slot_id = r.slot_id AND h.banner_id = r.banner_id AND h.group_id = r.group_id
+WHERE ($1 = 0 OR r.slot_id = $1) AND ($2 = 0 OR r.group_id = $2);`,
+		scope.SlotID, scope.GroupID, snapshot.ID)
+	if err != nil {
+		return snapshot, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Relations = int(rows)
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO snapshot_experiment_counters
+    (snapshot_id, experiment_id, arm, banner_id, group_id, impressions, clicks)
+SELECT $3::INTEGER, experiment_id, arm, banner_id, group_id, impressions, clicks FROM experiment_counters
+WHERE `+experimentScopeCondition+";",
+		scope.SlotID, scope.GroupID, snapshot.ID)
+
+	return snapshot, err
+}
+
+// restoreHoldoutCounters replaces the holdout counters of the snapshot scope with the snapshot ones.
+func restoreHoldoutCounters(ctx context.Context, tx *sql.Tx, snapshot repository.Snapshot) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM holdout_counters WHERE "+scopeCondition+";",
+		snapshot.Scope.SlotID, snapshot.Scope.GroupID); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO holdout_counters (slot_id, banner_id, group_id, impressions, clicks)
+SELECT slot_id, banner_id, group_id, holdout_impressions, holdout_clicks FROM snapshot_relations
+WHERE snapshot_id = $1 AND (holdout_impressions > 0 OR holdout_clicks > 0);`, snapshot.ID)
+
+	return err
+}
+
+// restoreExperimentCounters replaces the experiment counters of the snapshot scope with the snapshot ones,
+// the counters of the experiments removed since the snapshot are dropped.
+func restoreExperimentCounters(ctx context.Context, tx *sql.Tx, snapshot repository.Snapshot) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM experiment_counters WHERE "+experimentScopeCondition+";",
+		snapshot.Scope.SlotID, snapshot.Scope.GroupID); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO experiment_counters (experiment_id, arm, banner_id, group_id, impressions, clicks)
+SELECT sc.experiment_id, sc.arm, sc.banner_id, sc.group_id, sc.impressions, sc.clicks FROM snapshot_experiment_counters sc
+    JOIN experiments e ON e.id = sc.experiment_id
+WHERE sc.snapshot_id = $1;`, snapshot.ID)
+
+	return err
+}
+
+func snapshotLogEntry(s repository.Snapshot) *log.Entry {
+	return log.WithFields(log.Fields{
+		"snapshot id": s.ID,
+		"name":        s.Name,
+		"slot id":     s.Scope.SlotID,
+		"group id":    s.Scope.GroupID,
+		"relations":   s.Relations,
+	})
+}
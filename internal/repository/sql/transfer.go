@@ -0,0 +1,304 @@
+package sqlrepository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+func (r *sqlRepository) Export(ctx context.Context) (repository.Configuration, error) {
+	config := repository.Configuration{
+		Banners:   make([]repository.Banner, 0),
+		Slots:     make([]repository.Slot, 0),
+		Groups:    make([]repository.Group, 0),
+		Relations: make([]repository.Relation, 0),
+	}
+
+	if err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		banner, err := scanBanner(rows)
+		config.Banners = append(config.Banners, banner)
+		return err
+	}, "SELECT "+bannerColumns+" FROM banners WHERE deleted_at IS NULL ORDER BY id;"); err != nil {
+		return config, err
+	}
+
+	if err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		slot := repository.Slot{}
+		err := rows.Scan(&slot.ID)
+		config.Slots = append(config.Slots, slot)
+		return err
+	}, "SELECT id FROM slots WHERE deleted_at IS NULL ORDER BY id;"); err != nil {
+		return config, err
+	}
+
+	if err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		group, err := scanGroup(rows)
+		config.Groups = append(config.Groups, group)
+		return err
+	}, "SELECT "+groupColumns+" FROM groups WHERE deleted_at IS NULL ORDER BY id;"); err != nil {
+		return config, err
+	}
+
+	if err := queryEach(ctx, r.db, func(rows *sql.Rows) error {
+		relation := repository.Relation{}
+		err := rows.Scan(&relation.SlotID, &relation.BannerID)
+		config.Relations = append(config.Relations, relation)
+		return err
+	}, `SELECT DISTINCT r.slot_id, r.banner_id FROM relations r
+    JOIN banners b ON b.id = r.banner_id AND b.deleted_at IS NULL
+    JOIN slots s ON s.id = r.slot_id AND s.deleted_at IS NULL
+ORDER BY r.slot_id, r.banner_id;`); err != nil {
+		return config, err
+	}
+
+	log.WithFields(log.Fields{
+		"banners":   len(config.Banners),
+		"slots":     len(config.Slots),
+		"groups":    len(config.Groups),
+		"relations": len(config.Relations),
+	}).Info("configuration was exported")
+
+	return config, nil
+}
+
+// queryer is implemented by both sql.DB and sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryEach calls scan for every row selected by the query, an error of reading the rows is returned
+// as well, so that the queries of serving the requests never stop the service.
+func queryEach(ctx context.Context, q queryer, scan func(rows *sql.Rows) error, query string, args ...interface{}) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *sqlRepository) Import(ctx context.Context, config repository.Configuration, dryRun bool) (repository.ImportReport, error) {
+	report := repository.ImportReport{DryRun: dryRun}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer rollback(tx)
+
+	if err := importGroups(ctx, tx, config.Groups, &report.Groups); err != nil {
+		return report, err
+	}
+
+	bannerIDs, err := importBanners(ctx, tx, config.Banners, &report.Banners)
+	if err != nil {
+		return report, err
+	}
+
+	slotIDs, err := importSlots(ctx, tx, config.Slots, &report.Slots)
+	if err != nil {
+		return report, err
+	}
+
+	if err := importRelations(ctx, tx, config.Relations, slotIDs, bannerIDs, &report.Relations); err != nil {
+		return report, err
+	}
+
+	logEntry := log.WithFields(log.Fields{
+		"dry run":           dryRun,
+		"created banners":   report.Banners.Created,
+		"created slots":     report.Slots.Created,
+		"created groups":    report.Groups.Created,
+		"created relations": report.Relations.Created,
+	})
+	if dryRun {
+		logEntry.Info("configuration import was checked")
+		return report, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+	logEntry.Info("configuration was imported")
+
+	return report, nil
+}
+
+func importGroups(ctx context.Context, tx *sql.Tx, groups []repository.Group, counts *repository.ImportCounts) error {
+	for _, g := range groups {
+		if err := g.ValidateSegment(); err != nil {
+			return fmt.Errorf("group %d: %w", g.ID, err)
+		}
+
+		id := 0
+		err := tx.QueryRowContext(ctx, "SELECT id FROM groups WHERE description = $1 AND deleted_at IS NULL;", g.Description).Scan(&id)
+		if err == nil {
+			counts.Existing++
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// the fallback mark isn't imported, it would clash with the fallback group of the database
+		groupSegment, err := segmentValue(g.Segment)
+		if err != nil {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, "INSERT INTO groups (description, segment) VALUES ($1, $2) RETURNING id;", g.Description, groupSegment).Scan(&id); err != nil {
+			return err
+		}
+		// a new group takes part in every existing relation, the same as with AddGroup
+		if _, err := tx.ExecContext(ctx, `INSERT INTO relations (slot_id, banner_id, group_id, impressions, clicks)
+SELECT DISTINCT slot_id, banner_id, $1::INTEGER, 0, 0 FROM relations;`, id); err != nil {
+			return err
+		}
+		counts.Created++
+	}
+
+	return nil
+}
+
+// importBanners returns the database ids of the imported banners keyed by their ids in the configuration.
+func importBanners(ctx context.Context, tx *sql.Tx, banners []repository.Banner, counts *repository.ImportCounts) (map[int]int, error) {
+	ids := make(map[int]int, len(banners))
+	for _, b := range banners {
+		if b.Status == "" {
+			b.Status = repository.BannerActive
+		}
+		if !b.Status.Valid() {
+			return nil, fmt.Errorf("banner %d has unknown status %q", b.ID, b.Status)
+		}
+		if err := b.ValidateFlight(); err != nil {
+			return nil, fmt.Errorf("banner %d: %w", b.ID, err)
+		}
+		if err := b.ValidateCap(); err != nil {
+			return nil, fmt.Errorf("banner %d: %w", b.ID, err)
+		}
+		if err := b.ValidateBudget(); err != nil {
+			return nil, fmt.Errorf("banner %d: %w", b.ID, err)
+		}
+		if err := importedCampaign(ctx, tx, b.CampaignID); err != nil {
+			return nil, fmt.Errorf("banner %d: %w", b.ID, err)
+		}
+
+		id := 0
+		err := tx.QueryRowContext(ctx, "SELECT id FROM banners WHERE url = $1 AND description = $2 AND deleted_at IS NULL;", b.URL, b.Description).Scan(&id)
+		switch {
+		case err == nil:
+			counts.Existing++
+		case errors.Is(err, sql.ErrNoRows):
+			capLimit, capPeriod := capValues(b.FrequencyCap)
+			budget, err := budgetValue(b.Budget)
+			if err != nil {
+				return nil, err
+			}
+			if err := tx.QueryRowContext(ctx, `INSERT INTO banners (url, description, status, start_at, end_at, labels, cap_limit, cap_period, campaign_id, budget)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id;`,
+				b.URL, b.Description, b.Status, b.StartAt, b.EndAt, pq.Array(labels(b.Labels)), capLimit, capPeriod,
+				campaignValue(b.CampaignID), budget).Scan(&id); err != nil {
+				return nil, err
+			}
+			counts.Created++
+		default:
+			return nil, err
+		}
+		ids[b.ID] = id
+	}
+
+	return ids, nil
+}
+
+// importedCampaign makes sure that the campaign of an imported banner exists. Campaigns aren't
+// a part of the configuration, so the banners refer to the campaigns of the database.
+func importedCampaign(ctx context.Context, tx *sql.Tx, campaignID int) error {
+	if campaignID == 0 {
+		return nil
+	}
+
+	count := 0
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(id) FROM campaigns WHERE id = $1 AND deleted_at IS NULL;", campaignID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("campaign with id = %d: %w", campaignID, repository.ErrNotFound)
+	}
+
+	return nil
+}
+
+// importSlots returns the database ids of the imported slots keyed by their ids in the configuration.
+func importSlots(ctx context.Context, tx *sql.Tx, slots []repository.Slot, counts *repository.ImportCounts) (map[int]int, error) {
+	ids := make(map[int]int, len(slots))
+	for _, s := range slots {
+		count := 0
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(id) FROM slots WHERE id = $1 AND deleted_at IS NULL;", s.ID).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			ids[s.ID] = s.ID
+			counts.Existing++
+			continue
+		}
+
+		id := 0
+		if err := tx.QueryRowContext(ctx, "INSERT INTO slots DEFAULT VALUES RETURNING id;").Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[s.ID] = id
+		counts.Created++
+	}
+
+	return ids, nil
+}
+
+func importRelations(ctx context.Context, tx *sql.Tx, relations []repository.Relation, slotIDs, bannerIDs map[int]int, counts *repository.ImportCounts) error {
+	for _, rel := range relations {
+		slotID, ok := slotIDs[rel.SlotID]
+		if !ok {
+			return fmt.Errorf("relation refers to slot %d which isn't in the configuration", rel.SlotID)
+		}
+		bannerID, ok := bannerIDs[rel.BannerID]
+		if !ok {
+			return fmt.Errorf("relation refers to banner %d which isn't in the configuration", rel.BannerID)
+		}
+
+		count := 0
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(id) FROM relations WHERE slot_id = $1 AND banner_id = $2;", slotID, bannerID).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			counts.Existing++
+			continue
+		}
+
+		result, err := tx.ExecContext(ctx, `INSERT INTO relations (slot_id, banner_id, group_id, impressions, clicks)
+SELECT $1::INTEGER, $2::INTEGER, id, 0, 0 FROM groups WHERE deleted_at IS NULL;`, slotID, bannerID)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return fmt.Errorf("group with requested parameters not found")
+		}
+		counts.Created++
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package sqlrepository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (r *sqlRepository) RestoreBanner(ctx context.Context, bannerID int) error {
+	return r.restore(ctx, "banners", "banner", bannerID)
+}
+
+func (r *sqlRepository) RestoreSlot(ctx context.Context, slotID int) error {
+	return r.restore(ctx, "slots", "slot", slotID)
+}
+
+func (r *sqlRepository) RestoreGroup(ctx context.Context, groupID int) error {
+	return r.restore(ctx, "groups", "group", groupID)
+}
+
+// restore brings back a soft deleted row, the table name is never taken from the user input.
+func (r *sqlRepository) restore(ctx context.Context, table, entity string, id int) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE "+table+" SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL;", id) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	logEntry := log.WithFields(log.Fields{
+		entity + " id": id,
+	})
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		logEntry.Warning("no deleted " + entity + " to restore with the same id")
+		return fmt.Errorf("deleted %s with id = %d: %w", entity, id, repository.ErrNotFound)
+	}
+
+	logEntry.Info(entity + " was restored")
+
+	return nil
+}
+
+func (r *sqlRepository) Purge(ctx context.Context, deletedBefore time.Time) (repository.PurgeResult, error) {
+	res := repository.PurgeResult{}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer rollback(tx)
+
+	// relations of the purged rows are removed by the ON DELETE CASCADE constraints
+	for _, p := range []struct {
+		table   string
+		counter *int64
+	}{
+		{table: "banners", counter: &res.Banners},
+		{table: "slots", counter: &res.Slots},
+		{table: "groups", counter: &res.Groups},
+	} {
+		result, err := tx.ExecContext(ctx, "DELETE FROM "+p.table+" WHERE deleted_at < $1;", deletedBefore) //nolint:gosec
+		if err != nil {
+			return res, err
+		}
+		if *p.counter, err = result.RowsAffected(); err != nil {
+			return res, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return res, err
+	}
+
+	log.WithFields(log.Fields{
+		"deleted before": deletedBefore,
+		"banners":        res.Banners,
+		"slots":          res.Slots,
+		"groups":         res.Groups,
+	}).Info("deleted items were purged")
+
+	return res, nil
+}
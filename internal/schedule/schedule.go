@@ -0,0 +1,131 @@
+// Package schedule restricts the time when a banner is shown in a slot
+// to some days of the week and hours of the day in a time zone.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	// the time zones are embedded, so that the schedules work where the system has no zone database
+	_ "time/tzdata"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// locations caches the loaded time zones by their names, the schedules are decoded
+// on every banner choice and loading a time zone reads the zone database.
+var locations sync.Map
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// HourRange is the hours from From up to but not including To, e.g. 18-24 is the evening.
+// A range with From greater than To spans midnight, e.g. 22-2.
+type HourRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Schedule allows showing a banner on the days of the week within the hour ranges, both
+// are taken in the time zone. No days mean every day and no hours mean the whole day.
+type Schedule struct {
+	Days     []string    `json:"days,omitempty"`
+	Hours    []HourRange `json:"hours,omitempty"`
+	TimeZone string      `json:"time_zone,omitempty"`
+}
+
+func (h HourRange) contains(hour int) bool {
+	if h.From <= h.To {
+		return hour >= h.From && hour < h.To
+	}
+
+	return hour >= h.From || hour < h.To
+}
+
+func (s Schedule) Validate() error {
+	for _, d := range s.Days {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("%w: unknown day %q, expected one of mon, tue, wed, thu, fri, sat, sun", ErrInvalidSchedule, d)
+		}
+	}
+	for _, h := range s.Hours {
+		if h.From < 0 || h.From > 23 || h.To < 0 || h.To > 24 || h.From == h.To {
+			return fmt.Errorf("%w: invalid hours %d-%d", ErrInvalidSchedule, h.From, h.To)
+		}
+	}
+	if _, err := s.location(); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidSchedule, s.TimeZone)
+	}
+
+	return nil
+}
+
+func (s Schedule) location() (*time.Location, error) {
+	if s.TimeZone == "" {
+		return time.UTC, nil
+	}
+
+	return loadLocation(s.TimeZone)
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+
+	return loc, nil
+}
+
+// Active reports whether the schedule allows showing at the given time.
+// An invalid schedule never does.
+func (s Schedule) Active(at time.Time) bool {
+	loc, err := s.location()
+	if err != nil {
+		return false
+	}
+	at = at.In(loc)
+
+	return s.activeDay(at.Weekday()) && s.activeHour(at.Hour())
+}
+
+func (s Schedule) activeDay(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, d := range s.Days {
+		if wd, ok := weekdays[strings.ToLower(d)]; ok && wd == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s Schedule) activeHour(hour int) bool {
+	if len(s.Hours) == 0 {
+		return true
+	}
+	for _, h := range s.Hours {
+		if h.contains(hour) {
+			return true
+		}
+	}
+
+	return false
+}
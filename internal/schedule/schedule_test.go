@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActive(t *testing.T) {
+	// Saturday, 1 May 2021, 20:30 UTC
+	at := time.Date(2021, 5, 1, 20, 30, 0, 0, time.UTC)
+
+	assert.True(t, Schedule{}.Active(at))
+	assert.True(t, Schedule{Days: []string{"sat", "Sun"}}.Active(at))
+	assert.True(t, Schedule{Hours: []HourRange{{From: 18, To: 24}}}.Active(at))
+	assert.True(t, Schedule{Hours: []HourRange{{From: 8, To: 10}, {From: 20, To: 21}}}.Active(at))
+	assert.True(t, Schedule{Hours: []HourRange{{From: 19, To: 2}}}.Active(at))
+
+	assert.False(t, Schedule{Days: []string{"mon", "tue", "wed", "thu", "fri"}}.Active(at))
+	assert.False(t, Schedule{Hours: []HourRange{{From: 8, To: 20}}}.Active(at))
+	assert.False(t, Schedule{Hours: []HourRange{{From: 22, To: 2}}}.Active(at))
+
+	// it is already Sunday, 05:30 in Tokyo
+	tokyo := Schedule{Days: []string{"sun"}, Hours: []HourRange{{From: 0, To: 6}}, TimeZone: "Asia/Tokyo"}
+	assert.True(t, tokyo.Active(at))
+	assert.False(t, Schedule{TimeZone: "Mars/Olympus"}.Active(at))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Schedule{}.Validate())
+	assert.NoError(t, Schedule{
+		Days:     []string{"Sat", "sun"},
+		Hours:    []HourRange{{From: 18, To: 24}, {From: 22, To: 2}},
+		TimeZone: "Europe/Moscow",
+	}.Validate())
+
+	for _, s := range []Schedule{
+		{Days: []string{"saturday"}},
+		{Hours: []HourRange{{From: 10, To: 10}}},
+		{Hours: []HourRange{{From: 24, To: 2}}},
+		{Hours: []HourRange{{From: -1, To: 2}}},
+		{TimeZone: "Mars/Olympus"},
+	} {
+		assert.True(t, errors.Is(s.Validate(), ErrInvalidSchedule))
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := loadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+	cached, err := loadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+	assert.Same(t, loc, cached)
+
+	_, err = loadLocation("Mars/Olympus")
+	assert.Error(t, err)
+	_, ok := locations.Load("Mars/Olympus")
+	assert.False(t, ok)
+}
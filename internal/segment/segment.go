@@ -0,0 +1,178 @@
+// Package segment maps users to social groups by their attributes. Every group
+// may have a segment, a user belongs to the first group whose segment conditions
+// all hold for the user attributes.
+package segment
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSegment = errors.New("invalid segment")
+
+// Attributes are the raw attributes of a user, e.g. age, gender or country.
+type Attributes map[string]string
+
+type Operator string
+
+const (
+	OpEq     Operator = "eq"
+	OpNeq    Operator = "neq"
+	OpIn     Operator = "in"
+	OpNotIn  Operator = "not_in"
+	OpGt     Operator = "gt"
+	OpGte    Operator = "gte"
+	OpLt     Operator = "lt"
+	OpLte    Operator = "lte"
+	OpExists Operator = "exists"
+)
+
+// Condition is a predicate on a single attribute. Strings are compared case-insensitively,
+// the gt, gte, lt and lte operators compare numbers. A condition on an attribute which
+// the user doesn't have never holds.
+type Condition struct {
+	Attribute string   `json:"attribute"`
+	Op        Operator `json:"op"`
+	Value     string   `json:"value,omitempty"`
+	Values    []string `json:"values,omitempty"`
+}
+
+// Segment describes the users of a group, segments with higher priority are checked first.
+type Segment struct {
+	Priority   int         `json:"priority"`
+	Conditions []Condition `json:"conditions"`
+}
+
+// Candidate is a group which a user can be mapped to.
+type Candidate struct {
+	GroupID int
+	Segment Segment
+}
+
+func (c Condition) Validate() error {
+	if c.Attribute == "" {
+		return fmt.Errorf("%w: condition must have an attribute", ErrInvalidSegment)
+	}
+
+	switch c.Op {
+	case OpEq, OpNeq:
+	case OpIn, OpNotIn:
+		if len(c.Values) == 0 {
+			return fmt.Errorf("%w: %s condition on %q must have values", ErrInvalidSegment, c.Op, c.Attribute)
+		}
+	case OpGt, OpGte, OpLt, OpLte:
+		if _, err := strconv.ParseFloat(c.Value, 64); err != nil {
+			return fmt.Errorf("%w: %s condition on %q must have a numeric value", ErrInvalidSegment, c.Op, c.Attribute)
+		}
+	case OpExists:
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidSegment, c.Op)
+	}
+
+	return nil
+}
+
+func (c Condition) Match(attrs Attributes) bool {
+	value, ok := attrs[c.Attribute]
+	if !ok {
+		return false
+	}
+
+	switch c.Op {
+	case OpEq:
+		return strings.EqualFold(value, c.Value)
+	case OpNeq:
+		return !strings.EqualFold(value, c.Value)
+	case OpIn:
+		return contains(c.Values, value)
+	case OpNotIn:
+		return !contains(c.Values, value)
+	case OpGt, OpGte, OpLt, OpLte:
+		return compare(c.Op, value, c.Value)
+	case OpExists:
+		return true
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func compare(op Operator, value, bound string) bool {
+	x, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	y, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return false
+	}
+
+	switch op {
+	case OpGt:
+		return x > y
+	case OpGte:
+		return x >= y
+	case OpLt:
+		return x < y
+	default:
+		return x <= y
+	}
+}
+
+// Validate checks the conditions, a segment without conditions matches nobody and isn't allowed.
+func (s Segment) Validate() error {
+	if len(s.Conditions) == 0 {
+		return fmt.Errorf("%w: segment must have conditions", ErrInvalidSegment)
+	}
+	for _, c := range s.Conditions {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Match reports whether all the conditions hold for the attributes.
+func (s Segment) Match(attrs Attributes) bool {
+	for _, c := range s.Conditions {
+		if !c.Match(attrs) {
+			return false
+		}
+	}
+
+	return len(s.Conditions) > 0
+}
+
+// Resolve returns the group of the user with the given attributes. The candidates are checked
+// by priority and then by the group id, so the result doesn't depend on their order.
+func Resolve(candidates []Candidate, attrs Attributes) (int, bool) {
+	sorted := make([]Candidate, len(candidates))
+	copy(sorted, candidates)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Segment.Priority != sorted[j].Segment.Priority {
+			return sorted[i].Segment.Priority > sorted[j].Segment.Priority
+		}
+		return sorted[i].GroupID < sorted[j].GroupID
+	})
+
+	for _, c := range sorted {
+		if c.Segment.Match(attrs) {
+			return c.GroupID, true
+		}
+	}
+
+	return 0, false
+}
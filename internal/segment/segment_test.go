@@ -0,0 +1,68 @@
+package segment
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionMatch(t *testing.T) {
+	attrs := Attributes{"age": "25", "gender": "F", "country": "ru"}
+
+	assert.True(t, Condition{Attribute: "gender", Op: OpEq, Value: "f"}.Match(attrs))
+	assert.True(t, Condition{Attribute: "gender", Op: OpNeq, Value: "m"}.Match(attrs))
+	assert.True(t, Condition{Attribute: "country", Op: OpIn, Values: []string{"BY", "RU"}}.Match(attrs))
+	assert.True(t, Condition{Attribute: "country", Op: OpNotIn, Values: []string{"US"}}.Match(attrs))
+	assert.True(t, Condition{Attribute: "age", Op: OpGte, Value: "25"}.Match(attrs))
+	assert.True(t, Condition{Attribute: "age", Op: OpLt, Value: "30"}.Match(attrs))
+	assert.True(t, Condition{Attribute: "age", Op: OpExists}.Match(attrs))
+
+	assert.False(t, Condition{Attribute: "age", Op: OpGt, Value: "25"}.Match(attrs))
+	assert.False(t, Condition{Attribute: "age", Op: OpLte, Value: "24.5"}.Match(attrs))
+	assert.False(t, Condition{Attribute: "gender", Op: OpGt, Value: "1"}.Match(attrs))
+	assert.False(t, Condition{Attribute: "city", Op: OpNeq, Value: "Moscow"}.Match(attrs))
+	assert.False(t, Condition{Attribute: "city", Op: OpExists}.Match(attrs))
+}
+
+func TestSegmentValidate(t *testing.T) {
+	assert.NoError(t, Segment{Conditions: []Condition{
+		{Attribute: "age", Op: OpGte, Value: "18"},
+		{Attribute: "country", Op: OpIn, Values: []string{"RU"}},
+	}}.Validate())
+
+	for _, s := range []Segment{
+		{},
+		{Conditions: []Condition{{Op: OpExists}}},
+		{Conditions: []Condition{{Attribute: "age", Op: "like", Value: "1"}}},
+		{Conditions: []Condition{{Attribute: "age", Op: OpGte, Value: "adult"}}},
+		{Conditions: []Condition{{Attribute: "country", Op: OpIn}}},
+	} {
+		assert.True(t, errors.Is(s.Validate(), ErrInvalidSegment))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	adults := Segment{Conditions: []Condition{{Attribute: "age", Op: OpGte, Value: "18"}}}
+	russianAdults := Segment{Priority: 1, Conditions: []Condition{
+		{Attribute: "age", Op: OpGte, Value: "18"},
+		{Attribute: "country", Op: OpEq, Value: "RU"},
+	}}
+	candidates := []Candidate{
+		{GroupID: 3, Segment: adults},
+		{GroupID: 2, Segment: adults},
+		{GroupID: 5, Segment: russianAdults},
+	}
+
+	group, ok := Resolve(candidates, Attributes{"age": "30", "country": "RU"})
+	assert.True(t, ok)
+	assert.Equal(t, 5, group)
+
+	// equal priorities are resolved by the lower group id
+	group, ok = Resolve(candidates, Attributes{"age": "30", "country": "US"})
+	assert.True(t, ok)
+	assert.Equal(t, 2, group)
+
+	_, ok = Resolve(candidates, Attributes{"age": "12"})
+	assert.False(t, ok)
+}
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/bubblesupreme/banner_rotation/internal/app"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -26,3 +29,19 @@ func jsonHeaderMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// actorMiddleware takes the actor of administrative changes from the X-Actor header
+// and falls back to the client address.
+func actorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get("X-Actor")
+		if actor == "" {
+			actor = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				actor = host
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(app.WithActor(r.Context(), actor)))
+	})
+}
@@ -23,24 +23,76 @@ type Server struct {
 func NewServer(app *app.BannersApp, port int) *Server {
 	r := mux.NewRouter()
 	r.HandleFunc("/get_banner", app.GetBanner).Methods("POST")
+	r.HandleFunc("/get_page_banners", app.GetPageBanners).Methods("POST")
 	r.HandleFunc("/banner", app.AddBanner).Methods("POST")
 	r.HandleFunc("/banner", app.RemoveBanner).Methods("DELETE")
+	r.HandleFunc("/banner", app.UpdateBanner).Methods("PATCH")
+	r.HandleFunc("/banner/pause", app.PauseBanner).Methods("POST")
+	r.HandleFunc("/banner/resume", app.ResumeBanner).Methods("POST")
+	r.HandleFunc("/banner/archive", app.ArchiveBanner).Methods("POST")
+	r.HandleFunc("/banner/restore", app.RestoreBanner).Methods("POST")
 
 	r.HandleFunc("/slot", app.AddSlot).Methods("POST")
 	r.HandleFunc("/slot", app.RemoveSlot).Methods("DELETE")
+	r.HandleFunc("/slot/restore", app.RestoreSlot).Methods("POST")
 
 	r.HandleFunc("/relation", app.AddRelation).Methods("POST")
 	r.HandleFunc("/relation", app.RemoveRelation).Methods("DELETE")
+	r.HandleFunc("/relation/schedule", app.SetRelationSchedule).Methods("PUT")
+	r.HandleFunc("/relation/schedule", app.RemoveRelationSchedule).Methods("DELETE")
+	r.HandleFunc("/relation/delivery", app.SetRelationDelivery).Methods("PUT")
+	r.HandleFunc("/relation/delivery", app.RemoveRelationDelivery).Methods("DELETE")
+	r.HandleFunc("/slot/preview", app.PreviewSlot).Methods("GET")
+	r.HandleFunc("/slot/analytics", app.GetSlotAnalytics).Methods("GET")
+	r.HandleFunc("/delivery/report", app.GetDeliveryReport).Methods("GET")
 
 	r.HandleFunc("/group", app.AddGroup).Methods("POST")
 	r.HandleFunc("/group", app.RemoveGroup).Methods("DELETE")
+	r.HandleFunc("/group", app.UpdateGroup).Methods("PATCH")
+	r.HandleFunc("/group/resolve", app.ResolveGroup).Methods("POST")
+	r.HandleFunc("/group/restore", app.RestoreGroup).Methods("POST")
+
+	r.HandleFunc("/advertiser", app.AddAdvertiser).Methods("POST")
+	r.HandleFunc("/advertiser", app.RemoveAdvertiser).Methods("DELETE")
+	r.HandleFunc("/advertiser", app.UpdateAdvertiser).Methods("PATCH")
+	r.HandleFunc("/all_advertisers", app.GetAllAdvertisers).Methods("GET")
+
+	r.HandleFunc("/campaign", app.AddCampaign).Methods("POST")
+	r.HandleFunc("/campaign", app.RemoveCampaign).Methods("DELETE")
+	r.HandleFunc("/campaign", app.UpdateCampaign).Methods("PATCH")
+	r.HandleFunc("/campaign/pause", app.PauseCampaign).Methods("POST")
+	r.HandleFunc("/campaign/resume", app.ResumeCampaign).Methods("POST")
+	r.HandleFunc("/all_campaigns", app.GetAllCampaigns).Methods("GET")
+
+	r.HandleFunc("/experiment", app.AddExperiment).Methods("POST")
+	r.HandleFunc("/experiment/stop", app.StopExperiment).Methods("POST")
+	r.HandleFunc("/experiment/report", app.GetExperimentReport).Methods("GET")
+	r.HandleFunc("/experiments", app.GetExperiments).Methods("GET")
+	r.HandleFunc("/holdout/report", app.GetHoldoutReport).Methods("GET")
 
 	r.HandleFunc("/click", app.Click).Methods("POST")
 	r.HandleFunc("/show", app.Show).Methods("POST")
+	r.HandleFunc("/events", app.Events).Methods("POST")
+	r.HandleFunc("/c/{token}", app.ClickRedirect).Methods("GET")
+	r.HandleFunc("/i/{token}.gif", app.TrackingPixel).Methods("GET")
 	r.HandleFunc("/all_banners", app.GetAllBanners).Methods("GET")
 	r.HandleFunc("/all_groups", app.GetAllGroups).Methods("GET")
+	r.HandleFunc("/snapshot", app.CreateSnapshot).Methods("POST")
+	r.HandleFunc("/snapshot/restore", app.RestoreSnapshot).Methods("POST")
+	r.HandleFunc("/snapshots", app.GetSnapshots).Methods("GET")
+	r.HandleFunc("/statistic/reset", app.ResetStatistic).Methods("POST")
+	r.HandleFunc("/statistic/rollup", app.GetRollup).Methods("GET")
+	r.HandleFunc("/reports", app.GetReport).Methods("GET")
+
+	r.HandleFunc("/audit", app.GetAuditLog).Methods("GET")
+	r.HandleFunc("/fraud/report", app.GetFraudReport).Methods("GET")
+	r.HandleFunc("/import", app.Import).Methods("POST")
+	r.HandleFunc("/export", app.Export).Methods("GET")
+
+	r.NotFoundHandler = http.HandlerFunc(app.NotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(app.MethodNotAllowed)
 
-	r.Use(jsonHeaderMiddleware, loggingMiddleware)
+	r.Use(jsonHeaderMiddleware, loggingMiddleware, actorMiddleware)
 	http.Handle("/", r)
 	return &Server{
 		port:   port,
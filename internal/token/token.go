@@ -0,0 +1,113 @@
+// Package token issues and verifies HMAC signed impression tokens. A token
+// carries the serving decision of GetBanner so that shows and clicks can only
+// be counted for banners which were really served.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid impression token")
+	ErrExpiredToken = errors.New("impression token has expired")
+)
+
+const impressionIDSize = 16
+
+// Impression is a single banner serving decision.
+type Impression struct {
+	ID       string `json:"id"`
+	SlotID   int    `json:"slot"`
+	BannerID int    `json:"banner"`
+	GroupID  int    `json:"group"`
+	UserID   string `json:"user,omitempty"`
+	// Experiment and Arm tell which experiment arm has chosen the banner,
+	// Holdout tells that the banner was chosen at random.
+	Experiment int    `json:"experiment,omitempty"`
+	Arm        string `json:"arm,omitempty"`
+	Holdout    bool   `json:"holdout,omitempty"`
+	ExpiresAt  int64  `json:"exp"`
+}
+
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewSigner(secret []byte, ttl time.Duration) (*Signer, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("impression token secret is empty")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("impression token time to live must be positive")
+	}
+
+	return &Signer{
+		secret: secret,
+		ttl:    ttl,
+		now:    time.Now,
+	}, nil
+}
+
+// Issue gives the impression a new id and an expiration time and returns it with its token.
+func (s *Signer) Issue(imp Impression) (string, Impression, error) {
+	id := make([]byte, impressionIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", imp, err
+	}
+	imp.ID = hex.EncodeToString(id)
+	imp.ExpiresAt = s.now().Add(s.ttl).Unix()
+
+	payload, err := json.Marshal(&imp)
+	if err != nil {
+		return "", imp, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded)), imp, nil
+}
+
+// Parse verifies the token signature and expiration time and returns its impression.
+func (s *Signer) Parse(token string) (Impression, error) {
+	imp := Impression{}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return imp, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, s.sign(parts[0])) {
+		return imp, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return imp, ErrInvalidToken
+	}
+	if err := json.Unmarshal(payload, &imp); err != nil || imp.ID == "" {
+		return imp, ErrInvalidToken
+	}
+
+	if s.now().Unix() >= imp.ExpiresAt {
+		return imp, ErrExpiredToken
+	}
+
+	return imp, nil
+}
+
+func (s *Signer) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,85 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssueAndParse(t *testing.T) {
+	s, err := NewSigner([]byte("secret"), time.Minute)
+	assert.NoError(t, err)
+
+	token, issued, err := s.Issue(Impression{SlotID: 1, BannerID: 2, GroupID: 3, UserID: "user"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, issued.ID)
+
+	imp, err := s.Parse(token)
+	assert.NoError(t, err)
+	assert.Equal(t, issued, imp)
+	assert.Equal(t, 1, imp.SlotID)
+	assert.Equal(t, 2, imp.BannerID)
+	assert.Equal(t, 3, imp.GroupID)
+	assert.Equal(t, "user", imp.UserID)
+
+	other, _, err := s.Issue(Impression{SlotID: 1, BannerID: 2, GroupID: 3})
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+
+	token, issued, err = s.Issue(Impression{SlotID: 1, BannerID: 2, GroupID: 3, Experiment: 4, Arm: "ucb"})
+	assert.NoError(t, err)
+	imp, err = s.Parse(token)
+	assert.NoError(t, err)
+	assert.Equal(t, issued, imp)
+	assert.Equal(t, 4, imp.Experiment)
+	assert.Equal(t, "ucb", imp.Arm)
+}
+
+func TestParseForged(t *testing.T) {
+	s, err := NewSigner([]byte("secret"), time.Minute)
+	assert.NoError(t, err)
+	forger, err := NewSigner([]byte("another secret"), time.Minute)
+	assert.NoError(t, err)
+
+	token, _, err := forger.Issue(Impression{SlotID: 1, BannerID: 2, GroupID: 3})
+	assert.NoError(t, err)
+	_, err = s.Parse(token)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	token, _, err = s.Issue(Impression{SlotID: 1, BannerID: 2, GroupID: 3})
+	assert.NoError(t, err)
+	parts := strings.Split(token, ".")
+	payload, _, err := forger.Issue(Impression{SlotID: 1, BannerID: 5, GroupID: 3})
+	assert.NoError(t, err)
+	_, err = s.Parse(strings.Split(payload, ".")[0] + "." + parts[1])
+	assert.Equal(t, ErrInvalidToken, err)
+
+	for _, bad := range []string{"", "abc", "a.b.c", parts[0] + ".", "." + parts[1]} {
+		_, err = s.Parse(bad)
+		assert.Equal(t, ErrInvalidToken, err)
+	}
+}
+
+func TestParseExpired(t *testing.T) {
+	s, err := NewSigner([]byte("secret"), time.Minute)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	token, _, err := s.Issue(Impression{SlotID: 1, BannerID: 2, GroupID: 3})
+	assert.NoError(t, err)
+
+	s.now = func() time.Time { return now.Add(time.Minute) }
+	_, err = s.Parse(token)
+	assert.Equal(t, ErrExpiredToken, err)
+}
+
+func TestNewSigner(t *testing.T) {
+	_, err := NewSigner(nil, time.Minute)
+	assert.Error(t, err)
+
+	_, err = NewSigner([]byte("secret"), 0)
+	assert.Error(t, err)
+}
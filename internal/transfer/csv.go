@@ -0,0 +1,280 @@
+// Package transfer converts the rotation configuration to and from CSV.
+// Every item is a row, the first column tells its kind:
+//
+//	kind,id,url,description,status,start_at,end_at,slot,banner,labels,cap_limit,cap_period,campaign_id,budget,segment
+//	banner,1,https://example.com/1,first,active,,,,,bank;loans,3,day,2,"{""metric"":""impressions"",""daily"":1000}",
+//	slot,1,,,,,,,,,,,,,
+//	group,1,,students,,,,,,,,,,,"{""conditions"":[{""attribute"":""country"",""op"":""eq"",""value"":""de""}]}"
+//	relation,,,,,,,1,1,,,,,,
+//
+// The labels of a banner are separated by semicolons, so a label containing a semicolon
+// can't be carried by CSV. The budget of a banner and the segment of a group are JSON.
+// Files written before the labels column, with the columns up to banner only, are still read.
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+	"github.com/bubblesupreme/banner_rotation/internal/pacing"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/segment"
+)
+
+const (
+	kindBanner   = "banner"
+	kindSlot     = "slot"
+	kindGroup    = "group"
+	kindRelation = "relation"
+)
+
+const labelSeparator = ";"
+
+var header = []string{
+	"kind", "id", "url", "description", "status", "start_at", "end_at", "slot", "banner",
+	"labels", "cap_limit", "cap_period", "campaign_id", "budget", "segment",
+}
+
+const (
+	colKind = iota
+	colID
+	colURL
+	colDescription
+	colStatus
+	colStartAt
+	colEndAt
+	colSlot
+	colBanner
+	colLabels
+	colCapLimit
+	colCapPeriod
+	colCampaignID
+	colBudget
+	colSegment
+)
+
+// legacyColumns is the number of columns of the files written before the labels column.
+const legacyColumns = colLabels
+
+func EncodeCSV(w io.Writer, config repository.Configuration) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, b := range config.Banners {
+		row := newRow(kindBanner)
+		row[colID] = strconv.Itoa(b.ID)
+		row[colURL] = b.URL
+		row[colDescription] = b.Description
+		row[colStatus] = string(b.Status)
+		row[colStartAt] = formatTime(b.StartAt)
+		row[colEndAt] = formatTime(b.EndAt)
+		if err := encodeBannerSettings(row, b); err != nil {
+			return fmt.Errorf("banner %d: %w", b.ID, err)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	for _, s := range config.Slots {
+		row := newRow(kindSlot)
+		row[colID] = strconv.Itoa(s.ID)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	for _, g := range config.Groups {
+		row := newRow(kindGroup)
+		row[colID] = strconv.Itoa(g.ID)
+		row[colDescription] = g.Description
+		if g.Segment != nil {
+			raw, err := json.Marshal(g.Segment)
+			if err != nil {
+				return fmt.Errorf("group %d: %w", g.ID, err)
+			}
+			row[colSegment] = string(raw)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	for _, r := range config.Relations {
+		row := newRow(kindRelation)
+		row[colSlot] = strconv.Itoa(r.SlotID)
+		row[colBanner] = strconv.Itoa(r.BannerID)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func DecodeCSV(r io.Reader) (repository.Configuration, error) {
+	config := repository.Configuration{}
+
+	cr := csv.NewReader(r)
+	// the header sets the number of fields of every row
+	cr.FieldsPerRecord = 0
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return config, err
+	}
+	if len(rows) == 0 || rows[0][colKind] != header[colKind] {
+		return config, fmt.Errorf("the first CSV row must be the header")
+	}
+	if n := len(rows[0]); n != len(header) && n != legacyColumns {
+		return config, fmt.Errorf("the header must have %d columns, not %d", len(header), n)
+	}
+
+	for i, row := range rows[1:] {
+		line := i + 2
+		// the rows of a legacy file get empty values of the columns it doesn't have
+		row = append(row, make([]string, len(header)-len(row))...)
+		switch row[colKind] {
+		case kindBanner:
+			b, err := decodeBanner(row)
+			if err != nil {
+				return config, fmt.Errorf("line %d: %w", line, err)
+			}
+			config.Banners = append(config.Banners, b)
+		case kindSlot:
+			id, err := strconv.Atoi(row[colID])
+			if err != nil {
+				return config, fmt.Errorf("line %d: invalid slot id: %w", line, err)
+			}
+			config.Slots = append(config.Slots, repository.Slot{ID: id})
+		case kindGroup:
+			id, err := strconv.Atoi(row[colID])
+			if err != nil {
+				return config, fmt.Errorf("line %d: invalid group id: %w", line, err)
+			}
+			g := repository.Group{ID: id, Description: row[colDescription]}
+			if row[colSegment] != "" {
+				g.Segment = &segment.Segment{}
+				if err := json.Unmarshal([]byte(row[colSegment]), g.Segment); err != nil {
+					return config, fmt.Errorf("line %d: invalid group segment: %w", line, err)
+				}
+			}
+			config.Groups = append(config.Groups, g)
+		case kindRelation:
+			slotID, err := strconv.Atoi(row[colSlot])
+			if err != nil {
+				return config, fmt.Errorf("line %d: invalid relation slot: %w", line, err)
+			}
+			bannerID, err := strconv.Atoi(row[colBanner])
+			if err != nil {
+				return config, fmt.Errorf("line %d: invalid relation banner: %w", line, err)
+			}
+			config.Relations = append(config.Relations, repository.Relation{SlotID: slotID, BannerID: bannerID})
+		default:
+			return config, fmt.Errorf("line %d: unknown kind %q", line, row[colKind])
+		}
+	}
+
+	return config, nil
+}
+
+func decodeBanner(row []string) (repository.Banner, error) {
+	b := repository.Banner{
+		URL:         row[colURL],
+		Description: row[colDescription],
+		Status:      repository.BannerStatus(row[colStatus]),
+	}
+
+	var err error
+	if b.ID, err = strconv.Atoi(row[colID]); err != nil {
+		return b, fmt.Errorf("invalid banner id: %w", err)
+	}
+	if b.StartAt, err = parseTime(row[colStartAt]); err != nil {
+		return b, err
+	}
+	if b.EndAt, err = parseTime(row[colEndAt]); err != nil {
+		return b, err
+	}
+	if row[colLabels] != "" {
+		b.Labels = strings.Split(row[colLabels], labelSeparator)
+	}
+	if row[colCapLimit] != "" || row[colCapPeriod] != "" {
+		limit, err := strconv.Atoi(row[colCapLimit])
+		if err != nil {
+			return b, fmt.Errorf("invalid banner cap limit: %w", err)
+		}
+		b.FrequencyCap = &repository.FrequencyCap{Limit: limit, Period: capping.Period(row[colCapPeriod])}
+	}
+	if row[colCampaignID] != "" {
+		if b.CampaignID, err = strconv.Atoi(row[colCampaignID]); err != nil {
+			return b, fmt.Errorf("invalid banner campaign id: %w", err)
+		}
+	}
+	if row[colBudget] != "" {
+		b.Budget = &pacing.Budget{}
+		if err := json.Unmarshal([]byte(row[colBudget]), b.Budget); err != nil {
+			return b, fmt.Errorf("invalid banner budget: %w", err)
+		}
+	}
+
+	return b, nil
+}
+
+// encodeBannerSettings fills the columns of the banner settings which aren't plain values.
+func encodeBannerSettings(row []string, b repository.Banner) error {
+	for _, l := range b.Labels {
+		if strings.Contains(l, labelSeparator) {
+			return fmt.Errorf("label %q can't be written to CSV, it contains %q", l, labelSeparator)
+		}
+	}
+	row[colLabels] = strings.Join(b.Labels, labelSeparator)
+	if b.FrequencyCap != nil {
+		row[colCapLimit] = strconv.Itoa(b.FrequencyCap.Limit)
+		row[colCapPeriod] = string(b.FrequencyCap.Period)
+	}
+	if b.CampaignID != 0 {
+		row[colCampaignID] = strconv.Itoa(b.CampaignID)
+	}
+	if b.Budget != nil {
+		raw, err := json.Marshal(b.Budget)
+		if err != nil {
+			return err
+		}
+		row[colBudget] = string(raw)
+	}
+
+	return nil
+}
+
+func newRow(kind string) []string {
+	row := make([]string, len(header))
+	row[colKind] = kind
+
+	return row
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q: %w", value, err)
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,94 @@
+package transfer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bubblesupreme/banner_rotation/internal/capping"
+	"github.com/bubblesupreme/banner_rotation/internal/pacing"
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+	"github.com/bubblesupreme/banner_rotation/internal/segment"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	start := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	config := repository.Configuration{
+		Banners: []repository.Banner{
+			{
+				ID:           1,
+				URL:          "https://mybanner.com/1",
+				Description:  "first, with comma",
+				Status:       repository.BannerActive,
+				Labels:       []string{"bank", "loans"},
+				FrequencyCap: &repository.FrequencyCap{Limit: 3, Period: capping.PeriodDay},
+				CampaignID:   4,
+				Budget:       &pacing.Budget{Metric: pacing.MetricImpressions, Daily: 1000},
+			},
+			{ID: 2, URL: "https://mybanner.com/2", Description: "second", Status: repository.BannerDraft, StartAt: &start},
+		},
+		Slots: []repository.Slot{{ID: 7}},
+		Groups: []repository.Group{
+			{ID: 3, Description: "students"},
+			{ID: 5, Description: "germany", Segment: &segment.Segment{
+				Conditions: []segment.Condition{{Attribute: "country", Op: segment.OpEq, Value: "de"}},
+			}},
+		},
+		Relations: []repository.Relation{{SlotID: 7, BannerID: 1}, {SlotID: 7, BannerID: 2}},
+	}
+
+	b := bytes.Buffer{}
+	assert.NoError(t, EncodeCSV(&b, config))
+
+	decoded, err := DecodeCSV(&b)
+	assert.NoError(t, err)
+	assert.Equal(t, config.Slots, decoded.Slots)
+	assert.Equal(t, config.Groups, decoded.Groups)
+	assert.Equal(t, config.Relations, decoded.Relations)
+	assert.Len(t, decoded.Banners, 2)
+	assert.Equal(t, config.Banners[0], decoded.Banners[0])
+	assert.Equal(t, repository.BannerDraft, decoded.Banners[1].Status)
+	assert.True(t, start.Equal(*decoded.Banners[1].StartAt))
+	assert.Nil(t, decoded.Banners[1].EndAt)
+	assert.Nil(t, decoded.Banners[1].Labels)
+	assert.Nil(t, decoded.Banners[1].FrequencyCap)
+	assert.Nil(t, decoded.Banners[1].Budget)
+}
+
+func TestDecodeLegacyCSV(t *testing.T) {
+	decoded, err := DecodeCSV(strings.NewReader("kind,id,url,description,status,start_at,end_at,slot,banner\n" +
+		"banner,1,https://mybanner.com/1,first,active,,,,\n" +
+		"relation,,,,,,,7,1\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []repository.Banner{
+		{ID: 1, URL: "https://mybanner.com/1", Description: "first", Status: repository.BannerActive},
+	}, decoded.Banners)
+	assert.Equal(t, []repository.Relation{{SlotID: 7, BannerID: 1}}, decoded.Relations)
+}
+
+func TestEncodeCSVLabelSeparator(t *testing.T) {
+	err := EncodeCSV(&bytes.Buffer{}, repository.Configuration{
+		Banners: []repository.Banner{{ID: 1, URL: "https://mybanner.com/1", Labels: []string{"bank;loans"}}},
+	})
+	assert.EqualError(t, err, `banner 1: label "bank;loans" can't be written to CSV, it contains ";"`)
+}
+
+func TestDecodeCSVErrors(t *testing.T) {
+	_, err := DecodeCSV(strings.NewReader(""))
+	assert.Error(t, err)
+
+	_, err = DecodeCSV(strings.NewReader("banner,1,https://mybanner.com,,,,,,\n"))
+	assert.Error(t, err)
+
+	_, err = DecodeCSV(strings.NewReader("kind,id,url,description,status,start_at,end_at,slot,banner\nunknown,,,,,,,,\n"))
+	assert.EqualError(t, err, `line 2: unknown kind "unknown"`)
+
+	_, err = DecodeCSV(strings.NewReader("kind,id,url,description,status,start_at,end_at,slot,banner\nrelation,,,,,,,x,1\n"))
+	assert.Error(t, err)
+
+	_, err = DecodeCSV(strings.NewReader("kind,id,url,description,status,start_at,end_at,slot,banner\nslot,1\n"))
+	assert.Error(t, err)
+}
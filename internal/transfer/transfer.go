@@ -0,0 +1,47 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/bubblesupreme/banner_rotation/internal/repository"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatJSON, FormatCSV:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("unknown format %q, expected %q or %q", s, FormatJSON, FormatCSV)
+}
+
+func Encode(w io.Writer, config repository.Configuration, format Format) error {
+	if format == FormatCSV {
+		return EncodeCSV(w, config)
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(&config)
+}
+
+func Decode(r io.Reader, format Format) (repository.Configuration, error) {
+	if format == FormatCSV {
+		return DecodeCSV(r)
+	}
+
+	config := repository.Configuration{}
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	err := d.Decode(&config)
+	return config, err
+}
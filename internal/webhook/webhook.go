@@ -0,0 +1,133 @@
+// Package webhook delivers JSON notifications over HTTP. Every request is signed with
+// HMAC-SHA256 of its timestamp and body, so that the receiver can check where it comes from.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Signature"
+	TimestampHeader = "X-Timestamp"
+
+	signaturePrefix = "sha256="
+	requestTimeout  = time.Second * 10
+)
+
+// permanentError is a failure which repeating the request can't fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+type Sender struct {
+	url     string
+	secret  []byte
+	retries int
+	backoff time.Duration
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewSender makes a sender which repeats a failed delivery up to retries times,
+// waiting twice as long before every next attempt starting from the backoff.
+func NewSender(url string, secret []byte, retries int, backoff time.Duration) (*Sender, error) {
+	if url == "" {
+		return nil, errors.New("webhook url is empty")
+	}
+	if retries < 0 || backoff < 0 {
+		return nil, errors.New("webhook retries and backoff must not be negative")
+	}
+
+	return &Sender{
+		url:     url,
+		secret:  secret,
+		retries: retries,
+		backoff: backoff,
+		client:  &http.Client{Timeout: requestTimeout},
+		now:     time.Now,
+	}, nil
+}
+
+// Send posts the payload as JSON. Network errors, server errors and 429 responses are retried,
+// the other client errors are not.
+func (s *Sender) Send(ctx context.Context, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	wait := s.backoff
+	for attempt := 0; ; attempt++ {
+		err = s.post(ctx, body)
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) || attempt == s.retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (s *Sender) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err: err}
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(s.secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+
+	return &permanentError{err: err}
+}
+
+// Sign returns the signature of the request with the timestamp and the body.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of the received request.
+func Verify(secret []byte, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
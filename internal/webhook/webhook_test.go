@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var secret = []byte("secret")
+
+func TestSendSigned(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.True(t, Verify(secret, r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)))
+		assert.False(t, Verify([]byte("another secret"), r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)))
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	s, err := NewSender(server.URL, secret, 0, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Send(context.Background(), map[string]int{"slot": 1}))
+	assert.Equal(t, `{"slot":1}`, <-received)
+}
+
+func TestSendRetries(t *testing.T) {
+	attempts := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewSender(server.URL, secret, 2, time.Millisecond)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Send(context.Background(), "alert"))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	atomic.StoreInt32(&attempts, -10)
+	assert.Error(t, s.Send(context.Background(), "alert"))
+	assert.Equal(t, int32(-7), atomic.LoadInt32(&attempts))
+}
+
+func TestSendPermanentFailure(t *testing.T) {
+	attempts := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	s, err := NewSender(server.URL, secret, 3, time.Millisecond)
+	assert.NoError(t, err)
+	assert.Error(t, s.Send(context.Background(), "alert"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	_, err = NewSender("", secret, 0, 0)
+	assert.Error(t, err)
+	_, err = NewSender(server.URL, secret, -1, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upBannerLifecycle, downBannerLifecycle)
+}
+
+func upBannerLifecycle(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE "banners"
+    ADD COLUMN "status" TEXT NOT NULL DEFAULT 'active'
+        CHECK ("status" IN ('draft', 'active', 'paused', 'archived')),
+    ADD COLUMN "start_at" TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN "end_at" TIMESTAMP WITH TIME ZONE;`)
+
+	return err
+}
+
+func downBannerLifecycle(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE "banners"
+    DROP COLUMN "status",
+    DROP COLUMN "start_at",
+    DROP COLUMN "end_at";`)
+
+	return err
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upSoftDelete, downSoftDelete)
+}
+
+func upSoftDelete(tx *sql.Tx) error {
+	for _, table := range []string{"banners", "slots", "groups"} {
+		if _, err := tx.Exec(`ALTER TABLE "` + table + `" ADD COLUMN "deleted_at" TIMESTAMP WITH TIME ZONE;`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func downSoftDelete(tx *sql.Tx) error {
+	for _, table := range []string{"banners", "slots", "groups"} {
+		if _, err := tx.Exec(`DELETE FROM "` + table + `" WHERE "deleted_at" IS NOT NULL;`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`ALTER TABLE "` + table + `" DROP COLUMN "deleted_at";`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upVersions, downVersions)
+}
+
+func upVersions(tx *sql.Tx) error {
+	for _, table := range []string{"banners", "groups"} {
+		if _, err := tx.Exec(`ALTER TABLE "` + table + `" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func downVersions(tx *sql.Tx) error {
+	for _, table := range []string{"banners", "groups"} {
+		if _, err := tx.Exec(`ALTER TABLE "` + table + `" DROP COLUMN "version";`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
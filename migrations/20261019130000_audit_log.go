@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upAuditLog, downAuditLog)
+}
+
+func upAuditLog(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+CREATE TABLE "audit_log" (
+    "id" SERIAL NOT NULL,
+    "actor" TEXT NOT NULL,
+    "action" TEXT NOT NULL,
+    "before" JSONB,
+    "after" JSONB,
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY ("id")
+);`); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`CREATE INDEX "audit_log_created_at_idx" ON "audit_log" ("created_at");`)
+
+	return err
+}
+
+func downAuditLog(tx *sql.Tx) error {
+	_, err := tx.Exec(`DROP TABLE "audit_log";`)
+
+	return err
+}
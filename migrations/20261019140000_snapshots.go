@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upSnapshots, downSnapshots)
+}
+
+func upSnapshots(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+CREATE TABLE "snapshots" (
+    "id" SERIAL NOT NULL,
+    "name" TEXT NOT NULL UNIQUE,
+    "comment" TEXT NOT NULL,
+    "slot_id" INTEGER NOT NULL,
+    "group_id" INTEGER NOT NULL,
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY ("id")
+);`); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+CREATE TABLE "snapshot_relations" (
+    "snapshot_id" INTEGER NOT NULL REFERENCES snapshots ON DELETE CASCADE,
+    "slot_id" INTEGER NOT NULL,
+    "banner_id" INTEGER NOT NULL,
+    "group_id" INTEGER NOT NULL,
+    "impressions" INTEGER NOT NULL,
+    "clicks" INTEGER NOT NULL
+);`); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`CREATE INDEX "snapshot_relations_snapshot_id_idx" ON "snapshot_relations" ("snapshot_id");`)
+
+	return err
+}
+
+func downSnapshots(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DROP TABLE "snapshot_relations";`); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`DROP TABLE "snapshots";`)
+
+	return err
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upImpressions, downImpressions)
+}
+
+func upImpressions(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "impressions" (
+    "id" TEXT NOT NULL,
+    "slot_id" INTEGER NOT NULL,
+    "banner_id" INTEGER NOT NULL,
+    "group_id" INTEGER NOT NULL,
+    "shown_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    "clicked_at" TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY ("id")
+);`)
+
+	return err
+}
+
+func downImpressions(tx *sql.Tx) error {
+	_, err := tx.Exec(`DROP TABLE "impressions";`)
+
+	return err
+}
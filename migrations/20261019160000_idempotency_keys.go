@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upIdempotencyKeys, downIdempotencyKeys)
+}
+
+func upIdempotencyKeys(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "idempotency_keys" (
+    "key" TEXT NOT NULL,
+    "status_code" INTEGER,
+    "content_type" TEXT NOT NULL DEFAULT '',
+    "body" BYTEA,
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY ("key")
+);
+CREATE INDEX "idempotency_keys_created_at" ON "idempotency_keys" ("created_at");`)
+
+	return err
+}
+
+func downIdempotencyKeys(tx *sql.Tx) error {
+	_, err := tx.Exec(`DROP TABLE "idempotency_keys";`)
+
+	return err
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upBannerLabels, downBannerLabels)
+}
+
+func upBannerLabels(tx *sql.Tx) error {
+	_, err := tx.Exec(`ALTER TABLE "banners" ADD COLUMN "labels" TEXT[] NOT NULL DEFAULT '{}';`)
+
+	return err
+}
+
+func downBannerLabels(tx *sql.Tx) error {
+	_, err := tx.Exec(`ALTER TABLE "banners" DROP COLUMN "labels";`)
+
+	return err
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upFrequencyCaps, downFrequencyCaps)
+}
+
+func upFrequencyCaps(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE "banners" ADD COLUMN "cap_limit" INTEGER;
+ALTER TABLE "banners" ADD COLUMN "cap_period" TEXT CHECK ("cap_period" IN ('hour', 'day'));
+CREATE TABLE "user_shows" (
+    "user_id" TEXT NOT NULL,
+    "banner_id" INTEGER NOT NULL,
+    "period" TEXT NOT NULL,
+    "period_start" TIMESTAMP WITH TIME ZONE NOT NULL,
+    "shows" INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY ("user_id", "banner_id", "period", "period_start")
+);
+CREATE INDEX "user_shows_period_start" ON "user_shows" ("period_start");`)
+
+	return err
+}
+
+func downFrequencyCaps(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP TABLE "user_shows";
+ALTER TABLE "banners" DROP COLUMN "cap_period";
+ALTER TABLE "banners" DROP COLUMN "cap_limit";`)
+
+	return err
+}
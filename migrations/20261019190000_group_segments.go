@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upGroupSegments, downGroupSegments)
+}
+
+func upGroupSegments(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE "groups" ADD COLUMN "segment" JSONB;
+ALTER TABLE "groups" ADD COLUMN "fallback" BOOLEAN NOT NULL DEFAULT false;
+CREATE UNIQUE INDEX "groups_fallback" ON "groups" ("fallback") WHERE "fallback" AND "deleted_at" IS NULL;`)
+
+	return err
+}
+
+func downGroupSegments(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP INDEX "groups_fallback";
+ALTER TABLE "groups" DROP COLUMN "fallback";
+ALTER TABLE "groups" DROP COLUMN "segment";`)
+
+	return err
+}
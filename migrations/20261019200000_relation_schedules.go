@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upRelationSchedules, downRelationSchedules)
+}
+
+func upRelationSchedules(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "relation_schedules" (
+    "slot_id" INTEGER NOT NULL REFERENCES "slots" ("id") ON DELETE CASCADE,
+    "banner_id" INTEGER NOT NULL REFERENCES "banners" ("id") ON DELETE CASCADE,
+    "schedule" JSONB NOT NULL,
+    PRIMARY KEY ("slot_id", "banner_id")
+);`)
+
+	return err
+}
+
+func downRelationSchedules(tx *sql.Tx) error {
+	_, err := tx.Exec(`DROP TABLE "relation_schedules";`)
+
+	return err
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upCampaigns, downCampaigns)
+}
+
+func upCampaigns(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "advertisers" (
+    "id" SERIAL PRIMARY KEY,
+    "name" TEXT NOT NULL,
+    "version" INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE "campaigns" (
+    "id" SERIAL PRIMARY KEY,
+    "advertiser_id" INTEGER NOT NULL REFERENCES "advertisers" ("id"),
+    "name" TEXT NOT NULL,
+    "status" TEXT NOT NULL DEFAULT 'active',
+    "version" INTEGER NOT NULL DEFAULT 1
+);
+CREATE INDEX "campaigns_advertiser_id" ON "campaigns" ("advertiser_id");
+ALTER TABLE "banners" ADD COLUMN "campaign_id" INTEGER REFERENCES "campaigns" ("id") ON DELETE SET NULL;
+CREATE INDEX "banners_campaign_id" ON "banners" ("campaign_id");`)
+
+	return err
+}
+
+func downCampaigns(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE "banners" DROP COLUMN "campaign_id";
+DROP TABLE "campaigns";
+DROP TABLE "advertisers";`)
+
+	return err
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upBudgets, downBudgets)
+}
+
+func upBudgets(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE "banners" ADD COLUMN "budget" JSONB;
+ALTER TABLE "campaigns" ADD COLUMN "budget" JSONB;
+CREATE TABLE "budget_spend" (
+    "owner" TEXT NOT NULL,
+    "owner_id" INTEGER NOT NULL,
+    "day" DATE NOT NULL,
+    "impressions" BIGINT NOT NULL DEFAULT 0,
+    "spend" DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY ("owner", "owner_id", "day")
+);`)
+
+	return err
+}
+
+func downBudgets(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP TABLE "budget_spend";
+ALTER TABLE "campaigns" DROP COLUMN "budget";
+ALTER TABLE "banners" DROP COLUMN "budget";`)
+
+	return err
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upRelationDelivery, downRelationDelivery)
+}
+
+func upRelationDelivery(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "relation_delivery" (
+    "slot_id" INTEGER NOT NULL REFERENCES "slots" ("id") ON DELETE CASCADE,
+    "banner_id" INTEGER NOT NULL REFERENCES "banners" ("id") ON DELETE CASCADE,
+    "tier" TEXT NOT NULL,
+    "share" DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY ("slot_id", "banner_id")
+);`)
+
+	return err
+}
+
+func downRelationDelivery(tx *sql.Tx) error {
+	_, err := tx.Exec(`DROP TABLE "relation_delivery";`)
+
+	return err
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upExperiments, downExperiments)
+}
+
+func upExperiments(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "experiments" (
+    "id" SERIAL PRIMARY KEY,
+    "slot_id" INTEGER NOT NULL REFERENCES "slots" ("id") ON DELETE CASCADE,
+    "name" TEXT NOT NULL,
+    "arms" JSONB NOT NULL,
+    "status" TEXT NOT NULL DEFAULT 'running',
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    "stopped_at" TIMESTAMP WITH TIME ZONE
+);
+CREATE UNIQUE INDEX "experiments_running_slot" ON "experiments" ("slot_id") WHERE "status" = 'running';
+CREATE TABLE "experiment_counters" (
+    "experiment_id" INTEGER NOT NULL REFERENCES "experiments" ("id") ON DELETE CASCADE,
+    "arm" TEXT NOT NULL,
+    "banner_id" INTEGER NOT NULL,
+    "group_id" INTEGER NOT NULL,
+    "impressions" BIGINT NOT NULL DEFAULT 0,
+    "clicks" BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY ("experiment_id", "arm", "banner_id", "group_id")
+);`)
+
+	return err
+}
+
+func downExperiments(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP TABLE "experiment_counters";
+DROP TABLE "experiments";`)
+
+	return err
+}
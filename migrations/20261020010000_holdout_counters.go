@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upHoldoutCounters, downHoldoutCounters)
+}
+
+func upHoldoutCounters(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "holdout_counters" (
+    "slot_id" INTEGER NOT NULL,
+    "banner_id" INTEGER NOT NULL,
+    "group_id" INTEGER NOT NULL,
+    "impressions" BIGINT NOT NULL DEFAULT 0,
+    "clicks" BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY ("slot_id", "banner_id", "group_id")
+);`)
+
+	return err
+}
+
+func downHoldoutCounters(tx *sql.Tx) error {
+	_, err := tx.Exec(`DROP TABLE "holdout_counters";`)
+
+	return err
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upFlaggedEvents, downFlaggedEvents)
+}
+
+func upFlaggedEvents(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "flagged_events" (
+    "id" SERIAL PRIMARY KEY,
+    "type" TEXT NOT NULL,
+    "impression_id" TEXT,
+    "slot_id" INTEGER NOT NULL,
+    "banner_id" INTEGER NOT NULL,
+    "group_id" INTEGER NOT NULL,
+    "user_id" TEXT,
+    "ip" TEXT,
+    "reasons" TEXT[] NOT NULL,
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX "flagged_events_created_at" ON "flagged_events" ("created_at");`)
+
+	return err
+}
+
+func downFlaggedEvents(tx *sql.Tx) error {
+	_, err := tx.Exec(`DROP TABLE "flagged_events";`)
+
+	return err
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upEvents, downEvents)
+}
+
+func upEvents(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "events" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "type" TEXT NOT NULL,
+    "slot_id" INTEGER NOT NULL,
+    "banner_id" INTEGER NOT NULL,
+    "group_id" INTEGER NOT NULL,
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX "events_created_at" ON "events" ("created_at");`)
+
+	return err
+}
+
+func downEvents(tx *sql.Tx) error {
+	_, err := tx.Exec(`DROP TABLE "events";`)
+
+	return err
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upRelationCreatedAt, downRelationCreatedAt)
+}
+
+// upRelationCreatedAt leaves the time of the existing relations unknown, only the relations
+// added afterwards are timestamped.
+func upRelationCreatedAt(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE "relations" ADD COLUMN "created_at" TIMESTAMP WITH TIME ZONE;
+ALTER TABLE "relations" ALTER COLUMN "created_at" SET DEFAULT now();
+CREATE INDEX "events_slot_id_banner_id" ON "events" ("slot_id", "banner_id", "group_id", "created_at");`)
+
+	return err
+}
+
+func downRelationCreatedAt(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP INDEX "events_slot_id_banner_id";
+ALTER TABLE "relations" DROP COLUMN "created_at";`)
+
+	return err
+}
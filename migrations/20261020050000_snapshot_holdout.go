@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upSnapshotHoldout, downSnapshotHoldout)
+}
+
+func upSnapshotHoldout(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE "snapshot_relations" ADD COLUMN "holdout_impressions" BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE "snapshot_relations" ADD COLUMN "holdout_clicks" BIGINT NOT NULL DEFAULT 0;`)
+
+	return err
+}
+
+func downSnapshotHoldout(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE "snapshot_relations" DROP COLUMN "holdout_clicks";
+ALTER TABLE "snapshot_relations" DROP COLUMN "holdout_impressions";`)
+
+	return err
+}
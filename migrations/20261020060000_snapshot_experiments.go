@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upSnapshotExperiments, downSnapshotExperiments)
+}
+
+func upSnapshotExperiments(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "snapshot_experiment_counters" (
+    "snapshot_id" INTEGER NOT NULL REFERENCES "snapshots" ON DELETE CASCADE,
+    "experiment_id" INTEGER NOT NULL,
+    "arm" TEXT NOT NULL,
+    "banner_id" INTEGER NOT NULL,
+    "group_id" INTEGER NOT NULL,
+    "impressions" BIGINT NOT NULL,
+    "clicks" BIGINT NOT NULL
+);
+CREATE INDEX "snapshot_experiment_counters_snapshot_id_idx" ON "snapshot_experiment_counters" ("snapshot_id");`)
+
+	return err
+}
+
+func downSnapshotExperiments(tx *sql.Tx) error {
+	_, err := tx.Exec(`DROP TABLE "snapshot_experiment_counters";`)
+
+	return err
+}
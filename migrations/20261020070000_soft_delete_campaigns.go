@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upSoftDeleteCampaigns, downSoftDeleteCampaigns)
+}
+
+func upSoftDeleteCampaigns(tx *sql.Tx) error {
+	for _, table := range []string{"advertisers", "campaigns"} {
+		if _, err := tx.Exec(`ALTER TABLE "` + table + `" ADD COLUMN "deleted_at" TIMESTAMP WITH TIME ZONE;`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func downSoftDeleteCampaigns(tx *sql.Tx) error {
+	// the campaigns go first, the removed advertisers may still have removed campaigns
+	for _, table := range []string{"campaigns", "advertisers"} {
+		if _, err := tx.Exec(`DELETE FROM "` + table + `" WHERE "deleted_at" IS NOT NULL;`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`ALTER TABLE "` + table + `" DROP COLUMN "deleted_at";`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}